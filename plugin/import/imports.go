@@ -80,10 +80,13 @@ var (
 	ImportLibPQ             = Import{"github.com/lib/pq", "_"}
 	ImportLibPQWOAlias      = Import{"github.com/lib/pq", ""}
 	ImportPgxConn           = Import{"github.com/jackc/pgx/v5/pgconn", ""}
+	ImportPgx               = Import{"github.com/jackc/pgx/v5", ""}
+	ImportPgxStdlib         = Import{"github.com/jackc/pgx/v5/stdlib", ""}
 	ImportLibSqlite3        = Import{"github.com/mattn/go-sqlite3", "_"}
 	ImportLibSqlite3WOAlias = Import{"github.com/mattn/go-sqlite3", ""}
 	ImportStrings           = Import{"strings", ""}
 	ImportMath              = Import{"math", ""}
+	ImportMathRand          = Import{"math/rand", ""}
 	ImportSquirrel          = Import{"github.com/Masterminds/squirrel", "sq"}
 	ImportNull              = Import{"gopkg.in/guregu/null.v4", ""}
 	ImportFMT               = Import{"fmt", ""}
@@ -92,12 +95,21 @@ var (
 	ImportContext           = Import{"context", ""}
 	ImportStrconv           = Import{"strconv", ""}
 	ImportSync              = Import{"sync", ""}
+	ImportSyncAtomic        = Import{"sync/atomic", ""}
+	ImportNet               = Import{"net", ""}
 	ImportTime              = Import{"time", ""}
 	ImportJson              = Import{"encoding/json", ""}
 	ImportSQLDriver         = Import{"database/sql/driver", ""}
 	ImportGoogleUUID        = Import{"github.com/google/uuid", ""}
 	ImportStructPB          = Import{"google.golang.org/protobuf/types/known/structpb", ""}
+	ImportProto             = Import{"google.golang.org/protobuf/proto", ""}
 	ImportClickhouse        = Import{"github.com/ClickHouse/clickhouse-go/v2", ""}
+	ImportRegexp            = Import{"regexp", ""}
+	ImportOtel              = Import{"go.opentelemetry.io/otel", ""}
+	ImportOtelAttribute     = Import{"go.opentelemetry.io/otel/attribute", ""}
+	ImportOtelCodes         = Import{"go.opentelemetry.io/otel/codes", ""}
+	ImportOtelTrace         = Import{"go.opentelemetry.io/otel/trace", ""}
+	ImportPrometheus        = Import{"github.com/prometheus/client_golang/prometheus", ""}
 	ImportClickhouseDriver  = Import{"github.com/ClickHouse/clickhouse-go/v2/lib/driver", ""}
 )
 
@@ -63,6 +63,52 @@ func HasUnique(f *descriptorpb.FieldDescriptorProto) bool {
 	return false
 }
 
+// IsAutoCreateTime returns the auto_create_time option for a field.
+func IsAutoCreateTime(f *descriptorpb.FieldDescriptorProto) bool {
+	if opts := GetFieldOptions(f); opts != nil {
+		return opts.GetAutoCreateTime()
+	}
+	return false
+}
+
+// IsAutoUpdateTime returns the auto_update_time option for a field.
+func IsAutoUpdateTime(f *descriptorpb.FieldDescriptorProto) bool {
+	if opts := GetFieldOptions(f); opts != nil {
+		return opts.GetAutoUpdateTime()
+	}
+	return false
+}
+
+// AutoTimeValue returns a Go expression that converts the time.Time held in now
+// into the given field type. Pointer types are resolved to their element type.
+func AutoTimeValue(goType string, now string) (string, error) {
+	switch ClearPointer(goType) {
+	case "time.Time":
+		return now, nil
+	case "string":
+		return now + ".Format(time.RFC3339Nano)", nil
+	case "int64":
+		return now + ".Unix()", nil
+	case "int32", "uint32", "uint64":
+		return ClearPointer(goType) + "(" + now + ".Unix())", nil
+	}
+	return "", fmt.Errorf("unsupported type %q for automatic time field", goType)
+}
+
+// AutoTimeIsZero returns a Go expression that reports whether v holds the zero
+// value of the given automatic time field type.
+func AutoTimeIsZero(goType string, v string) string {
+	switch {
+	case strings.HasPrefix(goType, "*"):
+		return v + " == nil"
+	case goType == "time.Time":
+		return v + ".IsZero()"
+	case goType == "string":
+		return v + ` == ""`
+	}
+	return v + " == 0"
+}
+
 // GetMessageOptions returns the custom options for a message.
 func GetMessageOptions(d *descriptorpb.DescriptorProto) *structify.StructifyMessageOptions {
 	opts := d.GetOptions()
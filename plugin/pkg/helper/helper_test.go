@@ -161,6 +161,33 @@ func TestClearPointer(t *testing.T) {
 	}
 }
 
+func TestAutoTimeValue(t *testing.T) {
+	tests := []struct {
+		goType string
+		expr   string
+		isZero string
+	}{
+		{"time.Time", "now", "v.IsZero()"},
+		{"*time.Time", "now", "v == nil"},
+		{"string", "now.Format(time.RFC3339Nano)", `v == ""`},
+		{"int64", "now.Unix()", "v == 0"},
+		{"*int64", "now.Unix()", "v == nil"},
+		{"uint32", "uint32(now.Unix())", "v == 0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.goType, func(t *testing.T) {
+			expr, err := AutoTimeValue(test.goType, "now")
+			assert.NoError(t, err)
+			assert.Equal(t, test.expr, expr)
+			assert.Equal(t, test.isZero, AutoTimeIsZero(test.goType, "v"))
+		})
+	}
+
+	_, err := AutoTimeValue("bool", "now")
+	assert.Error(t, err)
+}
+
 // TestConvertType tests the ConvertType function
 func TestConvertType(t *testing.T) {
 	// Test cases representing different field types
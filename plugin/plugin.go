@@ -75,6 +75,8 @@ func (p *Plugin) Run() {
 		p.state.IncludeConnection = p.parseIncludeConnectionParam()
 		p.state.CRUDSchemas = p.parseCRUDSchemasParam()
 		p.state.UseSQLX = p.parseSQLXParam()
+		p.state.Otel = p.parseOtelParam()
+		p.state.Metrics = p.parseMetricsParam()
 	}
 
 	// get provider template builder based on command line parameter
@@ -148,6 +150,14 @@ func (p *Plugin) parseSQLXParam() bool {
 	return p.param["sqlx"] == "true"
 }
 
+func (p *Plugin) parseOtelParam() bool {
+	return p.param["otel"] == "true"
+}
+
+func (p *Plugin) parseMetricsParam() bool {
+	return p.param["metrics"] == "true"
+}
+
 // parsePathType parses the path type from the parameters.
 func (p *Plugin) parsePathType() {
 	switch p.param["paths"] {
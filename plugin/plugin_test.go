@@ -328,6 +328,13 @@ func TestPluginRun(t *testing.T) {
 	require.NoError(t, err)
 	os.Stdout = stdoutW
 
+	// Drain stdout while the plugin runs, the response can exceed the pipe buffer
+	responseCh := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(stdoutR)
+		responseCh <- data
+	}()
+
 	// Run the plugin
 	plugin := NewPlugin()
 	plugin.Run()
@@ -336,8 +343,7 @@ func TestPluginRun(t *testing.T) {
 	stdoutW.Close()
 
 	// Read the response from the read end of the pipe
-	responseData, err := io.ReadAll(stdoutR)
-	require.NoError(t, err)
+	responseData := <-responseCh
 
 	// Read the response
 	response := &plugingo.CodeGeneratorResponse{}
@@ -25,6 +25,7 @@ type initTemplater struct {
 
 	// initMethods bool
 	CRUDSchemas bool
+	Otel        bool
 }
 
 // NewInitTemplater returns a new initTemplater.
@@ -34,6 +35,7 @@ func NewInitTemplater(state *statepkg.State) statepkg.Templater {
 
 		IncludeConnection: state.IncludeConnection,
 		CRUDSchemas:       state.CRUDSchemas,
+		Otel:              state.Otel,
 	}
 }
 
@@ -71,6 +73,18 @@ func (i *initTemplater) BuildTemplate() string {
 			Name: "transaction",
 			Body: tmplpkg.TransactionManagerTemplate,
 		},
+		helperpkg.IncludeTemplate{
+			Name: "interceptors",
+			Body: tmplpkg.InterceptorsTemplate,
+		},
+		helperpkg.IncludeTemplate{
+			Name: "slowquery",
+			Body: tmplpkg.SlowQueryTemplate,
+		},
+		helperpkg.IncludeTemplate{
+			Name: "tracing",
+			Body: tmplpkg.TracingTemplate,
+		},
 		helperpkg.IncludeTemplate{
 			Name: "options",
 			Body: tmplpkg.OptionsTemplate,
@@ -98,16 +112,31 @@ func (i *initTemplater) Imports() *importpkg.ImportSet {
 		importpkg.ImportContext,
 		importpkg.ImportSquirrel,
 		importpkg.ImportClickhouseDriver,
+		importpkg.ImportStdErrors,
+		importpkg.ImportDb,
 	)
 
-	if i.IncludeConnection {
+	tmp := i.BuildTemplate()
+	if i.IncludeConnection || strings.Contains(tmp, "clickhouse.") {
 		is.Add(importpkg.ImportClickhouse)
 	}
-	tmp := i.BuildTemplate()
+	if strings.Contains(tmp, "time.Time") {
+		is.Add(importpkg.ImportTime)
+	}
 	if strings.Contains(tmp, "structpb.") {
 		is.Add(importpkg.ImportStructPB)
 	}
 
+	if i.Otel {
+		is.Add(
+			importpkg.ImportRegexp,
+			importpkg.ImportOtel,
+			importpkg.ImportOtelAttribute,
+			importpkg.ImportOtelCodes,
+			importpkg.ImportOtelTrace,
+		)
+	}
+
 	return is
 }
 
@@ -387,6 +387,63 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 			return false
 		},
 
+		// getPrimaryKeys returns the primary key fields.
+		"getPrimaryKeys": func() []*descriptorpb.FieldDescriptorProto {
+			return t.primaryKeys()
+		},
+
+		// hasCompositePrimaryKey returns true if more than one primary key exists.
+		"hasCompositePrimaryKey": func() bool {
+			return len(t.primaryKeys()) > 1
+		},
+
+		// primaryKeyName returns the name of the primary key, "key" for a composite one.
+		"primaryKeyName": func() string {
+			if len(t.primaryKeys()) > 1 {
+				return "key"
+			}
+			for _, f := range t.primaryKeys() {
+				return f.GetName()
+			}
+			return ""
+		},
+
+		// keyType returns the type of the primary key, the key struct for a composite one.
+		"keyType": func() string {
+			pks := t.primaryKeys()
+			if len(pks) > 1 {
+				return helperpkg.UpperCamelCase(t.message.GetName()) + "Key"
+			}
+			for _, f := range pks {
+				return helperpkg.ConvertType(f)
+			}
+			return "int64"
+		},
+
+		// isCompositeRelation returns true if the relation spans several columns.
+		"isCompositeRelation": func(f *descriptorpb.FieldDescriptorProto) bool {
+			relation, ok := t.state.Relations.Get(statepkg.RelationName(t.message.GetName(), f))
+			return ok && relation.IsComposite()
+		},
+
+		// relationFields returns the fields of the message a composite relation is joined on.
+		"relationFields": func(f *descriptorpb.FieldDescriptorProto) []*descriptorpb.FieldDescriptorProto {
+			relation, ok := t.state.Relations.Get(statepkg.RelationName(t.message.GetName(), f))
+			if !ok {
+				return nil
+			}
+			return fieldsByName(relation.ParentDescriptor, relation.Fields())
+		},
+
+		// relationReferences returns the fields of the related message a composite relation is joined on.
+		"relationReferences": func(f *descriptorpb.FieldDescriptorProto) []*descriptorpb.FieldDescriptorProto {
+			relation, ok := t.state.Relations.Get(statepkg.RelationName(t.message.GetName(), f))
+			if !ok {
+				return nil
+			}
+			return fieldsByName(relation.RelationDescriptor, relation.References())
+		},
+
 		"hasPrimaryKey": func() bool {
 			for _, f := range t.message.GetField() {
 				if opts := helperpkg.GetFieldOptions(f); opts != nil {
@@ -515,7 +572,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		// isOptional returns true if the field is marked as optional.
 		"isOptional": func(f *descriptorpb.FieldDescriptorProto) bool {
 			// Construct the relation name based on the message and the field type.
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 
 			// Retrieve the relation from the state using the constructed name.
 			relation, ok := t.state.Relations.Get(relName)
@@ -540,7 +597,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 
 		// hasRelationOptions returns true if the field has relation options.
 		"hasRelationOptions": func(f *descriptorpb.FieldDescriptorProto) bool {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 			_, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -558,10 +615,51 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		// hasUnique returns true if the field has unique.
 		"hasUnique": helperpkg.HasUnique,
 
+		// isAutoCreateTime returns true if the field is filled with the current time on insert.
+		"isAutoCreateTime": helperpkg.IsAutoCreateTime,
+
+		// isAutoUpdateTime returns true if the field is filled with the current time on insert and update.
+		"isAutoUpdateTime": helperpkg.IsAutoUpdateTime,
+
+		// otel returns true if the storage methods start OpenTelemetry spans.
+		"otel": func() bool {
+			return t.state.Otel
+		},
+
+		// hasAutoTime returns true if the message has automatic time fields.
+		"hasAutoTime": func() bool {
+			for _, f := range t.message.GetField() {
+				if helperpkg.IsAutoCreateTime(f) || helperpkg.IsAutoUpdateTime(f) {
+					return true
+				}
+			}
+			return false
+		},
+
+		// hasAutoUpdateTime returns true if the message has auto update time fields.
+		"hasAutoUpdateTime": func() bool {
+			for _, f := range t.message.GetField() {
+				if helperpkg.IsAutoUpdateTime(f) {
+					return true
+				}
+			}
+			return false
+		},
+
+		// autoTimeValue returns the expression that converts the current time into the field type.
+		"autoTimeValue": func(f *descriptorpb.FieldDescriptorProto) (string, error) {
+			return helperpkg.AutoTimeValue(helperpkg.ConvertType(f), "now")
+		},
+
+		// autoTimeIsZero returns the expression that checks if the automatic time value is not set.
+		"autoTimeIsZero": func(f *descriptorpb.FieldDescriptorProto, v string) string {
+			return helperpkg.AutoTimeIsZero(helperpkg.ConvertType(f), v)
+		},
+
 		// hasRelation returns true if the message has relation.
 		"hasRelation": func() bool {
 			for _, f := range t.message.GetField() {
-				relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+				relName := statepkg.RelationName(t.message.GetName(), f)
 				_, ok := t.state.Relations.Get(relName)
 				if ok {
 					return true
@@ -570,9 +668,35 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 			return false
 		},
 
+		// relationKeyType returns the type of the field the related rows reference, without pointer.
+		"relationKeyType": func(f *descriptorpb.FieldDescriptorProto) string {
+			relName := statepkg.RelationName(t.message.GetName(), f)
+			if relation, ok := t.state.Relations.Get(relName); ok {
+				for _, field := range relation.ParentDescriptor.GetField() {
+					if field.GetName() == relation.Field {
+						return helperpkg.ClearPointer(helperpkg.ConvertType(field))
+					}
+				}
+			}
+			return "interface{}"
+		},
+
+		// isRefOptional returns true if the referencing field of the related message is optional.
+		"isRefOptional": func(f *descriptorpb.FieldDescriptorProto) bool {
+			relName := statepkg.RelationName(t.message.GetName(), f)
+			if relation, ok := t.state.Relations.Get(relName); ok && relation.RelationDescriptor != nil {
+				for _, field := range relation.RelationDescriptor.GetField() {
+					if field.GetName() == relation.Reference {
+						return field.GetProto3Optional()
+					}
+				}
+			}
+			return false
+		},
+
 		// relation returns the relation.
 		"relation": func(f *descriptorpb.FieldDescriptorProto) *statepkg.Relation {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 			relation, ok := t.state.Relations.Get(relName)
 			if !ok {
 				return nil
@@ -583,7 +707,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 
 		// relationName returns the relation name.
 		"relationStorageName": func(f *descriptorpb.FieldDescriptorProto) string {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -593,7 +717,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		},
 
 		"relationStructureName": func(f *descriptorpb.FieldDescriptorProto) string {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -603,7 +727,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		},
 
 		"relationTableName": func(f *descriptorpb.FieldDescriptorProto) string {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -620,7 +744,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 
 		// relationName returns the relation name.
 		"hasIDFromRelation": func(f *descriptorpb.FieldDescriptorProto) bool {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -669,7 +793,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		},
 
 		"getFieldID": func(fl *descriptorpb.FieldDescriptorProto) string {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(fl))
+			relName := statepkg.RelationName(t.message.GetName(), fl)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -705,7 +829,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		},
 
 		"getRefID": func(fl *descriptorpb.FieldDescriptorProto) string {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(fl))
+			relName := statepkg.RelationName(t.message.GetName(), fl)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -736,7 +860,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		},
 
 		"getRefSource": func(fl *descriptorpb.FieldDescriptorProto) string {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(fl))
+			relName := statepkg.RelationName(t.message.GetName(), fl)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -785,7 +909,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		},
 
 		"getFieldSource": func(fl *descriptorpb.FieldDescriptorProto) string {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(fl))
+			relName := statepkg.RelationName(t.message.GetName(), fl)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -835,7 +959,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 
 		// relationAllowSubCreating returns true if the relation allows sub creating.
 		"relationAllowSubCreating": func(f *descriptorpb.FieldDescriptorProto) bool {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -901,3 +1025,27 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		"lowerCamelCase": helperpkg.LowerCamelCase,
 	}
 }
+
+// primaryKeys returns the primary key fields of the message.
+func (t *tableTemplater) primaryKeys() []*descriptorpb.FieldDescriptorProto {
+	var pks []*descriptorpb.FieldDescriptorProto
+	for _, f := range t.message.GetField() {
+		if opts := helperpkg.GetFieldOptions(f); opts != nil && opts.GetPrimaryKey() {
+			pks = append(pks, f)
+		}
+	}
+	return pks
+}
+
+// fieldsByName returns the fields of the message with the given names in the order of the names.
+func fieldsByName(msg *descriptorpb.DescriptorProto, names []string) []*descriptorpb.FieldDescriptorProto {
+	var fields []*descriptorpb.FieldDescriptorProto
+	for _, name := range names {
+		for _, f := range msg.GetField() {
+			if f.GetName() == name {
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields
+}
@@ -0,0 +1,75 @@
+package templater
+
+import (
+	"testing"
+
+	structify "github.com/cjp2600/protoc-gen-structify/plugin/options"
+	statepkg "github.com/cjp2600/protoc-gen-structify/plugin/state"
+	plugingo "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func testField(name string, opts *structify.StructifyFieldOptions) *descriptorpb.FieldDescriptorProto {
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:  proto.String(name),
+		Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:  descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+	}
+	if opts != nil {
+		f.Options = &descriptorpb.FieldOptions{}
+		proto.SetExtension(f.Options, structify.E_Field, opts)
+	}
+	return f
+}
+
+func TestTableTemplate_CompositeKey(t *testing.T) {
+	relation := func(rel *structify.Relation) *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, structify.E_Field, &structify.StructifyFieldOptions{Relation: rel})
+		return opts
+	}
+
+	tag := &descriptorpb.DescriptorProto{
+		Name: proto.String("ActiveTag"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			testField("customer_id", &structify.StructifyFieldOptions{PrimaryKey: true}),
+			testField("tag_name", &structify.StructifyFieldOptions{PrimaryKey: true}),
+			{
+				Name:     proto.String("events"),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".test.TagEvent"),
+				Number:   proto.Int32(3),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Options:  relation(&structify.Relation{Field: "customer_id, tag_name", Reference: "customer_id, tag_name"}),
+			},
+		},
+	}
+	event := &descriptorpb.DescriptorProto{
+		Name: proto.String("TagEvent"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			testField("id", &structify.StructifyFieldOptions{PrimaryKey: true}),
+			testField("customer_id", nil),
+			testField("tag_name", nil),
+		},
+	}
+	s := statepkg.NewState(&plugingo.CodeGeneratorRequest{
+		FileToGenerate: []string{"test.proto"},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{{
+			Name:        proto.String("test.proto"),
+			Package:     proto.String("test"),
+			MessageType: []*descriptorpb.DescriptorProto{tag, event},
+		}},
+	})
+
+	out := NewTableTemplater(tag, s).BuildTemplate()
+	require.Contains(t, out, "type ActiveTagKey struct {\n\tCustomerId string\n\tTagName string\n}")
+	require.Contains(t, out, "func (t *ActiveTag) Key() ActiveTagKey {")
+	require.Contains(t, out, "func ActiveTagKeyEq(key ActiveTagKey) FilterApplier {")
+	require.Contains(t, out, "FindByKey(ctx context.Context, key ActiveTagKey, opts ...Option) (*ActiveTag, error)")
+	require.Contains(t, out, "func (t *activeTagStorage) LoadBatchEvents(ctx context.Context, items []*ActiveTag, builders ...*QueryBuilder) error {")
+	require.Contains(t, out, "key := [2]interface{}{item.CustomerId, item.TagName}")
+	require.Contains(t, out, `partitionBuilder("customer_id, tag_name")`)
+	require.Contains(t, out, "return t.LoadBatchEvents(ctx, []*ActiveTag{model}, builders...)")
+}
@@ -0,0 +1,199 @@
+package tmpl
+
+// InterceptorsTemplate is the template for the query interceptors.
+// This is included in the init template.
+const InterceptorsTemplate = `
+// Operation is the kind of a query.
+type Operation string
+
+const (
+	OperationCreate Operation = "create"
+	OperationFind   Operation = "find"
+	OperationUpdate Operation = "update"
+	OperationDelete Operation = "delete"
+	OperationRaw    Operation = "raw"
+)
+
+// Query is a query passing through the interceptors.
+// Interceptors may change SQL and Args before calling next.
+type Query struct {
+	Table     string
+	Operation Operation
+	SQL       string
+	Args      []interface{}
+}
+
+// QueryResult is the result of a query. Only the field of the executing method is set:
+// Rows for Query, Row for QueryRow and Batch for PrepareBatch.
+// Select, Exec and AsyncInsert only return an error.
+type QueryResult struct {
+	Rows  driver.Rows
+	Row   driver.Row
+	Batch driver.Batch
+}
+
+// QueryHandler executes a query.
+type QueryHandler func(ctx context.Context, query *Query) (*QueryResult, error)
+
+// QueryInterceptor wraps the execution of every query; it calls next to execute the query.
+// For QueryRow the error is the error of the row.
+type QueryInterceptor func(ctx context.Context, query *Query, next QueryHandler) (*QueryResult, error)
+
+// operationKey is the key used to store the operation of the queries in the context.
+type operationKey struct{}
+
+// withOperation returns a context whose queries have the given operation.
+func withOperation(ctx context.Context, operation Operation) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// queryOperation returns the operation stored in the context, or the one of the statement.
+func queryOperation(ctx context.Context, query string) Operation {
+	if operation, ok := ctx.Value(operationKey{}).(Operation); ok {
+		return operation
+	}
+
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return OperationRaw
+	}
+
+	switch strings.ToUpper(fields[0]) {
+	case "INSERT":
+		return OperationCreate
+	case "SELECT":
+		return OperationFind
+	case "UPDATE":
+		return OperationUpdate
+	case "DELETE":
+		return OperationDelete
+	}
+	return OperationRaw
+}
+
+// tracingEnabled is true when the queries are traced with OpenTelemetry.
+const tracingEnabled = {{ if .Otel }}true{{ else }}false{{ end }}
+
+// intercepted returns true if the queries have to run through the wrapper,
+// for the tracing, the interceptors or the slow query detection.
+func (c *Config) intercepted() bool {
+	return tracingEnabled || len(c.Interceptors) > 0 || (c.SlowQueryHandler != nil && c.SlowQueryThreshold > 0)
+}
+
+// queryInterceptors returns the interceptors running around every query.
+func (c *Config) queryInterceptors() []QueryInterceptor {
+{{- if .Otel }}
+	return append([]QueryInterceptor{c.traceQuery}, c.Interceptors...)
+{{- else }}
+	return c.Interceptors
+{{- end }}
+}
+
+// intercept runs the query through the interceptors, the first one being the outermost, and then exec.
+func intercept(ctx context.Context, interceptors []QueryInterceptor, query *Query, exec QueryHandler) (*QueryResult, error) {
+	handler := exec
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, query *Query) (*QueryResult, error) {
+			return interceptor(ctx, query, next)
+		}
+	}
+
+	result, err := handler(ctx, query)
+	if result == nil {
+		result = &QueryResult{}
+	}
+	return result, err
+}
+
+// connWrapper wraps the connection to run the queries through the interceptors.
+type connWrapper struct {
+	driver.Conn
+	config *Config
+	table  string
+}
+
+// intercept runs a query through the interceptors of the config.
+func (w *connWrapper) intercept(ctx context.Context, query string, args []any, exec QueryHandler) (*QueryResult, error) {
+	return intercept(ctx, w.config.queryInterceptors(), &Query{
+		Table:     w.table,
+		Operation: queryOperation(ctx, query),
+		SQL:       query,
+		Args:      args,
+	}, w.config.slowQueries(w.Conn, exec))
+}
+
+// Select implements driver.Conn interface.
+func (w *connWrapper) Select(ctx context.Context, dest any, query string, args ...any) error {
+	_, err := w.intercept(ctx, query, args, func(ctx context.Context, q *Query) (*QueryResult, error) {
+		return nil, w.Conn.Select(ctx, dest, q.SQL, q.Args...)
+	})
+	return err
+}
+
+// Query implements driver.Conn interface.
+func (w *connWrapper) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	result, err := w.intercept(ctx, query, args, func(ctx context.Context, q *Query) (*QueryResult, error) {
+		rows, err := w.Conn.Query(ctx, q.SQL, q.Args...)
+		return &QueryResult{Rows: rows}, err
+	})
+	return result.Rows, err
+}
+
+// QueryRow implements driver.Conn interface.
+func (w *connWrapper) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	result, err := w.intercept(ctx, query, args, func(ctx context.Context, q *Query) (*QueryResult, error) {
+		row := w.Conn.QueryRow(ctx, q.SQL, q.Args...)
+		return &QueryResult{Row: row}, row.Err()
+	})
+	if result.Row == nil {
+		return &errRow{err: err}
+	}
+	return result.Row
+}
+
+// PrepareBatch implements driver.Conn interface.
+func (w *connWrapper) PrepareBatch(ctx context.Context, query string, opts ...driver.PrepareBatchOption) (driver.Batch, error) {
+	result, err := w.intercept(ctx, query, nil, func(ctx context.Context, q *Query) (*QueryResult, error) {
+		batch, err := w.Conn.PrepareBatch(ctx, q.SQL, opts...)
+		return &QueryResult{Batch: batch}, err
+	})
+	return result.Batch, err
+}
+
+// Exec implements driver.Conn interface.
+func (w *connWrapper) Exec(ctx context.Context, query string, args ...any) error {
+	_, err := w.intercept(ctx, query, args, func(ctx context.Context, q *Query) (*QueryResult, error) {
+		return nil, w.Conn.Exec(ctx, q.SQL, q.Args...)
+	})
+	return err
+}
+
+// AsyncInsert implements driver.Conn interface.
+func (w *connWrapper) AsyncInsert(ctx context.Context, query string, wait bool, args ...any) error {
+	_, err := w.intercept(ctx, query, args, func(ctx context.Context, q *Query) (*QueryResult, error) {
+		return nil, w.Conn.AsyncInsert(ctx, q.SQL, wait, q.Args...)
+	})
+	return err
+}
+
+// errRow is a row failed by an interceptor.
+type errRow struct {
+	err error
+}
+
+// Err implements driver.Row interface.
+func (r *errRow) Err() error {
+	return r.err
+}
+
+// Scan implements driver.Row interface.
+func (r *errRow) Scan(dest ...any) error {
+	return r.err
+}
+
+// ScanStruct implements driver.Row interface.
+func (r *errRow) ScanStruct(dest any) error {
+	return r.err
+}
+`
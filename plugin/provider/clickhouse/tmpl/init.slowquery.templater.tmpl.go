@@ -0,0 +1,90 @@
+package tmpl
+
+// SlowQueryTemplate is the template for the slow query detection.
+// This is included in the init template.
+const SlowQueryTemplate = `
+// SlowQuery is a query slower than Config.SlowQueryThreshold.
+type SlowQuery struct {
+	Table     string
+	Operation Operation
+	SQL       string
+	Args      []any
+	// Duration is how long the query took. For Query it is the time until the first block.
+	Duration time.Duration
+	// Err is the error of the query, if any.
+	Err error
+	// Plan is the output of EXPLAIN indexes=1 when Config.ExplainSlowQueries is set.
+	Plan string
+	// ExplainErr is the error of the EXPLAIN, if any. Only SELECT queries can be explained.
+	ExplainErr error
+}
+
+// SlowQueryHandler is called with the queries slower than Config.SlowQueryThreshold.
+// To explain a Query or PrepareBatch, the handler is called from another goroutine
+// once a connection is free, because the rows and batches hold theirs until they are done.
+type SlowQueryHandler func(ctx context.Context, query *SlowQuery)
+
+// slowQueries returns exec reporting the queries slower than the threshold of the config to its SlowQueryHandler.
+// The plan is explained on conn, the connection running the query.
+func (c *Config) slowQueries(conn driver.Conn, exec QueryHandler) QueryHandler {
+	if c.SlowQueryHandler == nil || c.SlowQueryThreshold <= 0 {
+		return exec
+	}
+
+	return func(ctx context.Context, query *Query) (*QueryResult, error) {
+		start := time.Now()
+		result, err := exec(ctx, query)
+		duration := time.Since(start)
+		if duration < c.SlowQueryThreshold {
+			return result, err
+		}
+
+		slow := &SlowQuery{
+			Table:     query.Table,
+			Operation: query.Operation,
+			SQL:       query.SQL,
+			Args:      query.Args,
+			Duration:  duration,
+			Err:       err,
+		}
+		switch {
+		case !c.ExplainSlowQueries:
+		case result == nil || (result.Rows == nil && result.Batch == nil):
+			slow.Plan, slow.ExplainErr = explain(ctx, conn, query)
+		default:
+			// the rows and batches hold their connection until they are done, explain once the pool has a free one.
+			go func() {
+				slow.Plan, slow.ExplainErr = explain(context.Background(), conn, query)
+				c.SlowQueryHandler(ctx, slow)
+			}()
+			return result, err
+		}
+		c.SlowQueryHandler(ctx, slow)
+
+		return result, err
+	}
+}
+
+// explain returns the plan of the query with the indexes it uses. EXPLAIN does not run the query.
+func explain(ctx context.Context, conn driver.Conn, query *Query) (string, error) {
+	rows, err := conn.Query(ctx, "EXPLAIN indexes=1 "+query.SQL, query.Args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to explain query: %w", err)
+	}
+	defer rows.Close()
+
+	var plan strings.Builder
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", fmt.Errorf("failed to scan plan: %w", err)
+		}
+		plan.WriteString(line + "\n")
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to explain query: %w", err)
+	}
+
+	return plan.String(), nil
+}
+`
@@ -40,6 +40,25 @@ const InitStatementTemplate = `
 
 {{ template "transaction" . }}
 
+//
+// Query interceptors.
+//
+
+{{ template "interceptors" . }}
+
+//
+// Slow queries.
+//
+
+{{ template "slowquery" . }}
+{{ if .Otel }}
+//
+// OpenTelemetry tracing.
+//
+
+{{ template "tracing" . }}
+{{ end }}
+
 //
 // Options.
 // 
@@ -63,6 +82,8 @@ type Options struct {
 	uniqField string
 	// waitAsyncInsert is the wait flag. wait_for_async_insert = 1
 	waitAsyncInsert bool
+	// preloads are the relations to load with the found rows.
+	preloads []Relation
 }
 
 // WithWaitAsyncInsert sets the waitAsyncInsert flag.
@@ -87,6 +108,13 @@ func WithUniqField(field string) Option {
 	}
 }
 
+// WithPreload loads the given relations together with the found rows.
+func WithPreload(relations ...Relation) Option {
+	return func(o *Options) {
+		o.preloads = append(o.preloads, relations...)
+	}
+}
+
 // FilterApplier is a condition filters.
 type FilterApplier interface {
 	Apply(query sq.SelectBuilder) sq.SelectBuilder
@@ -110,6 +138,12 @@ type QueryBuilder struct {
 	sortOptions  []FilterApplier
 	// pagination is the pagination.
 	pagination    *Pagination
+	// preloads are the relations to load with the found rows.
+	preloads      []Relation
+	// limitPerParent limits the related rows loaded for every parent row.
+	limitPerParent *uint64
+	// partitionBy is the column of the parent row, set by the relation loaders.
+	partitionBy string
 	// customFilters are the custom filters.
 	customFilters []struct {
 		filter CustomFilter
@@ -199,6 +233,20 @@ func (b *QueryBuilder) WithPagination(pagination *Pagination) *QueryBuilder {
 	return b
 }
 
+// Preload adds relations to load together with the found rows.
+// Each relation is loaded with a single query per level, whatever the number of rows.
+func (b *QueryBuilder) Preload(relations ...Relation) *QueryBuilder {
+	b.preloads = append(b.preloads, relations...)
+	return b
+}
+
+// LimitPerParent limits the related rows loaded for every parent row, e.g. the latest 3 posts of every user.
+// It is supported by the LoadBatch relation loaders and Preload, the sort options order the rows of every parent.
+func (b *QueryBuilder) LimitPerParent(limit uint64) *QueryBuilder {
+	b.limitPerParent = &limit
+	return b
+}
+
 // WithSettings sets the ClickHouse query settings.
 func (b *QueryBuilder) WithSettings(settings map[string]interface{}) *QueryBuilder {
 	if b.settings == nil {
@@ -264,6 +312,63 @@ func SettingBuilder(key string, value interface{}) *QueryBuilder {
 	return NewQueryBuilder().WithSetting(key, value)
 }
 
+// PreloadBuilder is a helper function to create a new query builder with preloads.
+func PreloadBuilder(relations ...Relation) *QueryBuilder {
+	return NewQueryBuilder().Preload(relations...)
+}
+
+// LimitPerParentBuilder is a helper function to create a new query builder with a limit per parent row.
+func LimitPerParentBuilder(limit uint64) *QueryBuilder {
+	return NewQueryBuilder().LimitPerParent(limit)
+}
+
+// partitionBuilder returns a query builder which counts the limit per parent row on the column.
+func partitionBuilder(column string) *QueryBuilder {
+	builder := NewQueryBuilder()
+	builder.partitionBy = column
+	return builder
+}
+
+// Relation identifies a relation of a table, e.g. UserRelPosts.
+// Use Then to preload nested relations and With to filter or sort the related rows.
+type Relation struct {
+	// name is the relation name, "<Structure>.<Field>".
+	name string
+	// builders are applied when the related rows are loaded.
+	builders []*QueryBuilder
+}
+
+// Then returns a copy of the relation that also preloads the given relations of the related rows.
+func (r Relation) Then(relations ...Relation) Relation {
+	return r.With(PreloadBuilder(relations...))
+}
+
+// With returns a copy of the relation that loads the related rows with the given builders.
+func (r Relation) With(builders ...*QueryBuilder) Relation {
+	r.builders = append(append([]*QueryBuilder{}, r.builders...), builders...)
+	return r
+}
+
+// String returns the relation name.
+func (r Relation) String() string {
+	return r.name
+}
+
+// mergeRelations merges relations with the same name, so each relation is loaded once.
+func mergeRelations(relations []Relation) []Relation {
+	merged := make([]Relation, 0, len(relations))
+	index := make(map[string]int, len(relations))
+	for _, r := range relations {
+		if i, ok := index[r.name]; ok {
+			merged[i] = merged[i].With(r.builders...)
+			continue
+		}
+		index[r.name] = len(merged)
+		merged = append(merged, r)
+	}
+	return merged
+}
+
 // Pagination is the pagination.
 type Pagination struct {
 	// limit is the limit.
@@ -385,6 +490,25 @@ type Config struct {
 
 	QueryLogMethod    func(ctx context.Context, table string, query string, args ...interface{})
 	ErrorLogMethod    func(ctx context.Context, err error, message string)
+
+	// Interceptors wrap every query, the first one being the outermost.
+	Interceptors []QueryInterceptor
+	// SlowQueryThreshold is the duration from which a query is reported to SlowQueryHandler.
+	// Zero reports no query.
+	SlowQueryThreshold time.Duration
+	// SlowQueryHandler is called with the queries slower than SlowQueryThreshold.
+	SlowQueryHandler SlowQueryHandler
+	// ExplainSlowQueries attaches the plan of the slow queries, explained on the connection that ran them.
+	ExplainSlowQueries bool
+{{- if .Otel }}
+	// TracerProvider creates the spans of the queries and transactions.
+	// Defaults to the global provider.
+	TracerProvider trace.TracerProvider
+{{- end }}
+
+	// Clock returns the current time for auto_create_time and auto_update_time fields.
+	// Defaults to time.Now.
+	Clock func() time.Time
 }
 
 // {{ storageName }} is the interface for the {{ storageName }}.
@@ -652,8 +776,10 @@ type QueryExecer interface {
 // This is included in the init template.
 const ErrorsTemplate = `
 var (
-	// ErrNotFound is returned when a record is not found.
+	// ErrRowNotFound is returned when a record is not found.
 	ErrRowNotFound = fmt.Errorf("row not found")
+	// ErrNotFound is returned when a record is not found.
+	ErrNotFound = ErrRowNotFound
 	// ErrNoTransaction is returned when a transaction is not provided.
 	ErrNoTransaction = fmt.Errorf("no transaction provided")
 	// ErrRowAlreadyExist is returned when a row already exist.
@@ -661,4 +787,73 @@ var (
 	// ErrModelIsNil is returned when a relation model is nil.
 	ErrModelIsNil = fmt.Errorf("model is nil")
 )
+
+// ErrUniqueViolation is returned when a write violates a unique constraint or the primary key.
+// errors.Is(err, ErrRowAlreadyExist) holds for it as well.
+type ErrUniqueViolation struct {
+	// Constraint is the name of the violated constraint, empty if the database does not report it.
+	Constraint string
+	// Columns are the columns of the violated constraint, empty if the database does not report them.
+	Columns []string
+	// Err is the driver error.
+	Err error
+}
+
+func (e *ErrUniqueViolation) Error() string { return fmt.Sprintf("unique violation: %v", e.Err) }
+func (e *ErrUniqueViolation) Unwrap() error { return e.Err }
+func (e *ErrUniqueViolation) Is(target error) bool { return target == ErrRowAlreadyExist }
+
+// ErrForeignKeyViolation is returned when a write references a missing row or deletes a referenced one.
+type ErrForeignKeyViolation struct {
+	// Constraint is the name of the violated constraint, empty if the database does not report it.
+	Constraint string
+	// Columns are the referencing columns, empty if the database does not report them.
+	Columns []string
+	// Err is the driver error.
+	Err error
+}
+
+func (e *ErrForeignKeyViolation) Error() string { return fmt.Sprintf("foreign key violation: %v", e.Err) }
+func (e *ErrForeignKeyViolation) Unwrap() error { return e.Err }
+
+// ErrCheckViolation is returned when a write violates a check constraint.
+type ErrCheckViolation struct {
+	// Constraint is the name of the violated constraint, empty if the database does not report it.
+	Constraint string
+	// Err is the driver error.
+	Err error
+}
+
+func (e *ErrCheckViolation) Error() string { return fmt.Sprintf("check violation: %v", e.Err) }
+func (e *ErrCheckViolation) Unwrap() error { return e.Err }
+
+// ErrSerialization is returned when a transaction conflicts with a concurrent one and may be retried.
+type ErrSerialization struct {
+	// Err is the driver error.
+	Err error
+}
+
+func (e *ErrSerialization) Error() string { return fmt.Sprintf("serialization failure: %v", e.Err) }
+func (e *ErrSerialization) Unwrap() error { return e.Err }
+
+// MapError maps a ClickHouse exception to ErrCheckViolation and sql.ErrNoRows to ErrNotFound.
+// ClickHouse has no unique or foreign key constraints, so the other error types are never returned.
+// Other errors are returned as is.
+func MapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) && exception.Code == errChViolatedConstraint {
+		return &ErrCheckViolation{Err: err}
+	}
+	return err
+}
+
+// errChViolatedConstraint is the VIOLATED_CONSTRAINT exception code of ClickHouse.
+const errChViolatedConstraint = 469
 `
@@ -135,7 +135,7 @@ const TableConditionFilters = `
   {{ range $field := $fieldMess.GetField }}
    {{- if not ($field | isRelation) }}
    {{- if not ($field | isJSON) }}
-   {{- if ($field | isCurrentOptional) }}
+   {{- if and ($field | isCurrentOptional) (not ($field | isValidNull)) }}
 	// {{ $fieldMess.GetName | camelCase }}{{ $field.GetName | camelCase }}IsNull checks if the {{ $field.GetName }} is NULL.
     func {{ $fieldMess.GetName | camelCase }}{{ $field.GetName | camelCase }}IsNull() FilterApplier {
       return IsNullCondition{Field: "{{ $field.GetName }}"}
@@ -275,7 +275,17 @@ const TableCountMethodTemplate = `
 
 const TableFindOneMethodTemplate = `
 // FindOne finds a single {{ structureName }} based on the provided options.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) FindOne(ctx context.Context, builders ...*QueryBuilder) (*{{structureName}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.FindOne")
+	res, err := t.doFindOne(ctx, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doFindOne is FindOne without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doFindOne{{ else }}FindOne{{ end }}(ctx context.Context, builders ...*QueryBuilder) (*{{structureName}}, error) {
 	// Use findMany but limit the results to 1
 	builders = append(builders, LimitBuilder(1))
 	results, err := t.FindMany(ctx, builders...)
@@ -293,12 +303,28 @@ func (t *{{ storageName | lowerCamelCase }}) FindOne(ctx context.Context, builde
 
 const TableFindManyMethodTemplate = `
 // FindMany finds multiple {{ structureName }} based on the provided options.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) FindMany(ctx context.Context, builders ...*QueryBuilder) ([]*{{structureName}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.FindMany")
+	res, err := t.doFindMany(ctx, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doFindMany is FindMany without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doFindMany{{ else }}FindMany{{ end }}(ctx context.Context, builders ...*QueryBuilder) ([]*{{structureName}}, error) {
 	// build query
 	query := t.queryBuilder.Select(t.Columns()...).From(t.TableName())
 
 	// set default options
 	options := &Options{}
+
+	// collect the limit per parent row of the relation loaders
+	var limitPerParent *uint64
+	var partitionBy string
+	var sortOptions []FilterApplier
+	var paginated bool
 	
 	// collect settings from all builders
 	allSettings := make(map[string]interface{})
@@ -328,6 +354,7 @@ func (t *{{ storageName | lowerCamelCase }}) FindMany(ctx context.Context, build
 
 		// apply pagination
 		if builder.pagination != nil {
+			paginated = true
 			if builder.pagination.limit != nil {
 				query = query.Limit(*builder.pagination.limit)
 			}
@@ -340,6 +367,14 @@ func (t *{{ storageName | lowerCamelCase }}) FindMany(ctx context.Context, build
 		for _, option := range builder.sortOptions {
 			query = option.Apply(query)
 		}
+		sortOptions = append(sortOptions, builder.sortOptions...)
+
+		if builder.limitPerParent != nil {
+			limitPerParent = builder.limitPerParent
+		}
+		if builder.partitionBy != "" {
+			partitionBy = builder.partitionBy
+		}
 
 	    // apply options
 		for _, o := range builder.options {
@@ -350,6 +385,20 @@ func (t *{{ storageName | lowerCamelCase }}) FindMany(ctx context.Context, build
 		for k, v := range builder.settings {
 			allSettings[k] = v
 		}
+
+		// collect relations to preload
+		options.preloads = append(options.preloads, builder.preloads...)
+	}
+
+	// keep at most limitPerParent rows for every parent row
+	if limitPerParent != nil {
+		if partitionBy == "" {
+			return nil, fmt.Errorf("limit per parent is only supported by relation loaders")
+		}
+		if paginated {
+			return nil, fmt.Errorf("limit per parent can't be combined with limit or offset")
+		}
+		query = query.Suffix(fmt.Sprintf("LIMIT %d BY %s", *limitPerParent, partitionBy))
 	}
 
 	// execute query
@@ -392,36 +441,72 @@ func (t *{{ storageName | lowerCamelCase }}) FindMany(ctx context.Context, build
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("failed to iterate over rows: %w", err)
 	}
+
+	// preload relations
+	if len(results) > 0 && len(options.preloads) > 0 {
+		if err := t.preload(ctx, results, options.preloads); err != nil {
+			return nil, err
+		}
+	}
 	
 	return results, nil
 }
 `
 
-const TableGetByIDMethodTemplate = ``
+const TableGetByIDMethodTemplate = `
+{{- if (hasCompositePrimaryKey) }}
+// FindByKey retrieves a {{ structureName }} by its key.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) FindByKey(ctx context.Context, key {{ structureName }}Key, opts ...Option) (*{{ structureName }}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.FindByKey")
+	res, err := t.doFindByKey(ctx, key, opts...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doFindByKey is FindByKey without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doFindByKey{{ else }}FindByKey{{ end }}(ctx context.Context, key {{ structureName }}Key, opts ...Option) (*{{ structureName }}, error) {
+	builder := NewQueryBuilder()
+	{
+		builder.WithFilter({{ structureName }}KeyEq(key))
+		builder.WithOptions(opts...)
+	}
+
+	// Use FindOne to get a single result
+	model, err := t.FindOne(ctx, builder)
+	if err != nil {
+		return nil, fmt.Errorf("find one {{ structureName }}: %w", err)
+	}
+
+	return model, nil
+}
+{{- end }}
+`
 
 const TableRawQueryMethodTemplate = `
 // Select executes a raw query and returns the result.
 func (t *{{ storageName | lowerCamelCase }}) Select(ctx context.Context, query string, dest any, args ...any) error {
 	t.logQuery(ctx, query, args...)
-	return t.DB().Select(ctx, dest, query, args...)
+	return t.DB().Select(withOperation(ctx, OperationRaw), dest, query, args...)
 }
 
 // Exec executes a raw query and returns the result.
 func (t *{{ storageName | lowerCamelCase }}) Exec(ctx context.Context, query string, args ...interface{}) error {
 	t.logQuery(ctx, query, args...)
-	return t.DB().Exec(ctx, query, args...)
+	return t.DB().Exec(withOperation(ctx, OperationRaw), query, args...)
 }
 
 // QueryRow executes a raw query and returns the result.
 func (t *{{ storageName | lowerCamelCase }}) QueryRow(ctx context.Context, query string, args ...interface{}) driver.Row {
 	t.logQuery(ctx, query, args...)
-	return t.DB().QueryRow(ctx, query, args...)
+	return t.DB().QueryRow(withOperation(ctx, OperationRaw), query, args...)
 }
 
 // QueryRows executes a raw query and returns the result.
 func (t *{{ storageName | lowerCamelCase }}) QueryRows(ctx context.Context, query string, args ...interface{}) (driver.Rows, error) {
 	t.logQuery(ctx, query, args...)
-	return t.DB().Query(ctx, query, args...)
+	return t.DB().Query(withOperation(ctx, OperationRaw), query, args...)
 }
 
 // Conn returns the connection.
@@ -455,11 +540,48 @@ func (t *{{ structureName }}) ScanRow(row driver.Row) error {
 		{{- end }}
 		{{- end }}
 	)
-}`
+}
+{{- if (hasCompositePrimaryKey) }}
+
+// {{ structureName }}Key is the composite primary key of the "{{ tableName }}" table.
+type {{ structureName }}Key struct {
+{{- range $field := getPrimaryKeys }}
+	{{ $field | fieldName }} {{ $field | fieldType }}
+{{- end }}
+}
+
+// Key returns the composite primary key of the {{ structureName }}.
+func (t *{{ structureName }}) Key() {{ structureName }}Key {
+	return {{ structureName }}Key{
+	{{- range $field := getPrimaryKeys }}
+		{{ $field | fieldName }}: t.{{ $field | fieldName }},
+	{{- end }}
+	}
+}
+
+// {{ structureName }}KeyEq returns a condition that matches the row with the given key.
+func {{ structureName }}KeyEq(key {{ structureName }}Key) FilterApplier {
+	return And(
+	{{- range $field := getPrimaryKeys }}
+		Eq("{{ $field.GetName }}", key.{{ $field | fieldName }}),
+	{{- end }}
+	)
+}
+{{- end }}`
 
 const TableOriginalBatchCreateMethodTemplate = `
 // OriginalBatchCreate creates multiple {{ structureName }} records in a single batch.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) OriginalBatchCreate(ctx context.Context, models []*{{structureName}}, opts ...Option) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.OriginalBatchCreate")
+	err := t.doOriginalBatchCreate(ctx, models, opts...)
+	endSpan(span, err)
+	return err
+}
+
+// doOriginalBatchCreate is OriginalBatchCreate without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doOriginalBatchCreate{{ else }}OriginalBatchCreate{{ end }}(ctx context.Context, models []*{{structureName}}, opts ...Option) error {
 	if len(models) == 0 {
 		return fmt.Errorf("no models to insert")
 	}
@@ -491,6 +613,12 @@ func (t *{{ storageName | lowerCamelCase }}) OriginalBatchCreate(ctx context.Con
 			return fmt.Errorf("model is nil: %w", ErrModelIsNil)
 		}
 
+		{{- if (hasAutoTime) }}
+
+		// fill automatic time fields
+		t.fillAutoTime(model)
+		{{- end }}
+
 		{{- range $index, $field := fields }}
 		{{- if not ($field | isRelation) }}
 		{{- if ($field | isRepeated) }}
@@ -536,7 +664,7 @@ func (t *{{ storageName | lowerCamelCase }}) OriginalBatchCreate(ctx context.Con
 
 	rows, err := t.DB().Query(ctx, sqlQuery, args...)
 	if err != nil {
-		return fmt.Errorf("failed to execute bulk insert: %w", err)
+		return fmt.Errorf("failed to execute bulk insert: %w", MapError(err))
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
@@ -554,7 +682,17 @@ func (t *{{ storageName | lowerCamelCase }}) OriginalBatchCreate(ctx context.Con
 
 const TableBatchCreateMethodTemplate = `
 // BatchCreate creates multiple {{ structureName }} records in a single batch.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) BatchCreate(ctx context.Context, models []*{{structureName}}, opts ...Option) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.BatchCreate")
+	err := t.doBatchCreate(ctx, models, opts...)
+	endSpan(span, err)
+	return err
+}
+
+// doBatchCreate is BatchCreate without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doBatchCreate{{ else }}BatchCreate{{ end }}(ctx context.Context, models []*{{structureName}}, opts ...Option) error {
 	if len(models) == 0 {
 		return fmt.Errorf("no models to insert")
 	}
@@ -578,6 +716,12 @@ func (t *{{ storageName | lowerCamelCase }}) BatchCreate(ctx context.Context, mo
 			return fmt.Errorf("one of the models is nil")
 		}
 
+		{{- if (hasAutoTime) }}
+
+		// fill automatic time fields
+		t.fillAutoTime(model)
+		{{- end }}
+
 		{{- range $index, $field := fields }}
 		{{- if not ($field | isRelation) }}
 		{{- if ($field | isRepeated) }}
@@ -619,7 +763,7 @@ func (t *{{ storageName | lowerCamelCase }}) BatchCreate(ctx context.Context, mo
 	}
 
 	if err := batch.Send(); err != nil {
-		return fmt.Errorf("failed to execute batch insert: %w", err)
+		return fmt.Errorf("failed to execute batch insert: %w", MapError(err))
 	}
 
 	return nil
@@ -627,7 +771,17 @@ func (t *{{ storageName | lowerCamelCase }}) BatchCreate(ctx context.Context, mo
 
 const TableCreateAsyncMethodTemplate = `
 // AsyncCreate asynchronously inserts a new {{ structureName }}.
-func (t *{{ storageName | lowerCamelCase }}) AsyncCreate(ctx context.Context, model *{{structureName}}, opts ...Option) error { 
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) AsyncCreate(ctx context.Context, model *{{structureName}}, opts ...Option) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.AsyncCreate")
+	err := t.doAsyncCreate(ctx, model, opts...)
+	endSpan(span, err)
+	return err
+}
+
+// doAsyncCreate is AsyncCreate without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doAsyncCreate{{ else }}AsyncCreate{{ end }}(ctx context.Context, model *{{structureName}}, opts ...Option) error { 
 	if model == nil {
 		return fmt.Errorf("model is nil")
 	}
@@ -638,6 +792,12 @@ func (t *{{ storageName | lowerCamelCase }}) AsyncCreate(ctx context.Context, mo
 		o(options)
 	}
 
+	{{- if (hasAutoTime) }}
+
+	// fill automatic time fields
+	t.fillAutoTime(model)
+	{{- end }}
+
 	{{- range $index, $field := fields }}
 	{{- if not ($field | isRelation) }}
 	{{- if ($field | isRepeated) }}
@@ -693,7 +853,7 @@ func (t *{{ storageName | lowerCamelCase }}) AsyncCreate(ctx context.Context, mo
 	t.logQuery(ctx, sqlQuery, args...)
 
 	if err := t.DB().AsyncInsert(ctx, sqlQuery, options.waitAsyncInsert, args...); err != nil {
-		return fmt.Errorf("failed to asynchronously create {{ structureName }}: %w", err)
+		return fmt.Errorf("failed to asynchronously create {{ structureName }}: %w", MapError(err))
 	}
 
 	{{- range $index, $field := fields }}
@@ -729,7 +889,17 @@ func (t *{{ storageName | lowerCamelCase }}) AsyncCreate(ctx context.Context, mo
 
 const TableCreateMethodTemplate = `
 // Create creates a new {{ structureName }}.
-func (t *{{ storageName | lowerCamelCase }}) Create(ctx context.Context, model *{{structureName}}, opts ...Option) error { 
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Create(ctx context.Context, model *{{structureName}}, opts ...Option) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Create")
+	err := t.doCreate(ctx, model, opts...)
+	endSpan(span, err)
+	return err
+}
+
+// doCreate is Create without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doCreate{{ else }}Create{{ end }}(ctx context.Context, model *{{structureName}}, opts ...Option) error { 
 	if model == nil {
 		return fmt.Errorf("model is nil")
 	}
@@ -740,6 +910,12 @@ func (t *{{ storageName | lowerCamelCase }}) Create(ctx context.Context, model *
 		o(options)
 	}
 
+	{{- if (hasAutoTime) }}
+
+	// fill automatic time fields
+	t.fillAutoTime(model)
+	{{- end }}
+
 	{{- range $index, $field := fields }}
 	{{- if not ($field | isRelation) }}
 	{{- if ($field | isRepeated) }}
@@ -796,7 +972,7 @@ func (t *{{ storageName | lowerCamelCase }}) Create(ctx context.Context, model *
 
 	err = t.DB().Exec(ctx, sqlQuery, args...)
 	if err != nil {
-		return fmt.Errorf("failed to create {{ structureName }}: %w", err)
+		return fmt.Errorf("failed to create {{ structureName }}: %w", MapError(err))
 	}
 
 	{{- range $index, $field := fields }}
@@ -848,6 +1024,9 @@ type {{structureName}}CRUDOperations interface {
 type {{structureName}}SearchOperations interface {
 	FindMany(ctx context.Context, builder ...*QueryBuilder) ([]*{{structureName}}, error)
 	FindOne(ctx context.Context, builders ...*QueryBuilder) (*{{structureName}}, error)
+	{{- if (hasCompositePrimaryKey) }}
+	FindByKey(ctx context.Context, key {{ structureName }}Key, opts ...Option) (*{{ structureName }}, error)
+	{{- end }}
 }
 
 type {{structureName}}Settings interface {
@@ -871,6 +1050,11 @@ type {{structureName}}RelationLoading interface {
 	LoadBatch{{ $field | pluralFieldName }} (ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) error
 	{{- end }}
 	{{- end }}
+	{{- range $index, $field := fields }}
+	{{- if and ($field | isRelation) ($field | isRepeated) (not ($field | isCompositeRelation)) }}
+	Load{{ $field | pluralFieldName }}Count (ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) (map[{{ $field | relationKeyType }}]int64, error)
+	{{- end }}
+	{{- end }}
 }
 
 // {{structureName}}RawQueryOperations is an interface for executing raw queries.
@@ -919,6 +1103,34 @@ func (t *{{ storageName | lowerCamelCase }}) logError(ctx context.Context, err e
 	}
 }
 
+{{- if (hasAutoTime) }}
+
+// now returns the current time from the configured clock.
+func (t *{{ storageName | lowerCamelCase }}) now() time.Time {
+	if t.config.Clock != nil {
+		return t.config.Clock()
+	}
+	return time.Now()
+}
+
+// fillAutoTime fills the automatic time fields of the {{ structureName }} that are not set yet.
+func (t *{{ storageName | lowerCamelCase }}) fillAutoTime(model *{{ structureName }}) {
+	now := t.now()
+	{{- range $index, $field := fields }}
+	{{- if or ($field | isAutoCreateTime) ($field | isAutoUpdateTime) }}
+	if {{ autoTimeIsZero $field (printf "model.%s" ($field | fieldName)) }} {
+		{{- if ($field | findPointer) }}
+		{{ $field | fieldName | lowerCamelCase }} := {{ $field | autoTimeValue }}
+		model.{{ $field | fieldName }} = &{{ $field | fieldName | lowerCamelCase }}
+		{{- else }}
+		model.{{ $field | fieldName }} = {{ $field | autoTimeValue }}
+		{{- end }}
+	}
+	{{- end }}
+	{{- end }}
+}
+{{- end }}
+
 // applyPrewhere applies ClickHouse PREWHERE conditions to the query.
 // PREWHERE is executed before WHERE and reads only the specified columns,
 // which can significantly improve query performance.
@@ -1023,7 +1235,11 @@ func (t *{{ storageName | lowerCamelCase }}) Columns() []string {
 }
 
 // DB returns the underlying DB. This is useful for doing transactions.
+// With interceptors configured, the queries run through them.
 func (t *{{ storageName | lowerCamelCase }}) DB() QueryExecer {
+	if t.config.intercepted() {
+		return &connWrapper{Conn: t.config.DB, config: t.config, table: t.TableName()}
+	}
 	return t.config.DB
 }
 
@@ -1040,11 +1256,26 @@ func (t *{{ storageName | lowerCamelCase }}) SetQueryBuilder(builder sq.Statemen
 {{- range $index, $field := fields }}
 {{- if and ($field | isRelation) }}
 // Load{{ $field | pluralFieldName }} loads the {{ $field | pluralFieldName }} relation.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) Load{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, builders ...*QueryBuilder) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Load{{ $field | pluralFieldName }}")
+	err := t.doLoad{{ $field | pluralFieldName }}(ctx, model, builders...)
+	endSpan(span, err)
+	return err
+}
+
+// doLoad{{ $field | pluralFieldName }} is Load{{ $field | pluralFieldName }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doLoad{{ $field | pluralFieldName }}{{ else }}Load{{ $field | pluralFieldName }}{{ end }}(ctx context.Context, model *{{structureName}}, builders ...*QueryBuilder) error {
 	if model == nil {
 		return fmt.Errorf("model is nil: %w", ErrModelIsNil)
 	}
 
+	{{- if ($field | isCompositeRelation) }}
+
+	return t.LoadBatch{{ $field | pluralFieldName }}(ctx, []*{{structureName}}{model}, builders...)
+	{{- else }}
+
 	// New{{ $field | relationStorageName }} creates a new {{ $field | relationStorageName }}.
 	s, err := New{{ $field | relationStorageName }}(t.config)
 	if err != nil {
@@ -1080,6 +1311,7 @@ func (t *{{ storageName | lowerCamelCase }}) Load{{ $field | pluralFieldName }}(
 		model.{{ $field | fieldName }} = relationModel
 	{{- end }}
 	return nil
+	{{- end }}
 }
 {{- end }}
 {{- end }}
@@ -1087,7 +1319,74 @@ func (t *{{ storageName | lowerCamelCase }}) Load{{ $field | pluralFieldName }}(
 {{- range $index, $field := fields }}
 {{- if and ($field | isRelation) }}
 // LoadBatch{{ $field | pluralFieldName }} loads the {{ $field | pluralFieldName }} relation.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) LoadBatch{{ $field | pluralFieldName }}(ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.LoadBatch{{ $field | pluralFieldName }}")
+	err := t.doLoadBatch{{ $field | pluralFieldName }}(ctx, items, builders...)
+	endSpan(span, err)
+	return err
+}
+
+// doLoadBatch{{ $field | pluralFieldName }} is LoadBatch{{ $field | pluralFieldName }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doLoadBatch{{ $field | pluralFieldName }}{{ else }}LoadBatch{{ $field | pluralFieldName }}{{ end }}(ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) error {
+	{{- if ($field | isCompositeRelation) }}
+	conditions := make([]FilterApplier, 0, len(items))
+	seen := make(map[[{{ len ($field | relationFields) }}]interface{}]bool, len(items))
+	for _, item := range items {
+		key := [{{ len ($field | relationFields) }}]interface{}{ {{- range $i, $f := ($field | relationFields) }}{{ if $i }}, {{ end }}item.{{ $f | fieldName }}{{ end -}} }
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		conditions = append(conditions, And(
+			{{- range $i, $ref := ($field | relationReferences) }}
+			Eq("{{ $ref.GetName }}", key[{{ $i }}]),
+			{{- end }}
+		))
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	// New{{ $field | relationStorageName }} creates a new {{ $field | relationStorageName }}.
+	s, err := New{{ $field | relationStorageName }}(t.config)
+	if err != nil {
+		return fmt.Errorf("failed to create {{ $field | relationStorageName }}: %w", err)
+	}
+
+	// Add the filter for the relation, a row matches one of the keys
+	builders = append(builders, FilterBuilder(Or(conditions...)), partitionBuilder("{{ range $i, $ref := ($field | relationReferences) }}{{ if $i }}, {{ end }}{{ $ref.GetName }}{{ end }}"))
+
+	results, err := s.FindMany(ctx, builders...)
+	if err != nil {
+		return fmt.Errorf("failed to find many {{ $field | relationStorageName }}: %w", err)
+	}
+
+	{{- if ($field | isRepeated) }}
+	resultMap := make(map[[{{ len ($field | relationFields) }}]interface{}][]*{{ $field | relationStructureName }})
+	{{- else }}
+	resultMap := make(map[[{{ len ($field | relationFields) }}]interface{}]*{{ $field | relationStructureName }})
+	{{- end }}
+	for _, result := range results {
+		key := [{{ len ($field | relationFields) }}]interface{}{ {{- range $i, $ref := ($field | relationReferences) }}{{ if $i }}, {{ end }}result.{{ $ref | fieldName }}{{ end -}} }
+		{{- if ($field | isRepeated) }}
+		resultMap[key] = append(resultMap[key], result)
+		{{- else }}
+		resultMap[key] = result
+		{{- end }}
+	}
+
+	// Assign {{ $field | relationStructureName }} to items
+	for _, item := range items {
+		key := [{{ len ($field | relationFields) }}]interface{}{ {{- range $i, $f := ($field | relationFields) }}{{ if $i }}, {{ end }}item.{{ $f | fieldName }}{{ end -}} }
+		if v, ok := resultMap[key]; ok {
+			item.{{ $field | fieldName }} = v
+		}
+	}
+
+	return nil
+	{{- else }}
 	requestItems := make([]interface{}, 0, len(items))
 	for _, item := range items {
 		{{- if ($field | isOptional) }}
@@ -1103,6 +1402,9 @@ func (t *{{ storageName | lowerCamelCase }}) LoadBatch{{ $field | pluralFieldNam
 			requestItems = append(requestItems, item.{{ $field | getFieldID }})
 		{{- end }}
 	}
+	if len(requestItems) == 0 {
+		return nil
+	}
 
 	// New{{ $field | relationStorageName }} creates a new {{ $field | relationStorageName }}.
 	s, err := New{{ $field | relationStorageName }}(t.config)
@@ -1111,14 +1413,7 @@ func (t *{{ storageName | lowerCamelCase }}) LoadBatch{{ $field | pluralFieldNam
 	}
 
 	// Add the filter for the relation
-	{{- if ($field | isOptional) }}
-		// Ensure that requestItems are not empty before adding the builder
-		if len(requestItems) > 0 {
-			builders = append(builders, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}In(requestItems...)))
-		}
-	{{- else }}
-		builders = append(builders, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}In(requestItems...)))
-	{{- end }}
+	builders = append(builders, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}In(requestItems...)), partitionBuilder("{{ ($field | relation).Reference }}"))
 
 	results, err := s.FindMany(ctx, builders...)
 	if err != nil {
@@ -1131,10 +1426,18 @@ func (t *{{ storageName | lowerCamelCase }}) LoadBatch{{ $field | pluralFieldNam
 	resultMap := make(map[interface{}]*{{ $field | relationStructureName }})
 	{{- end }}
 	for _, result := range results {
+		{{- if ($field | isRefOptional) }}
+		if result.{{ $field | getRefID }} == nil {
+			continue
+		}
+		key := *result.{{ $field | getRefID }}
+		{{- else }}
+		key := result.{{ $field | getRefID }}
+		{{- end }}
 		{{- if ($field | isRepeated) }}
-		resultMap[result.{{ $field | getRefID }}] = append(resultMap[result.{{ $field | getRefID }}], result)
+		resultMap[key] = append(resultMap[key], result)
 		{{- else }}
-		resultMap[result.{{ $field | getRefID }}] = result
+		resultMap[key] = result
 		{{- end }}
 	}
 
@@ -1158,7 +1461,119 @@ func (t *{{ storageName | lowerCamelCase }}) LoadBatch{{ $field | pluralFieldNam
 	}
 
 	return nil
+	{{- end }}
 }
 {{- end }}
 {{- end }}
+
+{{- range $index, $field := fields }}
+{{- if and ($field | isRelation) ($field | isRepeated) (not ($field | isCompositeRelation)) }}
+{{- $rel := ($field | relation) }}
+
+// Load{{ $field | pluralFieldName }}Count counts the {{ $field | pluralFieldName }} of every item with one GROUP BY query.
+// The filters of the builders apply to the related rows, items without related rows have no entry.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Load{{ $field | pluralFieldName }}Count(ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) (map[{{ $field | relationKeyType }}]int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Load{{ $field | pluralFieldName }}Count")
+	res, err := t.doLoad{{ $field | pluralFieldName }}Count(ctx, items, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doLoad{{ $field | pluralFieldName }}Count is Load{{ $field | pluralFieldName }}Count without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doLoad{{ $field | pluralFieldName }}Count{{ else }}Load{{ $field | pluralFieldName }}Count{{ end }}(ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) (map[{{ $field | relationKeyType }}]int64, error) {
+	keys := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		{{- if ($field | isOptional) }}
+		if item.{{ $field | getFieldID }} == nil {
+			continue
+		}
+		keys = append(keys, *item.{{ $field | getFieldID }})
+		{{- else }}
+		keys = append(keys, item.{{ $field | getFieldID }})
+		{{- end }}
+	}
+
+	counts := make(map[{{ $field | relationKeyType }}]int64, len(keys))
+	if len(keys) == 0 {
+		return counts, nil
+	}
+
+	query := t.queryBuilder.Select("{{ $rel.Reference }}", "toInt64(count())").
+		From("{{ $field | relationTableName }}").
+		Where(sq.Eq{"{{ $rel.Reference }}": keys}).
+		GroupBy("{{ $rel.Reference }}")
+	for _, builder := range builders {
+		if builder == nil {
+			continue
+		}
+
+		// apply filter options of the related rows
+		for _, option := range builder.filterOptions {
+			query = option.Apply(query)
+		}
+	}
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	rows, err := t.DB().Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count {{ $field | pluralFieldName }}: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			t.logError(ctx, err, "failed to close rows")
+		}
+	}()
+
+	for rows.Next() {
+		var key {{ $field | relationKeyType }}
+		var count int64
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan {{ $field | pluralFieldName }} count: %w", err)
+		}
+		counts[key] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over rows: %w", err)
+	}
+
+	return counts, nil
+}
+{{- end }}
+{{- end }}
+
+{{- range $index, $field := fields }}
+{{- if and ($field | isRelation) }}
+
+// {{ structureName }}Rel{{ $field | fieldName }} identifies the {{ $field | fieldName }} relation of {{ structureName }} for Preload.
+var {{ structureName }}Rel{{ $field | fieldName }} = Relation{name: "{{ structureName }}.{{ $field | fieldName }}"}
+{{- end }}
+{{- end }}
+
+// preload loads the given relations into items with one batched query per relation.
+// Nested relations are preloaded by the related storage.
+func (t *{{ storageName | lowerCamelCase }}) preload(ctx context.Context, items []*{{structureName}}, relations []Relation) error {
+	for _, relation := range mergeRelations(relations) {
+		switch relation.name {
+		{{- range $index, $field := fields }}
+		{{- if and ($field | isRelation) }}
+		case {{ structureName }}Rel{{ $field | fieldName }}.name:
+			if err := t.LoadBatch{{ $field | pluralFieldName }}(ctx, items, relation.builders...); err != nil {
+				return fmt.Errorf("failed to preload %s: %w", relation, err)
+			}
+		{{- end }}
+		{{- end }}
+		default:
+			return fmt.Errorf("unknown relation %s for {{ structureName }}", relation)
+		}
+	}
+
+	return nil
+}
 `
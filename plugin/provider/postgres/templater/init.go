@@ -26,6 +26,9 @@ type initTemplater struct {
 	// initMethods bool
 	CRUDSchemas bool
 	UseSQLX     bool
+	Outbox      bool
+	Otel        bool
+	Metrics     bool
 }
 
 // NewInitTemplater returns a new initTemplater.
@@ -36,6 +39,9 @@ func NewInitTemplater(state *statepkg.State) statepkg.Templater {
 		IncludeConnection: state.IncludeConnection,
 		CRUDSchemas:       state.CRUDSchemas,
 		UseSQLX:           state.UseSQLX,
+		Outbox:            state.Outbox,
+		Otel:              state.Otel,
+		Metrics:           state.Metrics,
 	}
 }
 
@@ -73,6 +79,26 @@ func (i *initTemplater) BuildTemplate() string {
 			Name: "transaction",
 			Body: tmplpkg.TransactionManagerTemplate,
 		},
+		helperpkg.IncludeTemplate{
+			Name: "replicas",
+			Body: tmplpkg.ReplicasTemplate,
+		},
+		helperpkg.IncludeTemplate{
+			Name: "interceptors",
+			Body: tmplpkg.InterceptorsTemplate,
+		},
+		helperpkg.IncludeTemplate{
+			Name: "slowquery",
+			Body: tmplpkg.SlowQueryTemplate,
+		},
+		helperpkg.IncludeTemplate{
+			Name: "tracing",
+			Body: tmplpkg.TracingTemplate,
+		},
+		helperpkg.IncludeTemplate{
+			Name: "metrics",
+			Body: tmplpkg.MetricsTemplate,
+		},
 		helperpkg.IncludeTemplate{
 			Name: "options",
 			Body: tmplpkg.OptionsTemplate,
@@ -81,6 +107,10 @@ func (i *initTemplater) BuildTemplate() string {
 			Name: "conditions",
 			Body: tmplpkg.TableConditionsTemplate,
 		},
+		helperpkg.IncludeTemplate{
+			Name: "outbox",
+			Body: tmplpkg.OutboxTemplate,
+		},
 	)
 	if err != nil {
 		log.Fatalf("failed to execute template: %v", err)
@@ -115,6 +145,9 @@ func (i *initTemplater) Imports() *importpkg.ImportSet {
 	if strings.Contains(tmp, "math.") {
 		is.Add(importpkg.ImportMath)
 	}
+	if strings.Contains(tmp, "rand.") {
+		is.Add(importpkg.ImportMathRand)
+	}
 	if strings.Contains(tmp, "json.") {
 		is.Add(importpkg.ImportJson)
 	}
@@ -124,12 +157,44 @@ func (i *initTemplater) Imports() *importpkg.ImportSet {
 	if strings.Contains(tmp, "strings.") {
 		is.Add(importpkg.ImportStrings)
 	}
+	if strings.Contains(tmp, "atomic.") {
+		is.Add(importpkg.ImportSyncAtomic)
+	}
+	if strings.Contains(tmp, "net.Error") {
+		is.Add(importpkg.ImportNet)
+	}
 	if strings.Contains(tmp, "pgconn.") {
 		is.Add(importpkg.ImportPgxConn)
 	}
+	if strings.Contains(tmp, "pgx.") {
+		is.Add(importpkg.ImportPgx)
+	}
+	if strings.Contains(tmp, "stdlib.") {
+		is.Add(importpkg.ImportPgxStdlib)
+	}
+	if strings.Contains(tmp, "pq.") {
+		is.Add(importpkg.ImportLibPQWOAlias)
+	}
 	if strings.Contains(tmp, "structpb.") {
 		is.Add(importpkg.ImportStructPB)
 	}
+	if strings.Contains(tmp, "proto.Message") {
+		is.Add(importpkg.ImportProto)
+	}
+
+	if i.Otel {
+		is.Add(
+			importpkg.ImportRegexp,
+			importpkg.ImportOtel,
+			importpkg.ImportOtelAttribute,
+			importpkg.ImportOtelCodes,
+			importpkg.ImportOtelTrace,
+		)
+	}
+
+	if i.Metrics {
+		is.Add(importpkg.ImportPrometheus)
+	}
 
 	return is
 }
@@ -48,3 +48,218 @@ func TestInitTemplate_SQLXConnections(t *testing.T) {
 	require.True(t, strings.Contains(out, "func NewTxManager(db DBWriteConnection) *TxManager"))
 	require.True(t, strings.Contains(out, "db QueryExecer"))
 }
+
+func TestInitTemplate_ErrorTaxonomy(t *testing.T) {
+	s := &statepkg.State{
+		Imports: importpkg.NewImportSet(),
+	}
+
+	tpl := NewInitTemplater(s)
+	out := tpl.BuildTemplate()
+
+	for _, typ := range []string{"ErrUniqueViolation", "ErrForeignKeyViolation", "ErrCheckViolation", "ErrSerialization"} {
+		require.True(t, strings.Contains(out, "type "+typ+" struct {"), typ)
+	}
+	require.True(t, strings.Contains(out, "func MapError(err error) error {"))
+	require.True(t, strings.Contains(out, "return &QueryResult{Rows: rows}, MapError(err)"))
+	require.True(t, strings.Contains(tpl.Imports().String(), `"github.com/lib/pq"`))
+}
+
+func TestInitTemplate_Savepoints(t *testing.T) {
+	s := &statepkg.State{
+		Imports: importpkg.NewImportSet(),
+	}
+
+	out := NewInitTemplater(s).BuildTemplate()
+
+	require.True(t, strings.Contains(out, "func TxDepth(ctx context.Context) int {"))
+	require.True(t, strings.Contains(out, `tx.ExecContext(ctx, "SAVEPOINT "+sp.name())`))
+	require.True(t, strings.Contains(out, `tx.ExecContext(ctx, "RELEASE SAVEPOINT "+sp.name())`))
+	require.True(t, strings.Contains(out, `tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+sp.name())`))
+	require.True(t, strings.Contains(out, `if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+sp.name()); rbErr != nil {`))
+	require.False(t, strings.Contains(out, "// if a transaction is already open, just execute the function."))
+}
+
+func TestInitTemplate_LimitPerParentSortArgs(t *testing.T) {
+	s := &statepkg.State{
+		Imports: importpkg.NewImportSet(),
+	}
+
+	out := NewInitTemplater(s).BuildTemplate()
+
+	require.True(t, strings.Contains(out, `query.Column("ROW_NUMBER() OVER (PARTITION BY "+column+" ORDER BY "+orderBy+") AS partition_row", orderArgs...)`))
+	require.True(t, strings.Contains(out, "OrderByClause(orderBy, orderArgs...), nil"))
+}
+
+func TestInitTemplate_TxOptions(t *testing.T) {
+	for _, useSQLX := range []bool{false, true} {
+		s := &statepkg.State{
+			Imports: importpkg.NewImportSet(),
+			UseSQLX: useSQLX,
+		}
+
+		out := NewInitTemplater(s).BuildTemplate()
+
+		require.True(t, strings.Contains(out, "type TxOptions struct {"))
+		require.True(t, strings.Contains(out, "func (m *TxManager) BeginTx(ctx context.Context, opts TxOptions) (context.Context, error) {"))
+		require.True(t, strings.Contains(out, "func (m *TxManager) ExecFuncWithTxOptions(ctx context.Context, opts TxOptions, f func(context.Context) error) error {"))
+		require.True(t, strings.Contains(out, "NewTxManager(config.DB.DBWrite).WithReadDB(config.DB.DBRead)"))
+		require.False(t, strings.Contains(out, "m.db.Begin()"))
+	}
+}
+
+func TestInitTemplate_RetryPolicy(t *testing.T) {
+	s := &statepkg.State{
+		Imports: importpkg.NewImportSet(),
+	}
+
+	tpl := NewInitTemplater(s)
+	out := tpl.BuildTemplate()
+
+	require.True(t, strings.Contains(out, "type RetryPolicy struct {"))
+	require.True(t, strings.Contains(out, "func (m *TxManager) ExecFuncWithRetry(ctx context.Context, policy RetryPolicy, f func(context.Context) error) error {"))
+	require.True(t, strings.Contains(out, "codes = []string{errPgSerializationFailure, errPgDeadlockDetected}"))
+	require.True(t, strings.Contains(tpl.Imports().String(), `"math/rand"`))
+}
+
+func TestInitTemplate_TxHooks(t *testing.T) {
+	s := &statepkg.State{
+		Imports: importpkg.NewImportSet(),
+	}
+
+	out := NewInitTemplater(s).BuildTemplate()
+
+	require.True(t, strings.Contains(out, "func OnCommit(ctx context.Context, f func(context.Context)) {"))
+	require.True(t, strings.Contains(out, "func OnRollback(ctx context.Context, f func(context.Context)) {"))
+	require.True(t, strings.Contains(out, "return context.WithValue(ctx, txHooksKey{}, &txHooks{}), nil"))
+	require.True(t, strings.Contains(out, "hooksFromContext(ctx).run(ctx, true)"))
+}
+
+func TestInitTemplate_Outbox(t *testing.T) {
+	s := &statepkg.State{
+		Imports:     importpkg.NewImportSet(),
+		CRUDSchemas: true,
+		Outbox:      true,
+	}
+
+	tpl := NewInitTemplater(s)
+	out := tpl.BuildTemplate()
+
+	require.True(t, strings.Contains(out, "EnqueueEvent(ctx context.Context, topic, key string, payload proto.Message) error"))
+	require.True(t, strings.Contains(out, `Suffix("FOR UPDATE SKIP LOCKED")`))
+	require.True(t, strings.Contains(out, "err = c.outbox.CreateTable(ctx)"))
+	require.True(t, strings.Contains(out, "type Publisher interface {"))
+	require.True(t, strings.Contains(out, "func (r *OutboxRelay) WithErrorHandler(handler func(ctx context.Context, err error)) *OutboxRelay {"))
+	require.True(t, strings.Contains(out, "func (t *outboxStorage) Enqueue(ctx context.Context, topic, key string, payload proto.Message) error {\n\tif _, ok := TxFromContext(ctx); !ok {\n\t\treturn ErrNoTransaction\n\t}"))
+	require.True(t, strings.Contains(out, `fmt.Errorf("failed to enqueue event: %w", MapError(err))`))
+	require.True(t, strings.Contains(out, "if size < 1 {\n\t\tsize = outboxDefaultBatchSize\n\t}"))
+	require.True(t, strings.Contains(out, "case published > 0 && published == r.batchSize:"))
+	require.True(t, strings.Contains(tpl.Imports().String(), `"google.golang.org/protobuf/proto"`))
+
+	s.Outbox = false
+	require.False(t, strings.Contains(NewInitTemplater(s).BuildTemplate(), "OutboxStorage"))
+}
+
+func TestInitTemplate_Replicas(t *testing.T) {
+	s := &statepkg.State{
+		Imports: importpkg.NewImportSet(),
+		UseSQLX: true,
+	}
+
+	tpl := NewInitTemplater(s)
+	out := tpl.BuildTemplate()
+
+	require.True(t, strings.Contains(out, "Replicas []*Replica"))
+	require.True(t, strings.Contains(out, "func NewReplica(db DBReadConnection) *Replica {"))
+	require.True(t, strings.Contains(out, "func WithPrimary(ctx context.Context) context.Context {"))
+	require.True(t, strings.Contains(out, "func (LeastInFlightSelector) Select(replicas []*Replica) *Replica {"))
+	require.True(t, strings.Contains(out, "return r.primary.QueryRowContext(ctx, query, args...)"))
+	require.True(t, strings.Contains(tpl.Imports().String(), `"sync/atomic"`))
+	require.True(t, strings.Contains(tpl.Imports().String(), `"net"`))
+}
+
+func TestInitTemplate_Interceptors(t *testing.T) {
+	s := &statepkg.State{
+		Imports: importpkg.NewImportSet(),
+	}
+
+	out := NewInitTemplater(s).BuildTemplate()
+
+	require.True(t, strings.Contains(out, "Interceptors []QueryInterceptor"))
+	require.True(t, strings.Contains(out, "type QueryInterceptor func(ctx context.Context, query *Query, next QueryHandler) (*QueryResult, error)"))
+	require.True(t, strings.Contains(out, "result, err := w.intercept(ctx, query, args, func(ctx context.Context, q *Query) (*QueryResult, error) {"))
+	require.True(t, strings.Contains(out, `case "INSERT":`))
+	require.True(t, strings.Contains(out, "return errRow(err)"))
+	require.True(t, strings.Contains(out, "db := sql.OpenDB(errConnector{err: err})"))
+	require.False(t, strings.Contains(out, "QueryRowContext(canceled"))
+}
+
+func TestInitTemplate_Tracing(t *testing.T) {
+	s := &statepkg.State{
+		Imports: importpkg.NewImportSet(),
+		Otel:    true,
+	}
+
+	tpl := NewInitTemplater(s)
+	out := tpl.BuildTemplate()
+
+	require.True(t, strings.Contains(out, "TracerProvider trace.TracerProvider"))
+	require.True(t, strings.Contains(out, "func (c *Config) traceQuery(ctx context.Context, query *Query, next QueryHandler) (*QueryResult, error) {"))
+	require.True(t, strings.Contains(out, "interceptors = append([]QueryInterceptor{c.traceQuery}, interceptors...)"))
+	require.True(t, strings.Contains(out, "ctx = m.startTxSpan(ctx)"))
+	require.True(t, strings.Contains(out, "return NewTxManager(c.DB.DBWrite).WithTracerProvider(c.TracerProvider)"))
+	require.True(t, strings.Contains(tpl.Imports().String(), `"go.opentelemetry.io/otel/trace"`))
+
+	s.Otel = false
+	require.False(t, strings.Contains(NewInitTemplater(s).BuildTemplate(), "traceQuery"))
+}
+
+func TestInitTemplate_Metrics(t *testing.T) {
+	s := &statepkg.State{
+		Imports: importpkg.NewImportSet(),
+		Metrics: true,
+	}
+
+	tpl := NewInitTemplater(s)
+	out := tpl.BuildTemplate()
+
+	require.True(t, strings.Contains(out, "Metrics *Metrics"))
+	require.True(t, strings.Contains(out, "func NewMetrics(db *DB, reg prometheus.Registerer) (*Metrics, error) {"))
+	require.True(t, strings.Contains(out, "interceptors = append([]QueryInterceptor{c.Metrics.observeQuery}, interceptors...)"))
+	require.True(t, strings.Contains(out, "m.metrics.observeTx(txOutcomeCommit)"))
+	require.True(t, strings.Contains(out, `m.collectPool(ch, "write", m.db.DBWrite)`))
+	require.True(t, strings.Contains(tpl.Imports().String(), `"github.com/prometheus/client_golang/prometheus"`))
+
+	s.Metrics = false
+	require.False(t, strings.Contains(NewInitTemplater(s).BuildTemplate(), "observeQuery"))
+}
+
+func TestInitTemplate_SlowQueries(t *testing.T) {
+	s := &statepkg.State{
+		Imports: importpkg.NewImportSet(),
+	}
+
+	out := NewInitTemplater(s).BuildTemplate()
+
+	require.True(t, strings.Contains(out, "SlowQueryThreshold time.Duration"))
+	require.True(t, strings.Contains(out, "type SlowQueryHandler func(ctx context.Context, query *SlowQuery)"))
+	require.True(t, strings.Contains(out, "}, w.config.slowQueries(w.db, exec))"))
+	require.True(t, strings.Contains(out, `db.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query.SQL, query.Args...)`))
+}
+
+func TestInitTemplate_CopyIn(t *testing.T) {
+	s := &statepkg.State{
+		Imports: importpkg.NewImportSet(),
+	}
+
+	tpl := NewInitTemplater(s)
+	out := tpl.BuildTemplate()
+
+	require.True(t, strings.Contains(out, "func (c *Config) copyIn(ctx context.Context, table string, columns []string, next func() ([]interface{}, bool, error)) (int64, error) {"))
+	require.True(t, strings.Contains(out, "intercept(ctx, c.queryInterceptors(), query, c.slowQueries(nil,"))
+	require.True(t, strings.Contains(out, "if _, ok := db.Driver().(*stdlib.Driver); ok {"))
+	require.True(t, strings.Contains(out, "stdlibConn.Conn().CopyFrom(ctx, pgx.Identifier(strings.Split(table, \".\")), columns, &copySource{next: next})"))
+	require.True(t, strings.Contains(out, "tx.PrepareContext(ctx, pq.CopyIn(table, columns...))"))
+	require.True(t, strings.Contains(out, `fmt.Errorf("failed to prepare copy: %w", MapError(err))`))
+	require.True(t, strings.Contains(tpl.Imports().String(), `"github.com/jackc/pgx/v5/stdlib"`))
+}
@@ -62,10 +62,30 @@ func (t *tableTemplater) BuildTemplate() string {
 			Name: "batch_create_method",
 			Body: tmplpkg.TableBatchCreateMethodTemplate,
 		},
+		helperpkg.IncludeTemplate{
+			Name: "batch_upsert_method",
+			Body: tmplpkg.TableBatchUpsertMethodTemplate,
+		},
+		helperpkg.IncludeTemplate{
+			Name: "copy_from_method",
+			Body: tmplpkg.TableCopyFromMethodTemplate,
+		},
 		helperpkg.IncludeTemplate{
 			Name: "update_method",
 			Body: tmplpkg.TableUpdateMethodTemplate,
 		},
+		helperpkg.IncludeTemplate{
+			Name: "returning_method",
+			Body: tmplpkg.TableReturningMethodTemplate,
+		},
+		helperpkg.IncludeTemplate{
+			Name: "many_to_many_method",
+			Body: tmplpkg.TableManyToManyMethodTemplate,
+		},
+		helperpkg.IncludeTemplate{
+			Name: "tree_method",
+			Body: tmplpkg.TableTreeMethodTemplate,
+		},
 		helperpkg.IncludeTemplate{
 			Name: "delete_method",
 			Body: tmplpkg.TableDeleteMethodTemplate,
@@ -82,6 +102,10 @@ func (t *tableTemplater) BuildTemplate() string {
 			Name: "get_field_by_id_method",
 			Body: tmplpkg.TableGetFieldByIDMethodTemplate,
 		},
+		helperpkg.IncludeTemplate{
+			Name: "unique_method",
+			Body: tmplpkg.TableUniqueMethodTemplate,
+		},
 		helperpkg.IncludeTemplate{
 			Name: "find_many_method",
 			Body: tmplpkg.TableFindManyMethodTemplate,
@@ -155,6 +179,9 @@ func (t *tableTemplater) Imports() *importpkg.ImportSet {
 	if strings.Contains(tmp, "strings.") {
 		is.Add(importpkg.ImportStrings)
 	}
+	if strings.Contains(tmp, "pq.") {
+		is.Add(importpkg.ImportLibPQWOAlias)
+	}
 	if strings.Contains(tmp, "structpb.") {
 		is.Add(importpkg.ImportStructPB)
 	}
@@ -367,6 +394,61 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 			return false
 		},
 
+		// hasUniqueKeys returns true if the message has unique fields or unique indexes to find by.
+		"hasUniqueKeys": func() bool {
+			return len(t.uniqueKeys()) > 0
+		},
+
+		// uniqueKeys returns the unique fields and the unique indexes of the message.
+		"uniqueKeys": t.uniqueKeys,
+
+		// primaryKeyName returns the name of the primary key, "key" for a composite one.
+		"primaryKeyName": func() string {
+			if len(t.primaryKeys()) > 1 {
+				return "key"
+			}
+			for _, f := range t.primaryKeys() {
+				return f.GetName()
+			}
+			return ""
+		},
+
+		// keyType returns the type of the primary key, the key struct for a composite one.
+		"keyType": func() string {
+			pks := t.primaryKeys()
+			if len(pks) > 1 {
+				return helperpkg.UpperCamelCase(t.message.GetName()) + "Key"
+			}
+			for _, f := range pks {
+				return helperpkg.ConvertType(f)
+			}
+			return "int64"
+		},
+
+		// isCompositeRelation returns true if the relation spans several columns.
+		"isCompositeRelation": func(f *descriptorpb.FieldDescriptorProto) bool {
+			relation, ok := t.state.Relations.Get(statepkg.RelationName(t.message.GetName(), f))
+			return ok && relation.IsComposite()
+		},
+
+		// relationFields returns the fields of the message a composite relation is joined on.
+		"relationFields": func(f *descriptorpb.FieldDescriptorProto) []*descriptorpb.FieldDescriptorProto {
+			relation, ok := t.state.Relations.Get(statepkg.RelationName(t.message.GetName(), f))
+			if !ok {
+				return nil
+			}
+			return fieldsByName(relation.ParentDescriptor, relation.Fields())
+		},
+
+		// relationReferences returns the fields of the related message a composite relation is joined on.
+		"relationReferences": func(f *descriptorpb.FieldDescriptorProto) []*descriptorpb.FieldDescriptorProto {
+			relation, ok := t.state.Relations.Get(statepkg.RelationName(t.message.GetName(), f))
+			if !ok {
+				return nil
+			}
+			return fieldsByName(relation.RelationDescriptor, relation.References())
+		},
+
 		"hasPrimaryKey": func() bool {
 			for _, f := range t.message.GetField() {
 				if opts := helperpkg.GetFieldOptions(f); opts != nil {
@@ -510,7 +592,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		// isOptional returns true if the field is marked as optional.
 		"isOptional": func(f *descriptorpb.FieldDescriptorProto) bool {
 			// Construct the relation name based on the message and the field type.
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 
 			// Retrieve the relation from the state using the constructed name.
 			relation, ok := t.state.Relations.Get(relName)
@@ -535,7 +617,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 
 		// hasRelationOptions returns true if the field has relation options.
 		"hasRelationOptions": func(f *descriptorpb.FieldDescriptorProto) bool {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 			_, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -553,10 +635,51 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		// hasUnique returns true if the field has unique.
 		"hasUnique": helperpkg.HasUnique,
 
+		// isAutoCreateTime returns true if the field is filled with the current time on insert.
+		"isAutoCreateTime": helperpkg.IsAutoCreateTime,
+
+		// isAutoUpdateTime returns true if the field is filled with the current time on insert and update.
+		"isAutoUpdateTime": helperpkg.IsAutoUpdateTime,
+
+		// otel returns true if the storage methods start OpenTelemetry spans.
+		"otel": func() bool {
+			return t.state.Otel
+		},
+
+		// hasAutoTime returns true if the message has automatic time fields.
+		"hasAutoTime": func() bool {
+			for _, f := range t.message.GetField() {
+				if helperpkg.IsAutoCreateTime(f) || helperpkg.IsAutoUpdateTime(f) {
+					return true
+				}
+			}
+			return false
+		},
+
+		// hasAutoUpdateTime returns true if the message has auto update time fields.
+		"hasAutoUpdateTime": func() bool {
+			for _, f := range t.message.GetField() {
+				if helperpkg.IsAutoUpdateTime(f) {
+					return true
+				}
+			}
+			return false
+		},
+
+		// autoTimeValue returns the expression that converts the current time into the field type.
+		"autoTimeValue": func(f *descriptorpb.FieldDescriptorProto) (string, error) {
+			return helperpkg.AutoTimeValue(helperpkg.ConvertType(f), "now")
+		},
+
+		// autoTimeIsZero returns the expression that checks if the automatic time value is not set.
+		"autoTimeIsZero": func(f *descriptorpb.FieldDescriptorProto, v string) string {
+			return helperpkg.AutoTimeIsZero(helperpkg.ConvertType(f), v)
+		},
+
 		// hasRelation returns true if the message has relation.
 		"hasRelation": func() bool {
 			for _, f := range t.message.GetField() {
-				relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+				relName := statepkg.RelationName(t.message.GetName(), f)
 				_, ok := t.state.Relations.Get(relName)
 				if ok {
 					return true
@@ -565,9 +688,53 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 			return false
 		},
 
+		// relationKeyType returns the type of the field the related rows reference, without pointer.
+		"relationKeyType": func(f *descriptorpb.FieldDescriptorProto) string {
+			relName := statepkg.RelationName(t.message.GetName(), f)
+			if relation, ok := t.state.Relations.Get(relName); ok {
+				for _, field := range relation.ParentDescriptor.GetField() {
+					if field.GetName() == relation.Field {
+						return helperpkg.ClearPointer(helperpkg.ConvertType(field))
+					}
+				}
+			}
+			return "interface{}"
+		},
+
+		// isRefOptional returns true if the referencing field of the related message is optional.
+		"isRefOptional": func(f *descriptorpb.FieldDescriptorProto) bool {
+			relName := statepkg.RelationName(t.message.GetName(), f)
+			if relation, ok := t.state.Relations.Get(relName); ok && relation.RelationDescriptor != nil {
+				for _, field := range relation.RelationDescriptor.GetField() {
+					if field.GetName() == relation.Reference {
+						return field.GetProto3Optional()
+					}
+				}
+			}
+			return false
+		},
+
+		// hasSelfRelation returns true if the message references its own table.
+		"hasSelfRelation": func() bool {
+			key, _ := t.treeFields()
+			return key != nil
+		},
+
+		// treeKey returns the field the self relation references.
+		"treeKey": func() *descriptorpb.FieldDescriptorProto {
+			key, _ := t.treeFields()
+			return key
+		},
+
+		// treeParentKey returns the field holding the parent reference of the self relation.
+		"treeParentKey": func() *descriptorpb.FieldDescriptorProto {
+			_, parent := t.treeFields()
+			return parent
+		},
+
 		// relation returns the relation.
 		"relation": func(f *descriptorpb.FieldDescriptorProto) *statepkg.Relation {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 			relation, ok := t.state.Relations.Get(relName)
 			if !ok {
 				return nil
@@ -576,9 +743,117 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 			return relation
 		},
 
+		// isManyToMany returns true if the relation goes through a join table.
+		"isManyToMany": func(f *descriptorpb.FieldDescriptorProto) bool {
+			relName := statepkg.RelationName(t.message.GetName(), f)
+			relation, ok := t.state.Relations.Get(relName)
+			return ok && relation.Direction == statepkg.ManyToMany
+		},
+
+		// relationKey returns the field of the message a many-to-many relation is keyed by.
+		"relationKey": func(f *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+			relName := statepkg.RelationName(t.message.GetName(), f)
+			if relation, ok := t.state.Relations.Get(relName); ok {
+				for _, field := range relation.ParentDescriptor.GetField() {
+					if field.GetName() == relation.Field {
+						return field
+					}
+				}
+			}
+			return nil
+		},
+
+		// relationRefKey returns the field of the related message a many-to-many relation is keyed by.
+		"relationRefKey": func(f *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+			relName := statepkg.RelationName(t.message.GetName(), f)
+			if relation, ok := t.state.Relations.Get(relName); ok {
+				for _, field := range relation.RelationDescriptor.GetField() {
+					if field.GetName() == relation.Reference {
+						return field
+					}
+				}
+			}
+			return nil
+		},
+
+		// relationCascadeDelete returns true if the related rows are deleted with the parent.
+		"relationCascadeDelete": func(f *descriptorpb.FieldDescriptorProto) bool {
+			relName := statepkg.RelationName(t.message.GetName(), f)
+			relation, ok := t.state.Relations.Get(relName)
+			return ok && t.state.IsRelation(f) && relation.CascadeDelete
+		},
+
+		// hasCascadeDelete returns true if the message has relations deleted with it.
+		"hasCascadeDelete": func() bool {
+			for _, f := range t.message.GetField() {
+				relName := statepkg.RelationName(t.message.GetName(), f)
+				if relation, ok := t.state.Relations.Get(relName); ok && t.state.IsRelation(f) && relation.CascadeDelete {
+					return true
+				}
+			}
+			return false
+		},
+
+		// hasChildRelations returns true if the message has relations created with it.
+		"hasChildRelations": func() bool {
+			for _, f := range t.message.GetField() {
+				relName := statepkg.RelationName(t.message.GetName(), f)
+				if relation, ok := t.state.Relations.Get(relName); ok && t.state.IsRelation(f) &&
+					relation.AllowSubCreating && relation.Direction != statepkg.ManyToMany {
+					return true
+				}
+			}
+			return false
+		},
+
+		// relationHasCascadeDelete returns true if the related message has relations deleted with it.
+		"relationHasCascadeDelete": func(f *descriptorpb.FieldDescriptorProto) bool {
+			relName := statepkg.RelationName(t.message.GetName(), f)
+			relation, ok := t.state.Relations.Get(relName)
+			if !ok {
+				return false
+			}
+			for _, r := range t.state.Relations {
+				if r.ParentDescriptor.GetName() == relation.RelationDescriptor.GetName() && r.CascadeDelete {
+					return true
+				}
+			}
+			return false
+		},
+
+		// relationPrimaryKey returns the single primary key of the related message or nil.
+		"relationPrimaryKey": func(f *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+			relName := statepkg.RelationName(t.message.GetName(), f)
+			relation, ok := t.state.Relations.Get(relName)
+			if !ok {
+				return nil
+			}
+			var pk *descriptorpb.FieldDescriptorProto
+			for _, field := range relation.RelationDescriptor.GetField() {
+				if opts := helperpkg.GetFieldOptions(field); opts != nil && opts.GetPrimaryKey() {
+					if pk != nil {
+						return nil
+					}
+					pk = field
+				}
+			}
+			return pk
+		},
+
+		// hasManyToMany returns true if the message has a many-to-many relation.
+		"hasManyToMany": func() bool {
+			for _, f := range t.message.GetField() {
+				relName := statepkg.RelationName(t.message.GetName(), f)
+				if relation, ok := t.state.Relations.Get(relName); ok && relation.Direction == statepkg.ManyToMany {
+					return true
+				}
+			}
+			return false
+		},
+
 		// relationName returns the relation name.
 		"relationStorageName": func(f *descriptorpb.FieldDescriptorProto) string {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -588,7 +863,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		},
 
 		"relationStructureName": func(f *descriptorpb.FieldDescriptorProto) string {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -598,7 +873,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		},
 
 		"relationTableName": func(f *descriptorpb.FieldDescriptorProto) string {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -615,7 +890,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 
 		// relationName returns the relation name.
 		"hasIDFromRelation": func(f *descriptorpb.FieldDescriptorProto) bool {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -651,6 +926,44 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 			return indexes
 		},
 
+		// conflictColumns returns the columns of the fields as an ON CONFLICT target.
+		"conflictColumns": func(fields []*descriptorpb.FieldDescriptorProto) string {
+			var columns []string
+			for _, f := range fields {
+				columns = append(columns, f.GetName())
+			}
+			return strings.Join(columns, ", ")
+		},
+
+		// defaultConflictTarget returns the primary key columns or, when the primary key is
+		// generated by the database, the columns of the first unique index if there is one.
+		// It is the conflict target of both Upsert and BatchUpsert.
+		"defaultConflictTarget": func() string {
+			var columns []string
+			var generated bool
+			for _, f := range t.message.GetField() {
+				opts := helperpkg.GetFieldOptions(f)
+				if opts == nil || !opts.GetPrimaryKey() {
+					continue
+				}
+				// generated keys are not inserted, so they can't conflict.
+				if opts.GetAutoIncrement() || strings.Contains(opts.GetDefault(), "uuid_generate") {
+					generated = true
+				}
+				columns = append(columns, f.GetName())
+			}
+			if generated || len(columns) == 0 {
+				if opts := helperpkg.GetMessageOptions(t.message); opts != nil {
+					for _, uniqueIndex := range opts.GetUniqueIndex() {
+						if len(uniqueIndex.GetFields()) > 0 {
+							return strings.Join(uniqueIndex.GetFields(), ", ")
+						}
+					}
+				}
+			}
+			return strings.Join(columns, ", ")
+		},
+
 		"sub": func(a, b int) int {
 			return a - b
 		},
@@ -664,7 +977,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		},
 
 		"getFieldID": func(fl *descriptorpb.FieldDescriptorProto) string {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(fl))
+			relName := statepkg.RelationName(t.message.GetName(), fl)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -700,7 +1013,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		},
 
 		"getRefID": func(fl *descriptorpb.FieldDescriptorProto) string {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(fl))
+			relName := statepkg.RelationName(t.message.GetName(), fl)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -733,7 +1046,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		},
 
 		"getRefSource": func(fl *descriptorpb.FieldDescriptorProto) string {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(fl))
+			relName := statepkg.RelationName(t.message.GetName(), fl)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -784,7 +1097,7 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		},
 
 		"getFieldSource": func(fl *descriptorpb.FieldDescriptorProto) string {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(fl))
+			relName := statepkg.RelationName(t.message.GetName(), fl)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
@@ -838,11 +1151,12 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 
 		// relationAllowSubCreating returns true if the relation allows sub creating.
 		"relationAllowSubCreating": func(f *descriptorpb.FieldDescriptorProto) bool {
-			relName := t.message.GetName() + "::" + helperpkg.ClearPointer(helperpkg.ConvertType(f))
+			relName := statepkg.RelationName(t.message.GetName(), f)
 			relation, ok := t.state.Relations.Get(relName)
 
 			if ok {
-				return relation.AllowSubCreating
+				// many-to-many rows are linked with Attach and Sync instead.
+				return relation.AllowSubCreating && relation.Direction != statepkg.ManyToMany
 			}
 			return false
 		},
@@ -921,3 +1235,134 @@ func (t *tableTemplater) Funcs() map[string]interface{} {
 		},
 	}
 }
+
+// selfRelation returns the first non many-to-many relation of the message to its own table.
+func (t *tableTemplater) selfRelation() *statepkg.Relation {
+	for _, f := range t.message.GetField() {
+		relation, ok := t.state.Relations.Get(statepkg.RelationName(t.message.GetName(), f))
+		if ok && relation.SelfReference && relation.Direction != statepkg.ManyToMany {
+			return relation
+		}
+	}
+	return nil
+}
+
+// treeFields returns the key and the parent key fields of the self relation.
+func (t *tableTemplater) treeFields() (key, parent *descriptorpb.FieldDescriptorProto) {
+	relation := t.selfRelation()
+	if relation == nil {
+		return nil, nil
+	}
+
+	keyName, parentName := relation.Reference, relation.Field
+	if relation.Direction == statepkg.ParentToChild {
+		keyName, parentName = relation.Field, relation.Reference
+	}
+	for _, f := range t.message.GetField() {
+		switch f.GetName() {
+		case keyName:
+			key = f
+		case parentName:
+			parent = f
+		}
+	}
+	if key == nil || parent == nil {
+		return nil, nil
+	}
+	return key, parent
+}
+
+// primaryKeys returns the primary key fields of the message.
+func (t *tableTemplater) primaryKeys() []*descriptorpb.FieldDescriptorProto {
+	var pks []*descriptorpb.FieldDescriptorProto
+	for _, f := range t.message.GetField() {
+		if opts := helperpkg.GetFieldOptions(f); opts != nil && opts.GetPrimaryKey() {
+			pks = append(pks, f)
+		}
+	}
+	return pks
+}
+
+// fieldsByName returns the fields of the message with the given names in the order of the names.
+func fieldsByName(msg *descriptorpb.DescriptorProto, names []string) []*descriptorpb.FieldDescriptorProto {
+	var fields []*descriptorpb.FieldDescriptorProto
+	for _, name := range names {
+		for _, f := range msg.GetField() {
+			if f.GetName() == name {
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields
+}
+
+// uniqueKey is a set of fields identifying a single row of the message.
+type uniqueKey struct {
+	// Name is the name of the key in the generated methods, e.g. "NameAndEmail".
+	Name   string
+	Fields []*descriptorpb.FieldDescriptorProto
+}
+
+// uniqueKeys returns the fields marked as unique and the unique indexes of the message.
+// Keys repeating the primary key and keys over repeated, message or bytes fields are skipped.
+func (t *tableTemplater) uniqueKeys() []uniqueKey {
+	var (
+		keys []uniqueKey
+		seen = make(map[string]bool)
+	)
+	add := func(fields []*descriptorpb.FieldDescriptorProto) {
+		if len(fields) == 0 || sameFields(fields, t.primaryKeys()) {
+			return
+		}
+		var names []string
+		for _, f := range fields {
+			if helperpkg.IsRepeated(f) || t.state.IsRelation(f) ||
+				f.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE ||
+				f.GetType() == descriptorpb.FieldDescriptorProto_TYPE_BYTES {
+				return
+			}
+			names = append(names, helperpkg.UpperCamelCase(f.GetName()))
+		}
+		name := strings.Join(names, "And")
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		keys = append(keys, uniqueKey{Name: name, Fields: fields})
+	}
+
+	for _, f := range t.message.GetField() {
+		if opts := helperpkg.GetFieldOptions(f); opts != nil && opts.GetUnique() {
+			add([]*descriptorpb.FieldDescriptorProto{f})
+		}
+	}
+	if opts := helperpkg.GetMessageOptions(t.message); opts != nil {
+		for _, index := range opts.GetUniqueIndex() {
+			fields := fieldsByName(t.message, index.GetFields())
+			if len(fields) == len(index.GetFields()) {
+				add(fields)
+			}
+		}
+	}
+	return keys
+}
+
+// sameFields returns true if both lists hold the same fields in any order.
+func sameFields(a, b []*descriptorpb.FieldDescriptorProto) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, f := range a {
+		var found bool
+		for _, g := range b {
+			if f.GetName() == g.GetName() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
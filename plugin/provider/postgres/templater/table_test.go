@@ -0,0 +1,350 @@
+package templater
+
+import (
+	"strings"
+	"testing"
+
+	structify "github.com/cjp2600/protoc-gen-structify/plugin/options"
+	statepkg "github.com/cjp2600/protoc-gen-structify/plugin/state"
+	plugingo "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func testField(name string, opts *structify.StructifyFieldOptions) *descriptorpb.FieldDescriptorProto {
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:  proto.String(name),
+		Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:  descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+	}
+	if opts != nil {
+		f.Options = &descriptorpb.FieldOptions{}
+		proto.SetExtension(f.Options, structify.E_Field, opts)
+	}
+	return f
+}
+
+func TestTableTemplate_BatchUpsertConflictTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  *descriptorpb.DescriptorProto
+		expected string
+	}{
+		{
+			name: "composite primary key",
+			message: &descriptorpb.DescriptorProto{
+				Name: proto.String("Membership"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					testField("tenant_id", &structify.StructifyFieldOptions{PrimaryKey: true}),
+					testField("user_id", &structify.StructifyFieldOptions{PrimaryKey: true}),
+					testField("role", nil),
+				},
+			},
+			expected: `conflictTarget := "tenant_id, user_id"`,
+		},
+		{
+			name: "generated primary key falls back to unique index",
+			message: &descriptorpb.DescriptorProto{
+				Name: proto.String("Account"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					testField("id", &structify.StructifyFieldOptions{PrimaryKey: true, Default: "uuid_generate_v4()"}),
+					testField("tenant_id", nil),
+					testField("email", nil),
+				},
+				Options: func() *descriptorpb.MessageOptions {
+					opts := &descriptorpb.MessageOptions{}
+					proto.SetExtension(opts, structify.E_Opts, &structify.StructifyMessageOptions{
+						UniqueIndex: []*structify.UniqueIndex{{Fields: []string{"tenant_id", "email"}}},
+					})
+					return opts
+				}(),
+			},
+			expected: `conflictTarget := "tenant_id, email"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &statepkg.State{
+				Relations: make(statepkg.Relations),
+			}
+
+			out := NewTableTemplater(tt.message, s).BuildTemplate()
+			require.Contains(t, out, "BatchUpsert(ctx context.Context")
+			require.Contains(t, out, tt.expected)
+		})
+	}
+}
+
+func TestTableTemplate_UniqueKeys(t *testing.T) {
+	message := &descriptorpb.DescriptorProto{
+		Name: proto.String("Account"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			testField("id", &structify.StructifyFieldOptions{PrimaryKey: true, Unique: true}),
+			testField("name", nil),
+			testField("email", &structify.StructifyFieldOptions{Unique: true}),
+		},
+		Options: func() *descriptorpb.MessageOptions {
+			opts := &descriptorpb.MessageOptions{}
+			proto.SetExtension(opts, structify.E_Opts, &structify.StructifyMessageOptions{
+				UniqueIndex: []*structify.UniqueIndex{
+					{Fields: []string{"name", "email"}},
+					{Fields: []string{"id"}},
+					{Fields: []string{"name", "missing"}},
+				},
+			})
+			return opts
+		}(),
+	}
+	s := &statepkg.State{
+		Relations: make(statepkg.Relations),
+	}
+
+	keys := NewTableTemplater(message, s).(*tableTemplater).uniqueKeys()
+	require.Len(t, keys, 2)
+	require.Equal(t, "Email", keys[0].Name)
+	require.Equal(t, "NameAndEmail", keys[1].Name)
+
+	out := NewTableTemplater(message, s).BuildTemplate()
+	require.Contains(t, out, "FindByEmail(ctx context.Context, email string, opts ...Option) (*Account, error)")
+	require.Contains(t, out, "FindManyByEmails(ctx context.Context, emails []string, opts ...Option) (map[string]*Account, error)")
+	require.Contains(t, out, "ExistsByNameAndEmail(ctx context.Context, name string, email string) (bool, error)")
+	require.Contains(t, out, "DeleteByNameAndEmail(ctx context.Context, name string, email string, opts ...Option) (int64, error)")
+	require.NotContains(t, out, "FindManyByIds")
+}
+
+func TestTableTemplate_CompositeUniqueKeyFilters(t *testing.T) {
+	message := &descriptorpb.DescriptorProto{
+		Name: proto.String("Account"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			testField("id", &structify.StructifyFieldOptions{PrimaryKey: true}),
+			testField("name", nil),
+			testField("email", nil),
+		},
+		Options: func() *descriptorpb.MessageOptions {
+			opts := &descriptorpb.MessageOptions{}
+			proto.SetExtension(opts, structify.E_Opts, &structify.StructifyMessageOptions{
+				UniqueIndex: []*structify.UniqueIndex{{Fields: []string{"name", "email"}}},
+			})
+			return opts
+		}(),
+	}
+	s := &statepkg.State{
+		Relations: make(statepkg.Relations),
+	}
+
+	out := NewTableTemplater(message, s).BuildTemplate()
+
+	// WithFilter replaces the filters, so all the columns of the key must be set at once.
+	require.Contains(t, out, `builder.WithFilter(Eq("name", name), Eq("email", email))`)
+	require.NotContains(t, out, `builder.WithFilter(Eq("name", name))`)
+	require.NotContains(t, out, `builder.WithFilter(Eq("email", email))`)
+}
+
+func TestTableTemplate_SelfReferenceExists(t *testing.T) {
+	relation := func(rel *structify.Relation) *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, structify.E_Field, &structify.StructifyFieldOptions{Relation: rel})
+		return opts
+	}
+
+	message := &descriptorpb.DescriptorProto{
+		Name: proto.String("Category"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			testField("id", &structify.StructifyFieldOptions{PrimaryKey: true}),
+			{Name: proto.String("parent_id"), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Number: proto.Int32(2), Proto3Optional: proto.Bool(true)},
+			{
+				Name:     proto.String("children"),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".test.Category"),
+				Number:   proto.Int32(3),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Options:  relation(&structify.Relation{Field: "id", Reference: "parent_id"}),
+			},
+		},
+	}
+	s := statepkg.NewState(&plugingo.CodeGeneratorRequest{
+		FileToGenerate: []string{"test.proto"},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{{
+			Name:        proto.String("test.proto"),
+			Package:     proto.String("test"),
+			MessageType: []*descriptorpb.DescriptorProto{message},
+		}},
+	})
+
+	out := NewTableTemplater(message, s).BuildTemplate()
+
+	// the subquery is aliased, otherwise categories.parent_id = categories.id compares each row with itself.
+	require.Contains(t, out, `Alias:   "sub",`)
+	require.Contains(t, out, `On:      "sub.parent_id = categories.id",`)
+	require.NotContains(t, out, `"categories.parent_id = categories.id"`)
+}
+
+func TestTableTemplate_UpsertTouchesAutoUpdateTime(t *testing.T) {
+	message := &descriptorpb.DescriptorProto{
+		Name: proto.String("Account"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			testField("id", &structify.StructifyFieldOptions{PrimaryKey: true}),
+			testField("created_at", &structify.StructifyFieldOptions{AutoCreateTime: true}),
+			testField("updated_at", &structify.StructifyFieldOptions{AutoUpdateTime: true}),
+		},
+	}
+	s := &statepkg.State{
+		Relations: make(statepkg.Relations),
+	}
+
+	out := NewTableTemplater(message, s).BuildTemplate()
+	require.Contains(t, out, "func (t *accountStorage) touchAutoUpdateTime(model *Account) {")
+	require.Contains(t, out, "model.UpdatedAt = now.Format(time.RFC3339Nano)")
+	require.Equal(t, 3, strings.Count(out, "t.touchAutoUpdateTime(model)"))
+}
+
+func TestTableTemplate_MethodSpans(t *testing.T) {
+	message := &descriptorpb.DescriptorProto{
+		Name: proto.String("Account"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			testField("id", &structify.StructifyFieldOptions{PrimaryKey: true}),
+			testField("email", &structify.StructifyFieldOptions{Unique: true}),
+		},
+	}
+	s := &statepkg.State{
+		Relations: make(statepkg.Relations),
+		Otel:      true,
+	}
+
+	out := NewTableTemplater(message, s).BuildTemplate()
+	require.Contains(t, out, `ctx, span := t.config.startSpan(ctx, t.TableName(), "AccountStorage.CreateReturning")`)
+	require.Contains(t, out, "res, err := t.doCreateReturning(ctx, model, opts...)")
+	require.Contains(t, out, "func (t *accountStorage) doCreateReturning(ctx context.Context, model *Account, opts ...Option) (*Account, error) {")
+	require.Contains(t, out, "res, err := t.doFindByEmail(ctx, email, opts...)")
+	require.Contains(t, out, "res, paginator, err := t.doFindManyWithPagination(ctx, limit, page, builders...)")
+
+	s.Otel = false
+	require.NotContains(t, NewTableTemplater(message, s).BuildTemplate(), "startSpan")
+}
+
+func TestTableTemplate_MapsDriverErrors(t *testing.T) {
+	message := &descriptorpb.DescriptorProto{
+		Name: proto.String("Account"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			testField("id", &structify.StructifyFieldOptions{PrimaryKey: true}),
+			testField("email", &structify.StructifyFieldOptions{Unique: true}),
+		},
+	}
+	s := &statepkg.State{
+		Relations: make(statepkg.Relations),
+	}
+
+	out := NewTableTemplater(message, s).BuildTemplate()
+	require.Contains(t, out, `fmt.Errorf("failed to scan field value: %w", MapError(err))`)
+	require.Contains(t, out, `fmt.Errorf("failed to execute batch upsert: %w", MapError(err))`)
+	require.NotContains(t, out, `fmt.Errorf("failed to scan returning id: %w", scanErr)`)
+	require.NotContains(t, out, `fmt.Errorf("failed to iterate over rows: %w", err)`)
+}
+
+func TestTableTemplate_UpsertSharesConflictTarget(t *testing.T) {
+	message := &descriptorpb.DescriptorProto{
+		Name: proto.String("Account"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			testField("id", &structify.StructifyFieldOptions{PrimaryKey: true, Default: "uuid_generate_v4()"}),
+			testField("email", nil),
+			testField("nickname", &structify.StructifyFieldOptions{Nullable: true}),
+		},
+		Options: func() *descriptorpb.MessageOptions {
+			opts := &descriptorpb.MessageOptions{}
+			proto.SetExtension(opts, structify.E_Opts, &structify.StructifyMessageOptions{
+				UniqueIndex: []*structify.UniqueIndex{{Fields: []string{"email"}}},
+			})
+			return opts
+		}(),
+	}
+	s := &statepkg.State{
+		Relations: make(statepkg.Relations),
+	}
+
+	out := NewTableTemplater(message, s).BuildTemplate()
+	require.Contains(t, out, `conflictTarget := "email"`)
+	require.Contains(t, out, `return "ON CONFLICT (" + conflictTarget + ") DO UPDATE SET " + t.upsertSet(updateFields)`)
+	require.NotContains(t, out, `ON CONFLICT (id)`)
+	require.Contains(t, out, "models = t.dedupeUpsert(models, conflictTarget)")
+	require.Contains(t, out, "case \"email\":\n\t\treturn model.Email, true")
+}
+
+// userPostsState returns a User message with an email unique key and cascading posts, and its state.
+func userPostsState(postID *structify.StructifyFieldOptions) (*descriptorpb.DescriptorProto, *statepkg.State) {
+	relationOpts := &descriptorpb.FieldOptions{}
+	proto.SetExtension(relationOpts, structify.E_Field, &structify.StructifyFieldOptions{
+		Relation: &structify.Relation{Field: "id", Reference: "author_id", Foreign: &structify.Foreign{Cascade: true}},
+	})
+	user := &descriptorpb.DescriptorProto{
+		Name: proto.String("User"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			testField("id", &structify.StructifyFieldOptions{PrimaryKey: true}),
+			testField("email", &structify.StructifyFieldOptions{Unique: true}),
+			{
+				Name:     proto.String("posts"),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".test.Post"),
+				Number:   proto.Int32(3),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Options:  relationOpts,
+			},
+		},
+	}
+	post := &descriptorpb.DescriptorProto{
+		Name: proto.String("Post"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			testField("id", postID),
+			testField("author_id", nil),
+		},
+	}
+	return user, statepkg.NewState(&plugingo.CodeGeneratorRequest{
+		FileToGenerate: []string{"test.proto"},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{{
+			Name:        proto.String("test.proto"),
+			Package:     proto.String("test"),
+			MessageType: []*descriptorpb.DescriptorProto{user, post},
+		}},
+	})
+}
+
+func TestTableTemplate_UpdateChildrenKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		postID   *structify.StructifyFieldOptions
+		expected string
+	}{
+		{
+			name:     "client key",
+			postID:   &structify.StructifyFieldOptions{PrimaryKey: true},
+			expected: "if _, err := s.FindById(ctx, item.Id); err == nil {",
+		},
+		{
+			name:     "generated key",
+			postID:   &structify.StructifyFieldOptions{PrimaryKey: true, Default: "uuid_generate_v4()"},
+			expected: "// the keys of the new items are generated by the database",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, s := userPostsState(tt.postID)
+
+			out := NewTableTemplater(user, s).BuildTemplate()
+			require.Contains(t, out, "func (t *userStorage) updatePosts(ctx context.Context, id string, items []*Post) error {")
+			require.Contains(t, out, `return fmt.Errorf("Posts %v: %w", item.Id, ErrForeignChild)`)
+			require.Contains(t, out, "item.Id = *created")
+			require.Contains(t, out, tt.expected)
+		})
+	}
+}
+
+func TestTableTemplate_DeleteByKeyCascadeLocksRow(t *testing.T) {
+	user, s := userPostsState(&structify.StructifyFieldOptions{PrimaryKey: true})
+
+	out := NewTableTemplater(user, s).BuildTemplate()
+	require.Contains(t, out, "deleted, err = t.DeleteByEmail(ctx, email, opts...)")
+	require.Contains(t, out, `model, err := t.SelectForUpdate(ctx, FilterBuilder(Eq("email", email)))`)
+	require.Contains(t, out, "return t.DeleteById(ctx, model.Id, opts...)")
+}
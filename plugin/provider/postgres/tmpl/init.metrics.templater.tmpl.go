@@ -0,0 +1,158 @@
+package tmpl
+
+// MetricsTemplate is the template for the Prometheus metrics.
+// This is included in the init template when the metrics parameter is set.
+const MetricsTemplate = `
+// outcomes of the transactions in the transactions metric.
+const (
+	txOutcomeCommit   = "commit"
+	txOutcomeRollback = "rollback"
+)
+
+// Metrics collects the Prometheus metrics of the storages: the duration and errors of the queries
+// by table and operation, the committed and rolled back transactions and the pool stats of
+// DBRead and DBWrite. Set it as Config.Metrics to collect the metrics of the queries and transactions.
+type Metrics struct {
+	db *DB
+
+	queryDuration *prometheus.HistogramVec
+	queryErrors   *prometheus.CounterVec
+	transactions  *prometheus.CounterVec
+
+	poolMaxOpen      *prometheus.Desc
+	poolOpen         *prometheus.Desc
+	poolInUse        *prometheus.Desc
+	poolIdle         *prometheus.Desc
+	poolWaitCount    *prometheus.Desc
+	poolWaitDuration *prometheus.Desc
+}
+
+// NewMetrics returns the metrics of the storages using db and registers them with reg.
+// Wrap reg with prometheus.WrapRegistererWith to tell the metrics of several storages apart.
+func NewMetrics(db *DB, reg prometheus.Registerer) (*Metrics, error) {
+	if db == nil || db.DBRead == nil {
+		return nil, fmt.Errorf("db read is required")
+	}
+	if reg == nil {
+		return nil, fmt.Errorf("registerer is required")
+	}
+
+	poolDesc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("db_pool_"+name, help, []string{"db"}, nil)
+	}
+
+	m := &Metrics{
+		db: db,
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "db_query_duration_seconds",
+			Help:    "Duration of the queries.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"table", "operation"}),
+		queryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "db_query_errors_total",
+			Help: "Number of failed queries by error class.",
+		}, []string{"table", "operation", "class"}),
+		transactions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "db_transactions_total",
+			Help: "Number of committed and rolled back transactions.",
+		}, []string{"outcome"}),
+		poolMaxOpen:      poolDesc("max_open_connections", "Maximum number of open connections."),
+		poolOpen:         poolDesc("open_connections", "Number of open connections."),
+		poolInUse:        poolDesc("in_use_connections", "Number of connections in use."),
+		poolIdle:         poolDesc("idle_connections", "Number of idle connections."),
+		poolWaitCount:    poolDesc("wait_count_total", "Number of connections waited for."),
+		poolWaitDuration: poolDesc("wait_duration_seconds_total", "Time spent waiting for connections."),
+	}
+	if err := reg.Register(m); err != nil {
+		return nil, fmt.Errorf("failed to register metrics: %w", err)
+	}
+
+	return m, nil
+}
+
+// Describe implements prometheus.Collector interface.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.queryDuration.Describe(ch)
+	m.queryErrors.Describe(ch)
+	m.transactions.Describe(ch)
+
+	ch <- m.poolMaxOpen
+	ch <- m.poolOpen
+	ch <- m.poolInUse
+	ch <- m.poolIdle
+	ch <- m.poolWaitCount
+	ch <- m.poolWaitDuration
+}
+
+// Collect implements prometheus.Collector interface.
+// The pool stats are collected for the connections exposing Stats, like *sql.DB and *sqlx.DB.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.queryDuration.Collect(ch)
+	m.queryErrors.Collect(ch)
+	m.transactions.Collect(ch)
+
+	m.collectPool(ch, "read", m.db.DBRead)
+	if m.db.DBWrite != nil {
+		m.collectPool(ch, "write", m.db.DBWrite)
+	}
+}
+
+// collectPool collects the pool stats of the connection if it exposes them.
+func (m *Metrics) collectPool(ch chan<- prometheus.Metric, label string, conn interface{}) {
+	db, ok := conn.(interface{ Stats() sql.DBStats })
+	if !ok {
+		return
+	}
+
+	stats := db.Stats()
+	ch <- prometheus.MustNewConstMetric(m.poolMaxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections), label)
+	ch <- prometheus.MustNewConstMetric(m.poolOpen, prometheus.GaugeValue, float64(stats.OpenConnections), label)
+	ch <- prometheus.MustNewConstMetric(m.poolInUse, prometheus.GaugeValue, float64(stats.InUse), label)
+	ch <- prometheus.MustNewConstMetric(m.poolIdle, prometheus.GaugeValue, float64(stats.Idle), label)
+	ch <- prometheus.MustNewConstMetric(m.poolWaitCount, prometheus.CounterValue, float64(stats.WaitCount), label)
+	ch <- prometheus.MustNewConstMetric(m.poolWaitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds(), label)
+}
+
+// observeQuery is the interceptor measuring every query.
+func (m *Metrics) observeQuery(ctx context.Context, query *Query, next QueryHandler) (*QueryResult, error) {
+	start := time.Now()
+	result, err := next(ctx, query)
+	m.queryDuration.WithLabelValues(query.Table, string(query.Operation)).Observe(time.Since(start).Seconds())
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		m.queryErrors.WithLabelValues(query.Table, string(query.Operation), errorClass(err)).Inc()
+	}
+
+	return result, err
+}
+
+// observeTx counts a transaction with its outcome.
+func (m *Metrics) observeTx(outcome string) {
+	if m == nil {
+		return
+	}
+	m.transactions.WithLabelValues(outcome).Inc()
+}
+
+// errorClass returns the class of a query error in the errors metric.
+func errorClass(err error) string {
+	err = MapError(err)
+	switch {
+	case errors.As(err, new(*ErrUniqueViolation)):
+		return "unique_violation"
+	case errors.As(err, new(*ErrForeignKeyViolation)):
+		return "foreign_key_violation"
+	case errors.As(err, new(*ErrCheckViolation)):
+		return "check_violation"
+	case errors.As(err, new(*ErrSerialization)):
+		return "serialization_failure"
+	case pgErrorCode(err) == errPgNotNullViolation:
+		return "not_null_violation"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	case isConnError(err):
+		return "connection"
+	}
+	return "other"
+}
+`
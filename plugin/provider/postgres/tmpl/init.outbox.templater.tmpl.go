@@ -0,0 +1,330 @@
+package tmpl
+
+// OutboxTemplate is the template for the transactional outbox.
+// This is included in the init template when the outbox file option is set.
+const OutboxTemplate = `
+// OutboxTableName is the name of the outbox table.
+const OutboxTableName = "outbox"
+
+// OutboxEvent is an event stored in the outbox table.
+type OutboxEvent struct {
+	ID          int64
+	Topic       string
+	Key         string
+	// PayloadType is the full name of the payload message.
+	PayloadType string
+	// Payload is the payload marshaled with proto.Marshal.
+	Payload     []byte
+	CreatedAt   time.Time
+}
+
+// Publisher publishes outbox events, e.g. to a message broker.
+// An event can be published more than once if marking it as sent fails,
+// so consumers should deduplicate by ID.
+type Publisher interface {
+	Publish(ctx context.Context, events []*OutboxEvent) error
+}
+
+// OutboxStorage is the storage of the outbox table.
+type OutboxStorage interface {
+{{- if .CRUDSchemas }}
+	CreateTable(ctx context.Context) error
+	DropTable(ctx context.Context) error
+	TruncateTable(ctx context.Context) error
+{{- end }}
+	// Enqueue writes an event to the outbox in the transaction of the context.
+	Enqueue(ctx context.Context, topic, key string, payload proto.Message) error
+	// FetchPending locks up to limit unsent events in the transaction of the context,
+	// skipping the events locked by other relays.
+	FetchPending(ctx context.Context, limit int) ([]*OutboxEvent, error)
+	// MarkSent marks the events as sent.
+	MarkSent(ctx context.Context, ids ...int64) error
+}
+
+// outboxStorage is a struct for the "outbox" table.
+type outboxStorage struct {
+	config *Config
+	queryBuilder sq.StatementBuilderType
+}
+
+// NewOutboxStorage returns a new outboxStorage.
+func NewOutboxStorage(config *Config) (OutboxStorage, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+	if config.DB == nil {
+		return nil, fmt.Errorf("config.DB is nil")
+	}
+	if config.DB.DBWrite == nil {
+		return nil, fmt.Errorf("config.DB.DBWrite is nil")
+	}
+
+	return &outboxStorage{
+		config: config,
+		queryBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}, nil
+}
+
+// logQuery logs the query if query logging is enabled.
+func (t *outboxStorage) logQuery(ctx context.Context, query string, args ...interface{}) {
+	if t.config.QueryLogMethod != nil {
+		t.config.QueryLogMethod(ctx, OutboxTableName, query, args...)
+	}
+}
+
+// DB returns the transaction of the context or the write connection.
+// The outbox is only read by the relay, which locks the rows, so it never uses the read connection.
+func (t *outboxStorage) DB(ctx context.Context) QueryExecer {
+	if tx, ok := TxFromContext(ctx); ok && tx != nil {
+		return &dbWrapper{db: tx, config: t.config, table: OutboxTableName}
+	}
+
+	return &dbWrapper{db: t.config.DB.DBWrite, config: t.config, table: OutboxTableName}
+}
+
+{{ if .CRUDSchemas }}
+// CreateTable creates the outbox table.
+func (t *outboxStorage) CreateTable(ctx context.Context) error {
+	sqlQuery := ` + "`" + `
+		-- Table: outbox
+		CREATE TABLE IF NOT EXISTS outbox (
+		id BIGSERIAL PRIMARY KEY,
+		topic TEXT NOT NULL,
+		event_key TEXT NOT NULL,
+		payload_type TEXT NOT NULL,
+		payload BYTEA NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		sent_at TIMESTAMPTZ
+		);
+		-- Other entities
+		CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (id) WHERE sent_at IS NULL;
+	` + "`" + `
+
+	_, err := t.DB(ctx).ExecContext(ctx, sqlQuery)
+	return err
+}
+
+// DropTable drops the outbox table.
+func (t *outboxStorage) DropTable(ctx context.Context) error {
+	sqlQuery := ` + "`" + `
+		DROP TABLE IF EXISTS outbox;
+	` + "`" + `
+
+	_, err := t.DB(ctx).ExecContext(ctx, sqlQuery)
+	return err
+}
+
+// TruncateTable truncates the outbox table.
+func (t *outboxStorage) TruncateTable(ctx context.Context) error {
+	sqlQuery := ` + "`" + `
+		TRUNCATE TABLE outbox;
+	` + "`" + `
+
+	_, err := t.DB(ctx).ExecContext(ctx, sqlQuery)
+	return err
+}
+{{ end }}
+
+// Enqueue writes an event to the outbox in the transaction of the context. It returns ErrNoTransaction
+// without one, since an event written on its own could be stored without the change it describes.
+func (t *outboxStorage) Enqueue(ctx context.Context, topic, key string, payload proto.Message) error {
+	if _, ok := TxFromContext(ctx); !ok {
+		return ErrNoTransaction
+	}
+	if payload == nil {
+		return fmt.Errorf("payload is nil")
+	}
+
+	data, err := proto.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	query := t.queryBuilder.Insert(OutboxTableName).
+		Columns("topic", "event_key", "payload_type", "payload").
+		Values(topic, key, string(proto.MessageName(payload)), data)
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	if _, err := t.DB(ctx).ExecContext(ctx, sqlQuery, args...); err != nil {
+		return fmt.Errorf("failed to enqueue event: %w", MapError(err))
+	}
+
+	return nil
+}
+
+// FetchPending locks up to limit unsent events in the transaction of the context,
+// skipping the events locked by other relays.
+func (t *outboxStorage) FetchPending(ctx context.Context, limit int) ([]*OutboxEvent, error) {
+	if _, ok := TxFromContext(ctx); !ok {
+		return nil, ErrNoTransaction
+	}
+
+	query := t.queryBuilder.Select("id", "topic", "event_key", "payload_type", "payload", "created_at").
+		From(OutboxTableName).
+		Where("sent_at IS NULL").
+		OrderBy("id").
+		Limit(uint64(limit)).
+		Suffix("FOR UPDATE SKIP LOCKED")
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	rows, err := t.DB(ctx).QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pending events: %w", MapError(err))
+	}
+	defer rows.Close()
+
+	var events []*OutboxEvent
+	for rows.Next() {
+		event := &OutboxEvent{}
+		if err := rows.Scan(&event.ID, &event.Topic, &event.Key, &event.PayloadType, &event.Payload, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", MapError(err))
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over events: %w", MapError(err))
+	}
+
+	return events, nil
+}
+
+// MarkSent marks the events as sent.
+func (t *outboxStorage) MarkSent(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := t.queryBuilder.Update(OutboxTableName).
+		Set("sent_at", sq.Expr("now()")).
+		Where(sq.Eq{"id": ids})
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	if _, err := t.DB(ctx).ExecContext(ctx, sqlQuery, args...); err != nil {
+		return fmt.Errorf("failed to mark events as sent: %w", MapError(err))
+	}
+
+	return nil
+}
+
+// outboxDefaultBatchSize is the default maximum number of events per batch of a relay.
+const outboxDefaultBatchSize = 100
+
+// OutboxRelay moves the events of the outbox to a Publisher.
+// Several relays can run at once, each batch is locked by one of them.
+type OutboxRelay struct {
+	tx        *TxManager
+	outbox    OutboxStorage
+	publisher Publisher
+	batchSize int
+	interval  time.Duration
+	onError   func(ctx context.Context, err error)
+}
+
+// NewOutboxRelay returns a relay publishing batches of 100 events, polling every second when idle.
+func NewOutboxRelay(storages {{ storageName }}, publisher Publisher) *OutboxRelay {
+	return &OutboxRelay{
+		tx:        storages.TxManager(),
+		outbox:    storages.GetOutboxStorage(),
+		publisher: publisher,
+		batchSize: outboxDefaultBatchSize,
+		interval:  time.Second,
+	}
+}
+
+// WithBatchSize sets the maximum number of events per batch. Sizes below 1 reset it to the default of 100.
+func (r *OutboxRelay) WithBatchSize(size int) *OutboxRelay {
+	if size < 1 {
+		size = outboxDefaultBatchSize
+	}
+	r.batchSize = size
+	return r
+}
+
+// WithInterval sets how long the relay waits when the outbox is empty.
+func (r *OutboxRelay) WithInterval(interval time.Duration) *OutboxRelay {
+	r.interval = interval
+	return r
+}
+
+// WithErrorHandler sets the handler of the errors of the failed batches in Run.
+func (r *OutboxRelay) WithErrorHandler(handler func(ctx context.Context, err error)) *OutboxRelay {
+	r.onError = handler
+	return r
+}
+
+// RelayOnce publishes one batch of pending events and marks them as sent.
+// It returns the number of published events.
+func (r *OutboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	var published int
+	err := r.tx.ExecFuncWithTx(ctx, func(ctx context.Context) error {
+		events, err := r.outbox.FetchPending(ctx, r.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		if err := r.publisher.Publish(ctx, events); err != nil {
+			return fmt.Errorf("failed to publish events: %w", err)
+		}
+
+		ids := make([]int64, 0, len(events))
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+		if err := r.outbox.MarkSent(ctx, ids...); err != nil {
+			return err
+		}
+
+		published = len(events)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return published, nil
+}
+
+// Run relays batches until the context is done. Full batches are followed immediately by the next one.
+// A failed batch goes to the error handler and stays pending, it is retried after the interval.
+func (r *OutboxRelay) Run(ctx context.Context) error {
+	for {
+		published, err := r.RelayOnce(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			if r.onError != nil {
+				r.onError(ctx, err)
+			}
+		case published > 0 && published == r.batchSize:
+			continue
+		}
+
+		timer := time.NewTimer(r.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+`
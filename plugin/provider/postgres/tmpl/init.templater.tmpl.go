@@ -36,6 +36,38 @@ const InitStatementTemplate = `
 
 {{ template "transaction" . }}
 
+//
+// Read replicas.
+//
+
+{{ template "replicas" . }}
+
+//
+// Query interceptors.
+//
+
+{{ template "interceptors" . }}
+
+//
+// Slow queries.
+//
+
+{{ template "slowquery" . }}
+{{ if .Otel }}
+//
+// OpenTelemetry tracing.
+//
+
+{{ template "tracing" . }}
+{{ end }}
+{{- if .Metrics }}
+//
+// Prometheus metrics.
+//
+
+{{ template "metrics" . }}
+{{ end }}
+
 //
 // Options.
 // 
@@ -45,6 +77,13 @@ const InitStatementTemplate = `
 // Conditions for query builder.
 // 
 {{ template "conditions" . }}
+{{ if .Outbox }}
+//
+// Transactional outbox.
+//
+
+{{ template "outbox" . }}
+{{ end }}
 `
 
 const OptionsTemplate = `
@@ -57,6 +96,10 @@ type Options struct {
 	relations bool
 	// ignoreConflictField is the field to ignore conflict.
 	ignoreConflictField string
+	// mustAffect turns zero affected rows into ErrNotFound.
+	mustAffect bool
+	// preloads are the relations to load with the found rows.
+	preloads []Relation
 	// uniqField is the unique field.
 	uniqField string
 }
@@ -83,6 +126,33 @@ func WithIgnoreConflictField(field string) Option {
 	}
 }
 
+// WithMustAffect makes Update, UpdateMany and the delete methods return ErrNotFound
+// when no rows were affected.
+func WithMustAffect() Option {
+	return func(o *Options) {
+		o.mustAffect = true
+	}
+}
+
+// WithPreload loads the given relations together with the found rows.
+func WithPreload(relations ...Relation) Option {
+	return func(o *Options) {
+		o.preloads = append(o.preloads, relations...)
+	}
+}
+
+// rowsAffected returns the number of rows affected by the result.
+func rowsAffected(result sql.Result, options *Options) (int64, error) {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", MapError(err))
+	}
+	if options.mustAffect && affected == 0 {
+		return 0, ErrNotFound
+	}
+	return affected, nil
+}
+
 // FilterApplier is a condition filters.
 type FilterApplier interface {
 	Apply(query sq.SelectBuilder) sq.SelectBuilder
@@ -104,6 +174,12 @@ type QueryBuilder struct {
 	sortOptions  []FilterApplier
 	// pagination is the pagination.
 	pagination    *Pagination
+	// preloads are the relations to load with the found rows.
+	preloads      []Relation
+	// limitPerParent limits the related rows loaded for every parent row.
+	limitPerParent *uint64
+	// partitionBy is the column of the parent row, set by the relation loaders.
+	partitionBy string
 	// customFilters are the custom filters.
 	customFilters []struct {
 		filter CustomFilter
@@ -142,6 +218,157 @@ func nullValue[T any](v *T) interface{} {
 	return v
 }
 
+// copyValue converts a value for the COPY protocol.
+// JSON and array columns are encoded by their Value() methods and sent as text,
+// since COPY would write raw bytes as bytea.
+func copyValue(v interface{}) (interface{}, error) {
+	valuer, ok := v.(driver.Valuer)
+	if !ok {
+		return v, nil
+	}
+	value, err := valuer.Value()
+	if err != nil {
+		return nil, err
+	}
+	if b, ok := value.([]byte); ok {
+		return string(b), nil
+	}
+	return value, nil
+}
+
+// copyConn is a database whose connections can run a pgx COPY.
+type copyConn interface {
+	Driver() driver.Driver
+	Conn(ctx context.Context) (*sql.Conn, error)
+}
+
+// copyIn runs a COPY of the rows returned by next into the columns of the table, through the interceptors
+// and the slow query reporting. It uses pgx CopyFrom when DBWrite runs on pgx/stdlib and lib/pq otherwise.
+func (c *Config) copyIn(ctx context.Context, table string, columns []string, next func() ([]interface{}, bool, error)) (int64, error) {
+	query := &Query{
+		Table:     table,
+		Operation: OperationCreate,
+		SQL:       fmt.Sprintf("COPY %s (%s) FROM STDIN", table, strings.Join(columns, ", ")),
+	}
+	result, err := intercept(ctx, c.queryInterceptors(), query, c.slowQueries(nil, func(ctx context.Context, _ *Query) (*QueryResult, error) {
+		var copied int64
+		var err error
+		if db, ok := interface{}(c.DB.DBWrite).(copyConn); ok {
+			if _, ok := db.Driver().(*stdlib.Driver); ok {
+				copied, err = pgxCopyIn(ctx, db, table, columns, next)
+				return &QueryResult{Result: driver.RowsAffected(copied)}, err
+			}
+		}
+		copied, err = c.pqCopyIn(ctx, table, columns, next)
+		return &QueryResult{Result: driver.RowsAffected(copied)}, err
+	}))
+	if err != nil {
+		return 0, err
+	}
+	if result.Result == nil {
+		return 0, errQueryNotRun
+	}
+	return result.Result.RowsAffected()
+}
+
+// pqCopyIn runs a lib/pq COPY in the transaction from the context or in a new one.
+func (c *Config) pqCopyIn(ctx context.Context, table string, columns []string, next func() ([]interface{}, bool, error)) (int64, error) {
+	var copied int64
+	err := c.txManager().ExecFuncWithTx(ctx, func(ctx context.Context) error {
+		tx, ok := TxFromContext(ctx)
+		if !ok {
+			return ErrNoTransaction
+		}
+
+		stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+		if err != nil {
+			return fmt.Errorf("failed to prepare copy: %w", MapError(err))
+		}
+		defer func() {
+			if err := stmt.Close(); err != nil && c.ErrorLogMethod != nil {
+				c.ErrorLogMethod(ctx, err, "failed to close copy statement")
+			}
+		}()
+
+		for {
+			values, ok, err := next()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			if _, err := stmt.ExecContext(ctx, values...); err != nil {
+				return fmt.Errorf("failed to copy %s: %w", table, MapError(err))
+			}
+			copied++
+		}
+
+		// flush the buffered rows
+		if _, err := stmt.ExecContext(ctx); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", table, MapError(err))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return copied, nil
+}
+
+// pgxCopyIn runs a pgx CopyFrom on a connection of its own. database/sql doesn't expose the connection
+// of a transaction, so it returns ErrCopyInTransaction when the context holds one.
+func pgxCopyIn(ctx context.Context, db copyConn, table string, columns []string, next func() ([]interface{}, bool, error)) (int64, error) {
+	if _, ok := TxFromContext(ctx); ok {
+		return 0, ErrCopyInTransaction
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get connection: %w", MapError(err))
+	}
+	defer conn.Close()
+
+	var copied int64
+	err = conn.Raw(func(driverConn interface{}) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("unexpected pgx connection %T", driverConn)
+		}
+		var err error
+		copied, err = stdlibConn.Conn().CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns, &copySource{next: next})
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy %s: %w", table, MapError(err))
+	}
+	return copied, nil
+}
+
+// copySource is a pgx.CopyFromSource reading the rows from next.
+type copySource struct {
+	next   func() ([]interface{}, bool, error)
+	values []interface{}
+	err    error
+}
+
+// Next implements pgx.CopyFromSource interface.
+func (s *copySource) Next() bool {
+	var ok bool
+	s.values, ok, s.err = s.next()
+	return ok && s.err == nil
+}
+
+// Values implements pgx.CopyFromSource interface.
+func (s *copySource) Values() ([]interface{}, error) {
+	return s.values, nil
+}
+
+// Err implements pgx.CopyFromSource interface.
+func (s *copySource) Err() error {
+	return s.err
+}
+
 // ApplyCustomFilters applies the custom filters to the query.
 func (qb *QueryBuilder) ApplyCustomFilters(query sq.SelectBuilder) sq.SelectBuilder {
 	for _, cf := range qb.customFilters {
@@ -168,6 +395,20 @@ func (b *QueryBuilder) WithPagination(pagination *Pagination) *QueryBuilder {
 	return b
 }
 
+// Preload adds relations to load together with the found rows.
+// Each relation is loaded with a single query per level, whatever the number of rows.
+func (b *QueryBuilder) Preload(relations ...Relation) *QueryBuilder {
+	b.preloads = append(b.preloads, relations...)
+	return b
+}
+
+// LimitPerParent limits the related rows loaded for every parent row, e.g. the latest 3 posts of every user.
+// It is supported by the LoadBatch relation loaders and Preload, the sort options order the rows of every parent.
+func (b *QueryBuilder) LimitPerParent(limit uint64) *QueryBuilder {
+	b.limitPerParent = &limit
+	return b
+}
+
 // Filter is a helper function to create a new query builder with filter options.
 func FilterBuilder(filterOptions ...FilterApplier) *QueryBuilder {
 	return NewQueryBuilder().WithFilter(filterOptions...)
@@ -197,6 +438,88 @@ func PaginateBuilder(limit, offset uint64) *QueryBuilder {
 	return NewQueryBuilder().WithPagination(NewPagination(limit, offset))
 }
 
+// PreloadBuilder is a helper function to create a new query builder with preloads.
+func PreloadBuilder(relations ...Relation) *QueryBuilder {
+	return NewQueryBuilder().Preload(relations...)
+}
+
+// LimitPerParentBuilder is a helper function to create a new query builder with a limit per parent row.
+func LimitPerParentBuilder(limit uint64) *QueryBuilder {
+	return NewQueryBuilder().LimitPerParent(limit)
+}
+
+// partitionBuilder returns a query builder which counts the limit per parent row on the column.
+func partitionBuilder(column string) *QueryBuilder {
+	builder := NewQueryBuilder()
+	builder.partitionBy = column
+	return builder
+}
+
+// Relation identifies a relation of a table, e.g. UserRelPosts.
+// Use Then to preload nested relations and With to filter or sort the related rows.
+type Relation struct {
+	// name is the relation name, "<Structure>.<Field>".
+	name string
+	// builders are applied when the related rows are loaded.
+	builders []*QueryBuilder
+}
+
+// Then returns a copy of the relation that also preloads the given relations of the related rows.
+func (r Relation) Then(relations ...Relation) Relation {
+	return r.With(PreloadBuilder(relations...))
+}
+
+// With returns a copy of the relation that loads the related rows with the given builders.
+func (r Relation) With(builders ...*QueryBuilder) Relation {
+	r.builders = append(append([]*QueryBuilder{}, r.builders...), builders...)
+	return r
+}
+
+// String returns the relation name.
+func (r Relation) String() string {
+	return r.name
+}
+
+// mergeRelations merges relations with the same name, so each relation is loaded once.
+func mergeRelations(relations []Relation) []Relation {
+	merged := make([]Relation, 0, len(relations))
+	index := make(map[string]int, len(relations))
+	for _, r := range relations {
+		if i, ok := index[r.name]; ok {
+			merged[i] = merged[i].With(r.builders...)
+			continue
+		}
+		index[r.name] = len(merged)
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// limitPerParentQuery wraps the query to keep at most limit rows for every value of the column.
+// The sort options order the rows of every parent and the result.
+func limitPerParentQuery(builder sq.StatementBuilderType, query sq.SelectBuilder, columns []string, column string, limit uint64, sortOptions []FilterApplier) (sq.SelectBuilder, error) {
+	orderBy := column
+	var orderArgs []interface{}
+	if len(sortOptions) > 0 {
+		sortQuery := sq.Select("*")
+		for _, option := range sortOptions {
+			sortQuery = option.Apply(sortQuery)
+		}
+		sortSQL, sortArgs, err := sortQuery.ToSql()
+		if err != nil {
+			return query, fmt.Errorf("failed to build sort: %w", err)
+		}
+		orderBy = strings.TrimPrefix(sortSQL, "SELECT * ORDER BY ")
+		orderArgs = sortArgs
+	}
+
+	partitioned := query.Column("ROW_NUMBER() OVER (PARTITION BY "+column+" ORDER BY "+orderBy+") AS partition_row", orderArgs...)
+	return builder.Select(columns...).
+		FromSelect(partitioned, "partitioned").
+		Where("partition_row <= ?", limit).
+		OrderByClause(orderBy, orderArgs...), nil
+}
+
 // Pagination is the pagination.
 type Pagination struct {
 	// limit is the limit.
@@ -346,6 +669,9 @@ type {{ storageName | lowerCamelCase }} struct {
 	tx *TxManager  // The transaction manager.
 {{ range $value := storages }}
 {{ $value.Key }} {{ $value.Value }}{{ end }}
+{{- if .Outbox }}
+	outbox OutboxStorage // The outbox storage.
+{{- end }}
 }
 
 // configuration for the {{ storageName }}.
@@ -354,6 +680,29 @@ type Config struct {
 
 	QueryLogMethod    func(ctx context.Context, table string, query string, args ...interface{})
 	ErrorLogMethod    func(ctx context.Context, err error, message string)
+
+	// Interceptors wrap every query, the first one being the outermost.
+	Interceptors []QueryInterceptor
+	// SlowQueryThreshold is the duration from which a query is reported to SlowQueryHandler.
+	// Zero reports no query.
+	SlowQueryThreshold time.Duration
+	// SlowQueryHandler is called with the queries slower than SlowQueryThreshold.
+	SlowQueryHandler SlowQueryHandler
+	// ExplainSlowQueries attaches the plan of the slow queries, explained on the connection that ran them.
+	ExplainSlowQueries bool
+{{- if .Otel }}
+	// TracerProvider creates the spans of the queries and transactions.
+	// Defaults to the global provider.
+	TracerProvider trace.TracerProvider
+{{- end }}
+{{- if .Metrics }}
+	// Metrics collects the metrics of the queries and transactions, nil to collect none.
+	Metrics *Metrics
+{{- end }}
+
+	// Clock returns the current time for auto_create_time and auto_update_time fields.
+	// Defaults to time.Now.
+	Clock func() time.Time
 }
 
 {{ if .UseSQLX }}
@@ -369,6 +718,7 @@ type DBReadConnection interface {
 type DBWriteConnection interface {
 	DBReadConnection
 	Begin() (*sql.Tx, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
 }
 {{ end }}
 
@@ -380,6 +730,11 @@ type DB struct {
 	DBRead *sql.DB
 	DBWrite *sql.DB
 {{ end }}
+	// Replicas serve the reads outside transactions instead of DBRead.
+	// When none of them is healthy, the reads go to DBWrite.
+	Replicas []*Replica
+	// ReplicaSelector picks one of the healthy replicas. Defaults to round-robin.
+	ReplicaSelector ReplicaSelector
 }
 
 // {{ storageName }} is the interface for the {{ storageName }}.
@@ -390,6 +745,12 @@ type {{ storageName }} interface {
 	{{- end }}
 	// TxManager returns the transaction manager.
 	TxManager() *TxManager
+{{- if .Outbox }}
+	// GetOutboxStorage returns the outbox store.
+	GetOutboxStorage() OutboxStorage
+	// EnqueueEvent writes an event to the outbox in the transaction of the context.
+	EnqueueEvent(ctx context.Context, topic, key string, payload proto.Message) error
+{{- end }}
 
 {{ if .CRUDSchemas }}
 	// CreateTables creates the tables for all the stores.
@@ -431,7 +792,7 @@ func New{{ storageName }}(config *Config) ({{ storageName }}, error) {
 	
 	var storages = {{ storageName | lowerCamelCase }}{
 		config: config,
-		tx: NewTxManager(config.DB.DBWrite),
+		tx: NewTxManager(config.DB.DBWrite).WithReadDB(config.DB.DBRead){{ if .Otel }}.WithTracerProvider(config.TracerProvider){{ end }}{{ if .Metrics }}.WithMetrics(config.Metrics){{ end }},
 	}
 {{ range $value := storages }}
 	{{ $value.Key }}Impl, err := New{{ $value.Value }}(config)
@@ -440,6 +801,13 @@ func New{{ storageName }}(config *Config) ({{ storageName }}, error) {
 	}
 	storages.{{ $value.Key }} = {{ $value.Key }}Impl
 {{ end }}
+{{- if .Outbox }}
+	outboxImpl, err := NewOutboxStorage(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OutboxStorage: %w", err)
+	}
+	storages.outbox = outboxImpl
+{{ end }}
 
 	return &storages, nil
 }
@@ -448,6 +816,18 @@ func New{{ storageName }}(config *Config) ({{ storageName }}, error) {
 func (c *{{ storageName | lowerCamelCase }}) TxManager() *TxManager {
 	return c.tx
 }
+{{ if .Outbox }}
+// GetOutboxStorage returns the outbox store.
+func (c *{{ storageName | lowerCamelCase }}) GetOutboxStorage() OutboxStorage {
+	return c.outbox
+}
+
+// EnqueueEvent writes an event to the outbox in the transaction of the context, and returns ErrNoTransaction
+// without one. Enqueue it in the same transaction as the change it describes, so both are committed or neither.
+func (c *{{ storageName | lowerCamelCase }}) EnqueueEvent(ctx context.Context, topic, key string, payload proto.Message) error {
+	return c.outbox.Enqueue(ctx, topic, key, payload)
+}
+{{ end }}
 
 {{ range $value := storages }}
 // Get{{ $value.Value }} returns the {{ $value.Value }} store.
@@ -467,6 +847,13 @@ func (c *{{ storageName | lowerCamelCase }}) CreateTables(ctx context.Context) e
 	if err != nil {
 		return fmt.Errorf("failed to create table: %w", err)
 	}
+{{ end }}
+{{- if .Outbox }}
+	// create the outbox table.
+	err = c.outbox.CreateTable(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
 {{ end }}
 	return nil
 }
@@ -481,6 +868,13 @@ func (c *{{ storageName | lowerCamelCase }}) DropTables(ctx context.Context) err
 	if err != nil {
 		return fmt.Errorf("failed to drop table: %w", err)
 	}
+{{ end }}
+{{- if .Outbox }}
+	// drop the outbox table.
+	err = c.outbox.DropTable(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to drop table: %w", err)
+	}
 {{ end }}
 	return nil
 }
@@ -495,6 +889,13 @@ func (c *{{ storageName | lowerCamelCase }}) TruncateTables(ctx context.Context)
 	if err != nil {
 		return fmt.Errorf("failed to truncate table: %w", err)
 	}
+{{ end }}
+{{- if .Outbox }}
+	// truncate the outbox table.
+	err = c.outbox.TruncateTable(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to truncate table: %w", err)
+	}
 {{ end }}
 	return nil
 }
@@ -739,13 +1140,143 @@ func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
 	return tx, ok
 }
 
+// savepointKey is the key used to store the innermost savepoint in the context.
+type savepointKey struct{}
+
+// savepoint is a nested transaction, begun inside an open transaction.
+type savepoint struct {
+	// level is 1 for the first nested transaction.
+	level int
+	// done is set once the savepoint was released or rolled back.
+	done bool
+}
+
+// name returns the name of the savepoint.
+func (s *savepoint) name() string {
+	return fmt.Sprintf("sp_%d", s.level)
+}
+
+// txHooksKey is the key used to store the hooks of the innermost transaction in the context.
+type txHooksKey struct{}
+
+// txHooks are the callbacks registered with OnCommit and OnRollback on a transaction or savepoint.
+type txHooks struct {
+	// parent is the hooks of the enclosing transaction, nil for the outermost one.
+	parent   *txHooks
+	commit   []func(context.Context)
+	rollback []func(context.Context)
+	// done is set once the hooks were run or handed over to the parent.
+	done bool
+}
+
+// hooksFromContext returns the hooks of the innermost transaction in the context.
+func hooksFromContext(ctx context.Context) *txHooks {
+	h, _ := ctx.Value(txHooksKey{}).(*txHooks)
+	return h
+}
+
+// run runs the commit or the rollback hooks in the order they were registered.
+// The hooks run at most once, outside of the transaction.
+func (h *txHooks) run(ctx context.Context, committed bool) {
+	if h == nil || h.done {
+		return
+	}
+	h.done = true
+
+	hooks := h.rollback
+	if committed {
+		hooks = h.commit
+	}
+
+	ctx = withoutTx(ctx)
+	for _, f := range hooks {
+		f(ctx)
+	}
+}
+
+// release hands the hooks of a released savepoint over to the enclosing transaction.
+func (h *txHooks) release() {
+	if h == nil || h.done || h.parent == nil {
+		return
+	}
+	h.done = true
+
+	h.parent.commit = append(h.parent.commit, h.commit...)
+	h.parent.rollback = append(h.parent.rollback, h.rollback...)
+}
+
+// withoutTx returns a context without the transaction, its savepoints and hooks.
+func withoutTx(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, txKey{}, nil)
+	ctx = context.WithValue(ctx, savepointKey{}, nil)
+	return context.WithValue(ctx, txHooksKey{}, nil)
+}
+
+// OnCommit registers f to run after the transaction in the context is committed.
+// Hooks registered in a nested transaction wait for the outermost commit
+// and are dropped when the nested transaction is rolled back.
+// Outside a transaction f runs immediately.
+func OnCommit(ctx context.Context, f func(context.Context)) {
+	if h := hooksFromContext(ctx); h != nil && !h.done {
+		h.commit = append(h.commit, f)
+		return
+	}
+	f(ctx)
+}
+
+// OnRollback registers f to run after the transaction in the context is rolled back,
+// or after a nested transaction is rolled back to its savepoint.
+// Outside a transaction f runs immediately.
+func OnRollback(ctx context.Context, f func(context.Context)) {
+	if h := hooksFromContext(ctx); h != nil && !h.done {
+		h.rollback = append(h.rollback, f)
+		return
+	}
+	f(ctx)
+}
+
+// TxDepth returns the transaction depth of the context:
+// 0 without a transaction, 1 in a transaction and one more for every nested transaction.
+func TxDepth(ctx context.Context) int {
+	if _, ok := TxFromContext(ctx); !ok {
+		return 0
+	}
+	if sp, ok := ctx.Value(savepointKey{}).(*savepoint); ok {
+		return sp.level + 1
+	}
+	return 1
+}
+
+// TxOptions are the options of a transaction.
+type TxOptions struct {
+	// Isolation is the isolation level, the driver default if zero.
+	Isolation sql.IsolationLevel
+	// ReadOnly begins a read-only transaction, on the read connection if the TxManager has one.
+	ReadOnly bool
+	// Deferrable makes a serializable read-only transaction wait for a snapshot
+	// that can't fail with a serialization error.
+	Deferrable bool
+}
+
 // TxManager is a transaction manager.
 type TxManager struct {
 {{ if .UseSQLX }}
 	db DBWriteConnection
+	// read begins the read-only transactions if it can begin transactions, db is used otherwise.
+	read DBReadConnection
 {{ else }}
 	db *sql.DB
+	// read begins the read-only transactions, db is used if nil.
+	read *sql.DB
 {{ end }}
+{{- if .Otel }}
+	// tracerProvider creates the spans of the transactions.
+	tracerProvider trace.TracerProvider
+{{- end }}
+{{- if .Metrics }}
+	// metrics counts the committed and rolled back transactions.
+	metrics *Metrics
+{{- end }}
 }
 
 // NewTxManager creates a new transaction manager.
@@ -759,39 +1290,197 @@ func NewTxManager(db *sql.DB) *TxManager {
 	}
 }
 
+// WithReadDB returns a transaction manager beginning the read-only transactions on db.
+{{ if .UseSQLX }}
+func (m *TxManager) WithReadDB(db DBReadConnection) *TxManager {
+{{ else }}
+func (m *TxManager) WithReadDB(db *sql.DB) *TxManager {
+{{ end }}
+	return &TxManager{
+		db:   m.db,
+		read: db,
+		{{- if .Otel }}
+		tracerProvider: m.tracerProvider,
+		{{- end }}
+		{{- if .Metrics }}
+		metrics: m.metrics,
+		{{- end }}
+	}
+}
+{{ if .Otel }}
+// WithTracerProvider returns a transaction manager creating the spans of the transactions with provider.
+func (m *TxManager) WithTracerProvider(provider trace.TracerProvider) *TxManager {
+	return &TxManager{
+		db:             m.db,
+		read:           m.read,
+		tracerProvider: provider,
+		{{- if .Metrics }}
+		metrics:        m.metrics,
+		{{- end }}
+	}
+}
+{{ end }}
+{{- if .Metrics }}
+// WithMetrics returns a transaction manager counting its transactions in metrics.
+func (m *TxManager) WithMetrics(metrics *Metrics) *TxManager {
+	return &TxManager{
+		db:             m.db,
+		read:           m.read,
+		{{- if .Otel }}
+		tracerProvider: m.tracerProvider,
+		{{- end }}
+		metrics:        metrics,
+	}
+}
+{{ end }}
+
+// txManager returns the transaction manager the storages use for their own transactions.
+func (c *Config) txManager() *TxManager {
+	return NewTxManager(c.DB.DBWrite){{ if .Otel }}.WithTracerProvider(c.TracerProvider){{ end }}{{ if .Metrics }}.WithMetrics(c.Metrics){{ end }}
+}
+
+// beginner returns the connection to begin a transaction with the options on.
+func (m *TxManager) beginner(opts TxOptions) interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+} {
+	if opts.ReadOnly && m.read != nil {
+		{{- if .UseSQLX }}
+		if read, ok := m.read.(interface {
+			BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+		}); ok {
+			return read
+		}
+		{{- else }}
+		return m.read
+		{{- end }}
+	}
+	return m.db
+}
+
 // Begin begins a transaction.
+// Inside an open transaction it creates a savepoint, which Commit releases and Rollback rolls back to.
 func (m *TxManager) Begin(ctx context.Context) (context.Context, error) {
-	if _, ok := TxFromContext(ctx); ok {
-		return ctx, nil
+	return m.BeginTx(ctx, TxOptions{})
+}
+
+// BeginTx begins a transaction with the options. The transaction is rolled back if ctx is canceled.
+// Inside an open transaction it creates a savepoint and the options are ignored.
+func (m *TxManager) BeginTx(ctx context.Context, opts TxOptions) (context.Context, error) {
+	if tx, ok := TxFromContext(ctx); ok {
+		sp := &savepoint{level: TxDepth(ctx)}
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT "+sp.name()); err != nil {
+			return ctx, fmt.Errorf("could not create savepoint: %w", err)
+		}
+
+		// store the savepoint and its hooks in the context.
+		ctx = context.WithValue(ctx, savepointKey{}, sp)
+		return context.WithValue(ctx, txHooksKey{}, &txHooks{parent: hooksFromContext(ctx)}), nil
 	}
 
-	tx, err := m.db.Begin()
+	{{- if .Otel }}
+	ctx = m.startTxSpan(ctx)
+	{{- end }}
+	tx, err := m.beginner(opts).BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
 	if err != nil {
+		{{- if .Otel }}
+		endTxSpan(ctx, "begin", err)
+		{{- end }}
 		return ctx, fmt.Errorf("could not begin transaction: %w", err)
 	}
+	if opts.Deferrable {
+		if _, err := tx.ExecContext(ctx, "SET TRANSACTION DEFERRABLE"); err != nil {
+			_ = tx.Rollback()
+			{{- if .Otel }}
+			endTxSpan(ctx, "begin", err)
+			{{- end }}
+			return ctx, fmt.Errorf("could not set transaction deferrable: %w", err)
+		}
+	}
 
-	// store the transaction in the context.
-	return context.WithValue(ctx, txKey{}, tx), nil
+	// store the transaction and its hooks in the context.
+	ctx = context.WithValue(ctx, txKey{}, tx)
+	return context.WithValue(ctx, txHooksKey{}, &txHooks{}), nil
 }
 
-// IsTxOpen returns true if a transaction is open.
+// Commit commits a transaction or releases the savepoint of a nested one.
+// The OnCommit hooks run after the outermost transaction is committed.
 func (m *TxManager) Commit(ctx context.Context) error {
 	tx, ok := TxFromContext(ctx)
 	if !ok {
 		return fmt.Errorf("transactions wasn't opened")
 	}
 
+	if sp, ok := ctx.Value(savepointKey{}).(*savepoint); ok {
+		if sp.done {
+			return fmt.Errorf("savepoint %s was already closed", sp.name())
+		}
+		sp.done = true
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+sp.name()); err != nil {
+			// drop the changes made since the savepoint, the outer transaction goes on without them.
+			if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+sp.name()); rbErr != nil {
+				return fmt.Errorf("could not release savepoint: %w, and failed to rollback to it: %v", err, rbErr)
+			}
+			hooksFromContext(ctx).run(ctx, false)
+			return fmt.Errorf("could not release savepoint: %w", err)
+		}
+		hooksFromContext(ctx).release()
+		return nil
+	}
+
 	if err := tx.Commit(); err != nil {
+		{{- if .Otel }}
+		endTxSpan(ctx, "commit", err)
+		{{- end }}
+		{{- if .Metrics }}
+		m.metrics.observeTx(txOutcomeRollback)
+		{{- end }}
+		hooksFromContext(ctx).run(ctx, false)
 		return fmt.Errorf("could not commit transaction: %w", err)
 	}
+	{{- if .Otel }}
+	endTxSpan(ctx, "commit", nil)
+	{{- end }}
+	{{- if .Metrics }}
+	m.metrics.observeTx(txOutcomeCommit)
+	{{- end }}
+	hooksFromContext(ctx).run(ctx, true)
 
 	return nil
 }
 
-// Rollback rolls back a transaction.
+// Rollback rolls back a transaction or, in a nested one, the changes made since its savepoint.
+// Rolling back a closed savepoint does nothing.
 func (m *TxManager) Rollback(ctx context.Context) error {
+	if sp, ok := ctx.Value(savepointKey{}).(*savepoint); ok {
+		if sp.done {
+			return nil
+		}
+		sp.done = true
+
+		tx, _ := TxFromContext(ctx)
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+sp.name()); err != nil {
+			return fmt.Errorf("failed to rollback to savepoint: %w", err)
+		}
+		hooksFromContext(ctx).run(ctx, false)
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+sp.name()); err != nil {
+			return fmt.Errorf("failed to release savepoint: %w", err)
+		}
+		return nil
+	}
+
 	if tx, ok := TxFromContext(ctx); ok {
 		err := tx.Rollback()
+		{{- if .Otel }}
+		if !errors.Is(err, sql.ErrTxDone) {
+			endTxSpan(ctx, "rollback", err)
+		}
+		{{- end }}
+		{{- if .Metrics }}
+		if err == nil {
+			m.metrics.observeTx(txOutcomeRollback)
+		}
+		{{- end }}
+		hooksFromContext(ctx).run(ctx, false)
 		if err != nil && err != sql.ErrTxDone {
 			return fmt.Errorf("failed to rollback transaction: %w", err)
 		}
@@ -801,13 +1490,14 @@ func (m *TxManager) Rollback(ctx context.Context) error {
 }
 
 // ExecFuncWithTx executes a function with a transaction.
+// Inside an open transaction the function runs in a nested one, so its failure only rolls back its own changes.
 func (m *TxManager) ExecFuncWithTx(ctx context.Context, f func(context.Context) error) error {
-	// if a transaction is already open, just execute the function.
-	if m.IsTxOpen(ctx) {
-		return f(ctx)
-	}
+	return m.ExecFuncWithTxOptions(ctx, TxOptions{}, f)
+}
 
-	ctx, err := m.Begin(ctx)
+// ExecFuncWithTxOptions executes a function with a transaction begun with the options.
+func (m *TxManager) ExecFuncWithTxOptions(ctx context.Context, opts TxOptions, f func(context.Context) error) error {
+	ctx, err := m.BeginTx(ctx, opts)
 	if err != nil {
 		return err
 	}
@@ -826,6 +1516,89 @@ func (m *TxManager) ExecFuncWithTx(ctx context.Context, f func(context.Context)
 	return nil
 }
 
+// RetryPolicy configures ExecFuncWithRetry.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of runs of the function, 3 if zero.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled for every next one; 50ms if zero.
+	// A random jitter of up to half the delay is subtracted.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two runs, 1s if zero.
+	MaxDelay time.Duration
+	// Codes are the retryable SQLSTATE codes, serialization failures and deadlocks if empty.
+	Codes []string
+	// TxOptions are the options of the transactions.
+	TxOptions TxOptions
+}
+
+// retryable returns true if the error has one of the retryable codes.
+func (p RetryPolicy) retryable(err error) bool {
+	codes := p.Codes
+	if len(codes) == 0 {
+		codes = []string{errPgSerializationFailure, errPgDeadlockDetected}
+	}
+
+	code := pgErrorCode(err)
+	if code == "" {
+		return false
+	}
+	for _, c := range codes {
+		if code == c {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the delay before the next run after the given attempt.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	base, limit := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = 50 * time.Millisecond
+	}
+	if limit <= 0 {
+		limit = time.Second
+	}
+
+	delay := base
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return delay - time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// ExecFuncWithRetry executes a function with a transaction and, while it fails with a retryable error,
+// runs it again in a fresh transaction. Inside an open transaction the function runs once,
+// because only the outer transaction can be restarted.
+func (m *TxManager) ExecFuncWithRetry(ctx context.Context, policy RetryPolicy, f func(context.Context) error) error {
+	if m.IsTxOpen(ctx) {
+		return m.ExecFuncWithTxOptions(ctx, policy.TxOptions, f)
+	}
+
+	attempts := policy.MaxAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := m.ExecFuncWithTxOptions(ctx, policy.TxOptions, f)
+		if err == nil || attempt >= attempts || !policy.retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(policy.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
 // IsTxOpen returns true if a transaction is open.
 func (m *TxManager) IsTxOpen(ctx context.Context) bool {
 	_, ok := TxFromContext(ctx)
@@ -840,9 +1613,26 @@ type QueryExecer interface {
 }
 
 // dbWrapper wraps DB connections to implement QueryExecer interface.
+// It runs the queries through the interceptors of the config.
 type dbWrapper struct {
 	db QueryExecer
 	config *Config
+	table string
+}
+
+// intercept runs a query through the interceptors of the config.
+func (w *dbWrapper) intercept(ctx context.Context, query string, args []interface{}, exec QueryHandler) (*QueryResult, error) {
+	var interceptors []QueryInterceptor
+	if w.config != nil {
+		interceptors = w.config.queryInterceptors()
+	}
+
+	return intercept(ctx, interceptors, &Query{
+		Table:     w.table,
+		Operation: queryOperation(ctx, query),
+		SQL:       query,
+		Args:      args,
+	}, w.config.slowQueries(w.db, exec))
 }
 
 // addNonce adds a unique SQL comment (nonce) to prevent prepared statement caching.
@@ -873,7 +1663,11 @@ func (w *dbWrapper) QueryContext(ctx context.Context, query string, args ...inte
 		w.config.QueryLogMethod(ctx, "sql", query, args...)
 	}
 	
-	return w.db.QueryContext(ctx, query, args...)
+	result, err := w.intercept(ctx, query, args, func(ctx context.Context, q *Query) (*QueryResult, error) {
+		rows, err := w.db.QueryContext(ctx, q.SQL, q.Args...)
+		return &QueryResult{Rows: rows}, MapError(err)
+	})
+	return result.Rows, err
 }
 
 // ExecContext implements QueryExecer interface.
@@ -885,7 +1679,11 @@ func (w *dbWrapper) ExecContext(ctx context.Context, query string, args ...inter
 		w.config.QueryLogMethod(ctx, "sql", query, args...)
 	}
 	
-	return w.db.ExecContext(ctx, query, args...)
+	result, err := w.intercept(ctx, query, args, func(ctx context.Context, q *Query) (*QueryResult, error) {
+		res, err := w.db.ExecContext(ctx, q.SQL, q.Args...)
+		return &QueryResult{Result: res}, MapError(err)
+	})
+	return result.Result, err
 }
 
 // QueryRowContext implements QueryExecer interface.
@@ -897,7 +1695,15 @@ func (w *dbWrapper) QueryRowContext(ctx context.Context, query string, args ...i
 		w.config.QueryLogMethod(ctx, "sql", query, args...)
 	}
 	
-	return w.db.QueryRowContext(ctx, query, args...)
+	result, err := w.intercept(ctx, query, args, func(ctx context.Context, q *Query) (*QueryResult, error) {
+		row := w.db.QueryRowContext(ctx, q.SQL, q.Args...)
+		return &QueryResult{Row: row}, row.Err()
+	})
+	if result.Row == nil {
+		// the query was not run, fail the row with the error of the interceptor.
+		return errRow(err)
+	}
+	return result.Row
 }
 
 // IsPgCheckViolation returns true if the error is a postgres check violation.
@@ -939,23 +1745,153 @@ const (
 	errPgNotNullViolation     = "23502"
 	errPgForeignKeyViolation  = "23503"
 	errPgUniqueViolationError = "23505"
+	errPgSerializationFailure = "40001"
+	errPgDeadlockDetected     = "40P01"
 )
+
+// maxBindParams is the maximum number of bind parameters postgres accepts in one statement.
+const maxBindParams = 65535
 `
 
 // ErrorsTemplate is the template for the errors.
 // This is included in the init template.
 const ErrorsTemplate = `
 var (
-	// ErrNotFound is returned when a record is not found.
+	// ErrRowNotFound is returned when a record is not found.
 	ErrRowNotFound = fmt.Errorf("row not found")
+	// ErrNotFound is returned when a record is not found or, with WithMustAffect, no rows were affected.
+	ErrNotFound = ErrRowNotFound
 	// ErrNoTransaction is returned when a transaction is not provided.
 	ErrNoTransaction = fmt.Errorf("no transaction provided")
 	// ErrRowAlreadyExist is returned when a row already exist.
 	ErrRowAlreadyExist    = fmt.Errorf("row already exist")
 	// ErrModelIsNil is returned when a relation model is nil.
 	ErrModelIsNil = fmt.Errorf("model is nil")
+	// ErrForeignChild is returned when a nested update gets a child item with the key of a row of another parent.
+	ErrForeignChild = fmt.Errorf("child belongs to another parent")
+	// ErrCopyInTransaction is returned when a COPY on pgx is run inside a transaction.
+	ErrCopyInTransaction = fmt.Errorf("copy with pgx can't run inside a transaction")
 )
 
+// ErrUniqueViolation is returned when a write violates a unique constraint or the primary key.
+// errors.Is(err, ErrRowAlreadyExist) holds for it as well.
+type ErrUniqueViolation struct {
+	// Constraint is the name of the violated constraint, empty if the database does not report it.
+	Constraint string
+	// Columns are the columns of the violated constraint, empty if the database does not report them.
+	Columns []string
+	// Err is the driver error.
+	Err error
+}
+
+func (e *ErrUniqueViolation) Error() string { return fmt.Sprintf("unique violation: %v", e.Err) }
+func (e *ErrUniqueViolation) Unwrap() error { return e.Err }
+func (e *ErrUniqueViolation) Is(target error) bool { return target == ErrRowAlreadyExist }
+
+// ErrForeignKeyViolation is returned when a write references a missing row or deletes a referenced one.
+type ErrForeignKeyViolation struct {
+	// Constraint is the name of the violated constraint, empty if the database does not report it.
+	Constraint string
+	// Columns are the referencing columns, empty if the database does not report them.
+	Columns []string
+	// Err is the driver error.
+	Err error
+}
+
+func (e *ErrForeignKeyViolation) Error() string { return fmt.Sprintf("foreign key violation: %v", e.Err) }
+func (e *ErrForeignKeyViolation) Unwrap() error { return e.Err }
+
+// ErrCheckViolation is returned when a write violates a check constraint.
+type ErrCheckViolation struct {
+	// Constraint is the name of the violated constraint, empty if the database does not report it.
+	Constraint string
+	// Err is the driver error.
+	Err error
+}
+
+func (e *ErrCheckViolation) Error() string { return fmt.Sprintf("check violation: %v", e.Err) }
+func (e *ErrCheckViolation) Unwrap() error { return e.Err }
+
+// ErrSerialization is returned when a transaction conflicts with a concurrent one and may be retried.
+type ErrSerialization struct {
+	// Err is the driver error.
+	Err error
+}
+
+func (e *ErrSerialization) Error() string { return fmt.Sprintf("serialization failure: %v", e.Err) }
+func (e *ErrSerialization) Unwrap() error { return e.Err }
+
+// MapError maps a lib/pq or pgx error to ErrUniqueViolation, ErrForeignKeyViolation, ErrCheckViolation
+// or ErrSerialization and sql.ErrNoRows to ErrNotFound. Other errors are returned as is.
+func MapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if isMappedError(err) {
+		return err
+	}
+
+	var code, constraint, detail string
+	var pgErr *pgconn.PgError
+	var pqErr *pq.Error
+	switch {
+	case errors.As(err, &pgErr):
+		code, constraint, detail = pgErr.Code, pgErr.ConstraintName, pgErr.Detail
+	case errors.As(err, &pqErr):
+		code, constraint, detail = string(pqErr.Code), pqErr.Constraint, pqErr.Detail
+	default:
+		return err
+	}
+
+	switch code {
+	case errPgUniqueViolationError:
+		return &ErrUniqueViolation{Constraint: constraint, Columns: pgDetailColumns(detail), Err: err}
+	case errPgForeignKeyViolation:
+		return &ErrForeignKeyViolation{Constraint: constraint, Columns: pgDetailColumns(detail), Err: err}
+	case errPgCheckViolation:
+		return &ErrCheckViolation{Constraint: constraint, Err: err}
+	case errPgSerializationFailure, errPgDeadlockDetected:
+		return &ErrSerialization{Err: err}
+	}
+	return err
+}
+
+// pgErrorCode returns the SQLSTATE code of a lib/pq or pgx error, empty for other errors.
+func pgErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+	return ""
+}
+
+// isMappedError returns true if the error was already mapped by MapError.
+func isMappedError(err error) bool {
+	return errors.As(err, new(*ErrUniqueViolation)) || errors.As(err, new(*ErrForeignKeyViolation)) ||
+		errors.As(err, new(*ErrCheckViolation)) || errors.As(err, new(*ErrSerialization))
+}
+
+// pgDetailColumns returns the columns of a detail like "Key (tenant_id, email)=(1, a@b.c) already exists.".
+func pgDetailColumns(detail string) []string {
+	start := strings.Index(detail, "Key (")
+	if start < 0 {
+		return nil
+	}
+	detail = detail[start+len("Key ("):]
+	end := strings.Index(detail, ")=(")
+	if end < 0 {
+		return nil
+	}
+	return strings.Split(detail[:end], ", ")
+}
+
 {{ if not .IncludeConnection }}
 // Dsn returns a connection string for PostgreSQL.
 func Dsn(host string, port int, user, password, dbname, sslmode string, maxOpenConns int) string {
@@ -7,18 +7,30 @@ const TableTemplate = `
 {{ template "create_method" . }}
 {{ template "upsert_method" . }}
 {{ template "batch_create_method" . }}
+{{ template "batch_upsert_method" . }}
+{{ template "copy_from_method" . }}
 {{ template "update_method" . }}
+{{ template "returning_method" . }}
 {{ template "delete_method" . }}
 {{- if (hasPrimaryKey) }}
 {{ template "get_by_id_method" . }}
 {{ template "get_field_by_id_method" . }}
 {{- end }}
+{{- if (hasUniqueKeys) }}
+{{ template "unique_method" . }}
+{{- end }}
 {{ template "find_many_method" . }}
 {{ template "find_one_method" . }}
 {{ template "count_method" . }}
 {{ template "find_with_pagination" . }}
 {{ template "lock_method" . }}
 {{ template "raw_method" . }}
+{{- if (hasManyToMany) }}
+{{ template "many_to_many_method" . }}
+{{- end }}
+{{- if (hasSelfRelation) }}
+{{ template "tree_method" . }}
+{{- end }}
 `
 
 const TableConditionFilters = `
@@ -135,7 +147,7 @@ const TableConditionFilters = `
   {{ range $field := $fieldMess.GetField }}
    {{- if not ($field | isRelation) }}
    {{- if not ($field | isJSON) }}
-   {{- if ($field | isCurrentOptional) }}
+   {{- if and ($field | isCurrentOptional) (not ($field | isValidNull)) }}
 	// {{ $fieldMess.GetName | camelCase }}{{ $field.GetName | camelCase }}IsNull checks if the {{ $field.GetName }} is NULL.
     func {{ $fieldMess.GetName | camelCase }}{{ $field.GetName | camelCase }}IsNull() FilterApplier {
       return IsNullCondition{Field: "{{ $field.GetName }}"}
@@ -287,11 +299,59 @@ const TableConditionFilters = `
    {{ end }}
   {{ end }}
 {{ end }}
+
+{{ range $field := fields }}
+  {{- if ($field | isRelation) }}
+  {{- $rel := ($field | relation) }}
+  {{- if $rel }}
+	// {{ structureName }}Has{{ $field | pluralFieldName }} returns a condition that checks if the {{ structureName }} has {{ $field | fieldName }} matching all filters.
+    func {{ structureName }}Has{{ $field | pluralFieldName }}(filters ...FilterApplier) FilterApplier {
+      return {{ structureName | lowerCamelCase }}{{ $field | fieldName }}Exists(false, filters)
+    }
+
+	// {{ structureName }}HasNo{{ $field | pluralFieldName }} returns a condition that checks if the {{ structureName }} has no {{ $field | fieldName }} matching all filters.
+    func {{ structureName }}HasNo{{ $field | pluralFieldName }}(filters ...FilterApplier) FilterApplier {
+      return {{ structureName | lowerCamelCase }}{{ $field | fieldName }}Exists(true, filters)
+    }
+
+	// {{ structureName | lowerCamelCase }}{{ $field | fieldName }}Exists correlates the {{ $field | relationTableName }} rows with the {{ tableName }} row.
+    {{- $sub := ($field | relationTableName) }}
+    {{- if $rel.SelfReference }}{{ $sub = "sub" }}{{ end }}
+    func {{ structureName | lowerCamelCase }}{{ $field | fieldName }}Exists(not bool, filters []FilterApplier) FilterApplier {
+      return ExistsCondition{
+        Table:   "{{ $field | relationTableName }}",
+        {{- if $rel.SelfReference }}
+        Alias:   "{{ $sub }}",
+        {{- end }}
+        {{- if ($field | isManyToMany) }}
+        On:      "{{ $sub }}.{{ $rel.Reference }} IN (SELECT {{ $rel.Through }}.{{ $rel.ThroughReference }} FROM {{ $rel.Through }} WHERE {{ $rel.Through }}.{{ $rel.ThroughField }} = {{ tableName }}.{{ $rel.Field }})",
+        {{- else if ($field | isCompositeRelation) }}
+        On:      "{{ range $i, $ref := ($field | relationReferences) }}{{ if $i }} AND {{ end }}{{ $sub }}.{{ $ref.GetName }} = {{ tableName }}.{{ (index ($field | relationFields) $i).GetName }}{{ end }}",
+        {{- else }}
+        On:      "{{ $sub }}.{{ $rel.Reference }} = {{ tableName }}.{{ $rel.Field }}",
+        {{- end }}
+        Not:     not,
+        Filters: filters,
+      }
+    }
+  {{ end }}
+  {{- end }}
+{{ end }}
 `
 
 const TableFindWithPaginationMethodTemplate = `
 // FindManyWithPagination finds multiple {{ structureName }} with pagination support.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) FindManyWithPagination(ctx context.Context, limit int, page int, builders ...*QueryBuilder) ([]*{{structureName}}, *Paginator, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.FindManyWithPagination")
+	res, paginator, err := t.doFindManyWithPagination(ctx, limit, page, builders...)
+	endSpan(span, err)
+	return res, paginator, err
+}
+
+// doFindManyWithPagination is FindManyWithPagination without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doFindManyWithPagination{{ else }}FindManyWithPagination{{ end }}(ctx context.Context, limit int, page int, builders ...*QueryBuilder) ([]*{{structureName}}, *Paginator, error) {
 	// Count the total number of records
 	totalCount, err := t.Count(ctx, builders...)
 	if err != nil {
@@ -324,7 +384,17 @@ func (t *{{ storageName | lowerCamelCase }}) FindManyWithPagination(ctx context.
 
 const TableLockMethodTemplate = `
 // SelectForUpdate lock locks the {{ structureName }} for the given ID.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) SelectForUpdate(ctx context.Context, builders ...*QueryBuilder) (*{{structureName}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.SelectForUpdate")
+	res, err := t.doSelectForUpdate(ctx, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doSelectForUpdate is SelectForUpdate without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doSelectForUpdate{{ else }}SelectForUpdate{{ end }}(ctx context.Context, builders ...*QueryBuilder) (*{{structureName}}, error) {
 	query := t.queryBuilder.Select(t.Columns()...).From(t.TableName()).Suffix("FOR UPDATE")
 
 	// apply options from builder
@@ -355,7 +425,7 @@ func (t *{{ storageName | lowerCamelCase }}) SelectForUpdate(ctx context.Context
         if err == sql.ErrNoRows {
             return nil, ErrRowNotFound
         }
-        return nil, fmt.Errorf("failed to scan {{ structureName }}: %w", err)
+        return nil, fmt.Errorf("failed to scan {{ structureName }}: %w", MapError(err))
     }
 
 	return &model, nil
@@ -364,7 +434,17 @@ func (t *{{ storageName | lowerCamelCase }}) SelectForUpdate(ctx context.Context
 
 const TableCountMethodTemplate = `
 // Count counts {{ structureName }} based on the provided options.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) Count(ctx context.Context, builders ...*QueryBuilder) (int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Count")
+	res, err := t.doCount(ctx, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doCount is Count without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doCount{{ else }}Count{{ end }}(ctx context.Context, builders ...*QueryBuilder) (int64, error) {
 	// build query
 	query := t.queryBuilder.Select("COUNT(*)").From(t.TableName())
 
@@ -393,7 +473,7 @@ func (t *{{ storageName | lowerCamelCase }}) Count(ctx context.Context, builders
 	row := t.DB(ctx, false).QueryRowContext(ctx, sqlQuery, args...)
 	var count int64
 	if err := row.Scan(&count); err != nil {
-		return 0, fmt.Errorf("failed to scan count: %w", err)
+		return 0, fmt.Errorf("failed to scan count: %w", MapError(err))
 	}
 
 	return count, nil
@@ -402,7 +482,17 @@ func (t *{{ storageName | lowerCamelCase }}) Count(ctx context.Context, builders
 
 const TableFindOneMethodTemplate = `
 // FindOne finds a single {{ structureName }} based on the provided options.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) FindOne(ctx context.Context, builders ...*QueryBuilder) (*{{structureName}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.FindOne")
+	res, err := t.doFindOne(ctx, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doFindOne is FindOne without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doFindOne{{ else }}FindOne{{ end }}(ctx context.Context, builders ...*QueryBuilder) (*{{structureName}}, error) {
 	// Use findMany but limit the results to 1
 	builders = append(builders, LimitBuilder(1))
 	results, err := t.FindMany(ctx, builders...)
@@ -420,13 +510,29 @@ func (t *{{ storageName | lowerCamelCase }}) FindOne(ctx context.Context, builde
 
 const TableFindManyMethodTemplate = `
 // FindMany finds multiple {{ structureName }} based on the provided options.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) FindMany(ctx context.Context, builders ...*QueryBuilder) ([]*{{structureName}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.FindMany")
+	res, err := t.doFindMany(ctx, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doFindMany is FindMany without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doFindMany{{ else }}FindMany{{ end }}(ctx context.Context, builders ...*QueryBuilder) ([]*{{structureName}}, error) {
 	// build query
 	query := t.queryBuilder.Select(t.Columns()...).From(t.TableName())
 
 	// set default options
 	options := &Options{}
 
+	// collect the limit per parent row of the relation loaders
+	var limitPerParent *uint64
+	var partitionBy string
+	var sortOptions []FilterApplier
+	var paginated bool
+
  	// apply options from builder
 	for _, builder := range builders {
 		if builder == nil {
@@ -443,6 +549,7 @@ func (t *{{ storageName | lowerCamelCase }}) FindMany(ctx context.Context, build
 
 		// apply pagination
 		if builder.pagination != nil {
+			paginated = true
 			if builder.pagination.limit != nil {
 				query = query.Limit(*builder.pagination.limit)
 			}
@@ -455,11 +562,38 @@ func (t *{{ storageName | lowerCamelCase }}) FindMany(ctx context.Context, build
 		for _, option := range builder.sortOptions {
 			query = option.Apply(query)
 		}
+		sortOptions = append(sortOptions, builder.sortOptions...)
+
+		if builder.limitPerParent != nil {
+			limitPerParent = builder.limitPerParent
+		}
+		if builder.partitionBy != "" {
+			partitionBy = builder.partitionBy
+		}
 
 	    // apply options
 		for _, o := range builder.options {
 			o(options)
 		}
+
+		// collect relations to preload
+		options.preloads = append(options.preloads, builder.preloads...)
+	}
+
+	// keep at most limitPerParent rows for every parent row
+	if limitPerParent != nil {
+		if partitionBy == "" {
+			return nil, fmt.Errorf("limit per parent is only supported by relation loaders")
+		}
+		if paginated {
+			return nil, fmt.Errorf("limit per parent can't be combined with limit or offset")
+		}
+
+		var err error
+		query, err = limitPerParentQuery(t.queryBuilder, query, t.Columns(), partitionBy, *limitPerParent, sortOptions)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// execute query
@@ -471,7 +605,7 @@ func (t *{{ storageName | lowerCamelCase }}) FindMany(ctx context.Context, build
 
 	rows, err := t.DB(ctx, false).QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %w", err)
+		return nil, fmt.Errorf("failed to execute query: %w", MapError(err))
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
@@ -483,13 +617,20 @@ func (t *{{ storageName | lowerCamelCase }}) FindMany(ctx context.Context, build
 	for rows.Next() {
 		model := &{{structureName}}{}
 		if err := model.ScanRows(rows); err != nil {
-			return nil, fmt.Errorf("failed to scan {{ structureName }}: %w", err)
+			return nil, fmt.Errorf("failed to scan {{ structureName }}: %w", MapError(err))
 		}
 		results = append(results, model)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("failed to iterate over rows: %w", err)
+		return nil, fmt.Errorf("failed to iterate over rows: %w", MapError(err))
+	}
+
+	// preload relations
+	if len(results) > 0 && len(options.preloads) > 0 {
+		if err := t.preload(ctx, results, options.preloads); err != nil {
+			return nil, err
+		}
 	}
 	
 	return results, nil
@@ -497,11 +638,21 @@ func (t *{{ storageName | lowerCamelCase }}) FindMany(ctx context.Context, build
 `
 
 const TableGetByIDMethodTemplate = `
-// FindBy{{ getPrimaryKey.GetName | camelCase }} retrieves a {{ structureName }} by its {{ getPrimaryKey.GetName }}.
-func (t *{{ storageName | lowerCamelCase }}) FindBy{{ getPrimaryKey.GetName | camelCase }}(ctx context.Context, id {{IDType}}, opts ...Option) (*{{ structureName }}, error) {
+// FindBy{{ primaryKeyName | camelCase }} retrieves a {{ structureName }} by its {{ primaryKeyName }}.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) FindBy{{ primaryKeyName | camelCase }}(ctx context.Context, id {{ keyType }}, opts ...Option) (*{{ structureName }}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.FindBy{{ primaryKeyName | camelCase }}")
+	res, err := t.doFindBy{{ primaryKeyName | camelCase }}(ctx, id, opts...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doFindBy{{ primaryKeyName | camelCase }} is FindBy{{ primaryKeyName | camelCase }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doFindBy{{ primaryKeyName | camelCase }}{{ else }}FindBy{{ primaryKeyName | camelCase }}{{ end }}(ctx context.Context, id {{ keyType }}, opts ...Option) (*{{ structureName }}, error) {
 	builder := NewQueryBuilder()
 	{
-		builder.WithFilter({{ messageName }}{{ getPrimaryKey.GetName | camelCase }}Eq(id))
+		builder.WithFilter({{ if (hasCompositePrimaryKey) }}{{ structureName }}KeyEq(id){{ else }}{{ messageName }}{{ getPrimaryKey.GetName | camelCase }}Eq(id){{ end }})
 		builder.WithOptions(opts...)
 	}
 	
@@ -516,9 +667,19 @@ func (t *{{ storageName | lowerCamelCase }}) FindBy{{ getPrimaryKey.GetName | ca
 `
 
 const TableGetFieldByIDMethodTemplate = `
-// Get{{ getPrimaryKey.GetName | camelCase }}Field retrieves a specific field value by {{ getPrimaryKey.GetName }}.
-func (t *{{ storageName | lowerCamelCase }}) Get{{ getPrimaryKey.GetName | camelCase }}Field(ctx context.Context, {{getPrimaryKey.GetName | lowerCamelCase}} {{IDType}}, field string) (interface{}, error) {
-	query := t.queryBuilder.Select(field).From(t.TableName()).Where("{{ getPrimaryKey.GetName }} = ?", {{getPrimaryKey.GetName | lowerCamelCase}})
+// Get{{ primaryKeyName | camelCase }}Field retrieves a specific field value by {{ primaryKeyName }}.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Get{{ primaryKeyName | camelCase }}Field(ctx context.Context, {{primaryKeyName | lowerCamelCase}} {{ keyType }}, field string) (interface{}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Get{{ primaryKeyName | camelCase }}Field")
+	res, err := t.doGet{{ primaryKeyName | camelCase }}Field(ctx, {{primaryKeyName | lowerCamelCase}}, field)
+	endSpan(span, err)
+	return res, err
+}
+
+// doGet{{ primaryKeyName | camelCase }}Field is Get{{ primaryKeyName | camelCase }}Field without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doGet{{ primaryKeyName | camelCase }}Field{{ else }}Get{{ primaryKeyName | camelCase }}Field{{ end }}(ctx context.Context, {{primaryKeyName | lowerCamelCase}} {{ keyType }}, field string) (interface{}, error) {
+	query := t.queryBuilder.Select(field).From(t.TableName()).Where({{ if (hasCompositePrimaryKey) }}key.eq(){{ else }}"{{ getPrimaryKey.GetName }} = ?", {{getPrimaryKey.GetName | lowerCamelCase}}{{ end }})
 
 	sqlQuery, args, err := query.ToSql()
 	if err != nil {
@@ -532,7 +693,7 @@ func (t *{{ storageName | lowerCamelCase }}) Get{{ getPrimaryKey.GetName | camel
 		if err == sql.ErrNoRows {
 			return nil, ErrRowNotFound
 		}
-		return nil, fmt.Errorf("failed to scan field value: %w", err)
+		return nil, fmt.Errorf("failed to scan field value: %w", MapError(err))
 	}
 
 	return value, nil
@@ -543,54 +704,132 @@ const TableRawQueryMethodTemplate = `
 // Query executes a raw query and returns the result.
 // isWrite is used to determine if the query is a write operation.
 func (t *{{ storageName | lowerCamelCase }}) Query(ctx context.Context, isWrite bool, query string, args ...interface{}) (sql.Result, error) {
-	return t.DB(ctx, isWrite).ExecContext(ctx, query, args...)
+	return t.DB(ctx, isWrite).ExecContext(withOperation(ctx, OperationRaw), query, args...)
 }
 
 // QueryRow executes a raw query and returns the result.
 // isWrite is used to determine if the query is a write operation.
 func (t *{{ storageName | lowerCamelCase }}) QueryRow(ctx context.Context, isWrite bool, query string, args ...interface{}) *sql.Row {
-	return t.DB(ctx, isWrite).QueryRowContext(ctx, query, args...)
+	return t.DB(ctx, isWrite).QueryRowContext(withOperation(ctx, OperationRaw), query, args...)
 }
 
 // QueryRows executes a raw query and returns the result.
 // isWrite is used to determine if the query is a write operation.
 func (t *{{ storageName | lowerCamelCase }}) QueryRows(ctx context.Context, isWrite bool, query string, args ...interface{}) (*sql.Rows, error) {
-	return t.DB(ctx, isWrite).QueryContext(ctx, query, args...)
+	return t.DB(ctx, isWrite).QueryContext(withOperation(ctx, OperationRaw), query, args...)
 }
 `
 
 const TableDeleteMethodTemplate = `
 {{- if (hasPrimaryKey) }}
-// DeleteBy{{ getPrimaryKey.GetName | camelCase }} - deletes a {{ structureName }} by its {{ getPrimaryKey.GetName }}.
-func (t *{{ storageName | lowerCamelCase }}) DeleteBy{{ getPrimaryKey.GetName | camelCase }}(ctx context.Context, {{getPrimaryKey.GetName | lowerCamelCase}} {{IDType}}, opts ...Option) error {
+// DeleteBy{{ primaryKeyName | camelCase }} - deletes a {{ structureName }} by its {{ primaryKeyName }} and returns the number of deleted rows.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) DeleteBy{{ primaryKeyName | camelCase }}(ctx context.Context, {{primaryKeyName | lowerCamelCase}} {{ keyType }}, opts ...Option) (int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.DeleteBy{{ primaryKeyName | camelCase }}")
+	res, err := t.doDeleteBy{{ primaryKeyName | camelCase }}(ctx, {{primaryKeyName | lowerCamelCase}}, opts...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doDeleteBy{{ primaryKeyName | camelCase }} is DeleteBy{{ primaryKeyName | camelCase }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doDeleteBy{{ primaryKeyName | camelCase }}{{ else }}DeleteBy{{ primaryKeyName | camelCase }}{{ end }}(ctx context.Context, {{primaryKeyName | lowerCamelCase}} {{ keyType }}, opts ...Option) (int64, error) {
 	// set default options
 	options := &Options{}
 	for _, o := range opts {
 		o(options)
 	}
 
-	query := t.queryBuilder.Delete("{{ tableName }}").Where("{{ getPrimaryKey.GetName }} = ?", {{getPrimaryKey.GetName | lowerCamelCase}})
+	{{- if (hasCascadeDelete) }}
+
+	// delete the model and its cascading relations in one transaction
+	if _, ok := TxFromContext(ctx); !ok {
+		var deleted int64
+		err := t.config.txManager().ExecFuncWithTx(ctx, func(ctx context.Context) error {
+			var err error
+			deleted, err = t.DeleteBy{{ primaryKeyName | camelCase }}(ctx, {{primaryKeyName | lowerCamelCase}}, opts...)
+			return err
+		})
+		return deleted, err
+	}
+	{{- range $index, $field := fields }}
+	{{- if ($field | relationCascadeDelete) }}
+
+	if err := t.delete{{ $field | fieldName }}(ctx, {{getPrimaryKey.GetName | lowerCamelCase}}); err != nil {
+		return 0, err
+	}
+	{{- end }}
+	{{- end }}
+	{{- end }}
+
+	query := t.queryBuilder.Delete("{{ tableName }}").Where({{ if (hasCompositePrimaryKey) }}key.eq(){{ else }}"{{ getPrimaryKey.GetName }} = ?", {{getPrimaryKey.GetName | lowerCamelCase}}{{ end }})
 
 	sqlQuery, args, err := query.ToSql()
 	if err != nil {
-		return fmt.Errorf("failed to build query: %w", err)
+		return 0, fmt.Errorf("failed to build query: %w", err)
 	}
 	t.logQuery(ctx, sqlQuery, args...)
 
-	_, err = t.DB(ctx, true).ExecContext(ctx,sqlQuery, args...)
+	result, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...)
 	if err != nil {
-		return fmt.Errorf("failed to delete {{ structureName }}: %w", err)
+		return 0, fmt.Errorf("failed to delete {{ structureName }}: %w", MapError(err))
 	}
 
+	return rowsAffected(result, options)
+}
+{{- range $index, $field := fields }}
+{{- if ($field | relationCascadeDelete) }}
+
+// delete{{ $field | fieldName }} deletes the {{ $field | fieldName }} of the {{ structureName }} with the given {{ getPrimaryKey.GetName }}.
+func (t *{{ storageName | lowerCamelCase }}) delete{{ $field | fieldName }}(ctx context.Context, id {{IDType}}) error {
+	s, err := New{{ $field | relationStorageName }}(t.config)
+	if err != nil {
+		return fmt.Errorf("failed to create {{ $field | relationStorageName }}: %w", err)
+	}
+	{{- if and ($field | relationHasCascadeDelete) ($field | relationPrimaryKey) }}
+
+	// delete one by one, so the nested relations are deleted as well
+	items, err := s.FindMany(ctx, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}Eq(id)))
+	if err != nil {
+		return fmt.Errorf("failed to find {{ $field | fieldName }}: %w", err)
+	}
+	for _, item := range items {
+		if _, err := s.DeleteBy{{ ($field | relationPrimaryKey).GetName | camelCase }}(ctx, item.{{ ($field | relationPrimaryKey).GetName | camelCase }}); err != nil {
+			return fmt.Errorf("failed to delete {{ $field | fieldName }}: %w", err)
+		}
+	}
+	{{- else }}
+
+	if _, err := s.DeleteMany(ctx, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}Eq(id))); err != nil {
+		return fmt.Errorf("failed to delete {{ $field | fieldName }}: %w", err)
+	}
+	{{- end }}
+
 	return nil
 }
 {{- end }}
+{{- end }}
+{{- end }}
 
 // DeleteMany removes entries from the {{ tableName }} table using the provided filters
-func (t *{{ storageName | lowerCamelCase }}) DeleteMany(ctx context.Context, builders ...*QueryBuilder) error {
+// and returns the number of deleted rows.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) DeleteMany(ctx context.Context, builders ...*QueryBuilder) (int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.DeleteMany")
+	res, err := t.doDeleteMany(ctx, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doDeleteMany is DeleteMany without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doDeleteMany{{ else }}DeleteMany{{ end }}(ctx context.Context, builders ...*QueryBuilder) (int64, error) {
 	// build query
 	query := t.queryBuilder.Delete("{{ tableName }}")
 
+	// set default options
+	options := &Options{}
+
 	var withFilter bool
 	for _, builder := range builders {
 		if builder == nil {
@@ -602,24 +841,29 @@ func (t *{{ storageName | lowerCamelCase }}) DeleteMany(ctx context.Context, bui
 			query = option.ApplyDelete(query)
 			withFilter = true
 		}
+
+		// apply options
+		for _, o := range builder.options {
+			o(options)
+		}
 	}
 
 	if !withFilter {
-		return fmt.Errorf("filters are required for delete operation")
+		return 0, fmt.Errorf("filters are required for delete operation")
 	}
 
 	sqlQuery, args, err := query.ToSql()
 	if err != nil {
-		return fmt.Errorf("failed to build query: %w", err)
+		return 0, fmt.Errorf("failed to build query: %w", err)
 	}
 	t.logQuery(ctx, sqlQuery, args...)
 
-	_, err = t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...)
+	result, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...)
 	if err != nil {
-		return fmt.Errorf("failed to delete {{ tableName }}: %w", err)
+		return 0, fmt.Errorf("failed to delete {{ tableName }}: %w", MapError(err))
 	}
-	
-	return nil
+
+	return rowsAffected(result, options)
 }
 `
 
@@ -636,15 +880,63 @@ type {{ structureName }}Update struct {
 	{{- end }}
 	{{- end }}
 	{{- end }}
+
+	{{- range $index, $field := fields }}
+	{{- if and ($field | isRelation) ($field | relationAllowSubCreating) ($field | relationPrimaryKey) }}
+		// {{ $field | fieldName }} replaces the related rows when updated with WithRelations, nil keeps them
+		{{ $field | fieldName }} {{ $field | fieldType }}
+	{{- end }}
+	{{- end }}
 }
 
-// Update updates an existing {{ structureName }} based on non-nil fields.
-func (t *{{ storageName | lowerCamelCase }}) Update(ctx context.Context, id {{IDType}}, updateData *{{structureName}}Update) error {
-	if updateData == nil {
-		return fmt.Errorf("update data is nil")
+// new{{ structureName }}Update returns an update that sets all columns of the model
+// except the automatic time fields.
+func new{{ structureName }}Update(model *{{structureName}}) *{{ structureName }}Update {
+	return &{{ structureName }}Update{
+	{{- range $index, $field := fields }}
+	{{- if not ($field | isRelation) }}
+	{{- if not ($field | isAutoIncrement) }}
+	{{- if not ($field | isPrimary) }}
+	{{- if not (or ($field | isAutoCreateTime) ($field | isAutoUpdateTime)) }}
+		{{ $field | fieldName }}: {{ if not ($field | findPointer) }}&{{ end }}model.{{ $field | fieldName }},
+	{{- end }}
+	{{- end }}
+	{{- end }}
+	{{- end }}
+	{{- end }}
+
+	{{- range $index, $field := fields }}
+	{{- if and ($field | isRelation) ($field | relationAllowSubCreating) ($field | relationPrimaryKey) }}
+		{{ $field | fieldName }}: model.{{ $field | fieldName }},
+	{{- end }}
+	{{- end }}
+	}
+}
+
+// isEmpty returns true if the {{ structureName }}Update does not set any column.
+func (u *{{ structureName }}Update) isEmpty() bool {
+	{{- range $index, $field := fields }}
+	{{- if not ($field | isRelation) }}
+	{{- if not ($field | isAutoIncrement) }}
+	{{- if not ($field | isPrimary) }}
+	if u.{{ $field | fieldName }} != nil {
+		return false
 	}
+	{{- end }}
+	{{- end }}
+	{{- end }}
+	{{- end }}
+	return true
+}
 
+// buildUpdateQuery builds the UPDATE statement for the non-nil fields of the {{ structureName }}Update.
+func (t *{{ storageName | lowerCamelCase }}) buildUpdateQuery(updateData *{{structureName}}Update) (sq.UpdateBuilder, error) {
 	query := t.queryBuilder.Update("{{ tableName }}")
+	{{- if (hasAutoUpdateTime) }}
+
+	// current time for automatic update time fields
+	now := t.now()
+	{{- end }}
 
 	{{- range $index, $field := fields }}
 	{{- if not ($field | isRelation) }}
@@ -656,7 +948,7 @@ func (t *{{ storageName | lowerCamelCase }}) Update(ctx context.Context, id {{ID
 			// Handle repeated fields by calling .Value()
 			{{ $field | fieldName | lowerCamelCase }}, err := updateData.{{ $field | fieldName }}.Value()
 			if err != nil {
-				return fmt.Errorf("failed to get value of {{ $field | fieldName }}: %w", err)
+				return query, fmt.Errorf("failed to get value of {{ $field | fieldName }}: %w", err)
 			}
 			query = query.Set("{{ $field | sourceName }}", {{ $field | fieldName | lowerCamelCase }})
 			{{- else if ($field | isJSON) }}
@@ -664,209 +956,359 @@ func (t *{{ storageName | lowerCamelCase }}) Update(ctx context.Context, id {{ID
 			{{- else }}
 			query = query.Set("{{ $field | sourceName }}", *updateData.{{ $field | fieldName }})
 			{{- end }}
-		}
+		} {{- if ($field | isAutoUpdateTime) }} else {
+			query = query.Set("{{ $field | sourceName }}", {{ $field | autoTimeValue }})
+		} {{- end }}
 	{{- end }}
 	{{- end }}
 	{{- end }}
 	{{- end }}
 
-	query = query.Where("{{ getPrimaryKey.GetName }} = ?", id)
+	return query, nil
+}
 
-	sqlQuery, args, err := query.ToSql()
-	if err != nil {
-		return fmt.Errorf("failed to build query: %w", err)
+// Update updates an existing {{ structureName }} based on non-nil fields and returns the number of updated rows.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Update(ctx context.Context, id {{ keyType }}, updateData *{{structureName}}Update, opts ...Option) (int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Update")
+	res, err := t.doUpdate(ctx, id, updateData, opts...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doUpdate is Update without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doUpdate{{ else }}Update{{ end }}(ctx context.Context, id {{ keyType }}, updateData *{{structureName}}Update, opts ...Option) (int64, error) {
+	if updateData == nil {
+		return 0, fmt.Errorf("update data is nil")
 	}
-	t.logQuery(ctx, sqlQuery, args...)
 
-	_, err = t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...)
-	if err != nil {
-		return fmt.Errorf("failed to update {{ structureName }}: %w", err)
+	// set default options
+	options := &Options{}
+	for _, o := range opts {
+		o(options)
 	}
 
-	return nil
-}
-`
+	{{- if (hasChildRelations) }}
 
-const StructureTemplate = `
-// {{ structureName }} is a struct for the "{{ tableName }}" table.
-type {{ structureName }} struct {
-{{ range $field := fields }}
-	{{ $field | fieldName }} {{ $field | fieldType }}{{if not ($field | isRelation) }}` + " `db:\"{{ $field | sourceName }}\"`" + `{{end}}{{end}}
-}
+	if options.relations {
+		// update the model and its relations in one transaction
+		if _, ok := TxFromContext(ctx); !ok {
+			var affected int64
+			err := t.config.txManager().ExecFuncWithTx(ctx, func(ctx context.Context) error {
+				var err error
+				affected, err = t.Update(ctx, id, updateData, opts...)
+				return err
+			})
+			return affected, err
+		}
 
-// TableName returns the table name.
-func (t *{{ structureName }}) TableName() string {
-	return "{{ tableName }}"
-}
+		var affected int64
+		if !updateData.isEmpty() {
+			var err error
+			affected, err = t.update(ctx, id, updateData, options)
+			if err != nil {
+				return 0, err
+			}
+		}
+		{{- range $index, $field := fields }}
+		{{- if and ($field | isRelation) ($field | relationAllowSubCreating) ($field | relationPrimaryKey) }}
 
-// ScanRow scans a row into a {{ structureName }}.
-func (t *{{ structureName }}) ScanRow(r *sql.Row) error {
-	return r.Scan({{ range $field := fields }} {{if not ($field | isRelation) }} &t.{{ $field | fieldName }}, {{ end }}{{ end }})
+		if updateData.{{ $field | fieldName }} != nil {
+			{{- if ($field | isRepeated) }}
+			if err := t.update{{ $field | fieldName }}(ctx, id, updateData.{{ $field | fieldName }}); err != nil {
+			{{- else }}
+			if err := t.update{{ $field | fieldName }}(ctx, id, []*{{ $field | relationStructureName }}{updateData.{{ $field | fieldName }}}); err != nil {
+			{{- end }}
+				return 0, err
+			}
+		}
+		{{- end }}
+		{{- end }}
+
+		return affected, nil
+	}
+	{{- end }}
+
+	return t.update(ctx, id, updateData, options)
 }
 
-// ScanRows scans a single row into the {{ structureName }}.
-func (t *{{ structureName }}) ScanRows(r *sql.Rows) error {
-	return r.Scan(
-		{{- range $index, $field := fields }}
-		{{- if not ($field | isRelation) }}
-		&t.{{ $field | fieldName }},
-		{{- end}}
-		{{- end }}
-	)
+// update runs the UPDATE statement of the {{ structureName }} with the given {{ primaryKeyName }}.
+func (t *{{ storageName | lowerCamelCase }}) update(ctx context.Context, id {{ keyType }}, updateData *{{structureName}}Update, options *Options) (int64, error) {
+	query, err := t.buildUpdateQuery(updateData)
+	if err != nil {
+		return 0, err
+	}
+
+	query = query.Where({{ if (hasCompositePrimaryKey) }}id.eq(){{ else }}"{{ getPrimaryKey.GetName }} = ?", id{{ end }})
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	result, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update {{ structureName }}: %w", MapError(err))
+	}
+
+	return rowsAffected(result, options)
 }
-`
+{{- range $index, $field := fields }}
+{{- if and ($field | isRelation) ($field | relationAllowSubCreating) ($field | relationPrimaryKey) }}
 
-const TableBatchCreateMethodTemplate = `
-// BatchCreate creates multiple {{ structureName }} records in a single batch.
-func (t *{{ storageName | lowerCamelCase }}) BatchCreate(ctx context.Context, models []*{{structureName}}, opts ...Option) ({{ if (hasID) }}[]string, {{ end }}error) {
-	if len(models) == 0 {
-		{{ if (hasID) }} return nil, fmt.Errorf("no models to insert") {{ else }} return fmt.Errorf("no models to insert") {{ end }}
+// update{{ $field | fieldName }} replaces the {{ $field | fieldName }} of the {{ structureName }} with the given items.
+// Known items are updated, new items are created and the missing ones are deleted. The keys of the created
+// items are set on them. An item with the key of a row of another {{ structureName }} returns ErrForeignChild.
+func (t *{{ storageName | lowerCamelCase }}) update{{ $field | fieldName }}(ctx context.Context, id {{IDType}}, items []*{{ $field | relationStructureName }}) error {
+	s, err := New{{ $field | relationStorageName }}(t.config)
+	if err != nil {
+		return fmt.Errorf("failed to create {{ $field | relationStorageName }}: %w", err)
 	}
 
-	options := &Options{}
-	for _, o := range opts {
-		o(options)
+	existing, err := s.FindMany(ctx, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}Eq(id)))
+	if err != nil {
+		return fmt.Errorf("failed to find {{ $field | fieldName }}: %w", err)
 	}
 
-	if options.relations {
-		{{ if (hasID) }} return nil, fmt.Errorf("relations are not supported in batch create") {{ else }} return fmt.Errorf("relations are not supported in batch create") {{ end }}
+	known := make(map[{{ ($field | relationPrimaryKey) | fieldType }}]bool, len(existing))
+	for _, item := range existing {
+		known[item.{{ ($field | relationPrimaryKey).GetName | camelCase }}] = true
 	}
 
-	query := t.queryBuilder.Insert(t.TableName()).
-		Columns(
-			{{- range $index, $field := fields }}
-			{{- if not ($field | isRelation) }}
-			{{- if not ($field | isAutoIncrement ) }}
-			{{- if not ($field | isDefaultUUID ) }}
-			"{{ $field | sourceName }}",
-			{{- end}}
-			{{- end}}
-			{{- end}}
-			{{- end}}
-		)
+	var newKey {{ ($field | relationPrimaryKey) | fieldType }}
 
-	for _, model := range models {
-		if model == nil {
-			{{ if (hasID) }} return nil, fmt.Errorf("one of the models is nil") {{ else }} return fmt.Errorf("one of the models is nil") {{ end }}
+	for _, item := range items {
+		if item == nil {
+			continue
 		}
+		item.{{ $field | getRefID }} = {{ if ($field | isRefOptional) }}&{{ end }}id
 
-		{{- range $index, $field := fields }}
-		{{- if not ($field | isRelation) }}
-		{{- if ($field | isRepeated) }}
-		// get value of {{ $field | fieldName | lowerCamelCase }}
-		{{ $field | fieldName | lowerCamelCase }}, err := model.{{ $field | fieldName }}.Value()
-		if err != nil {
-			{{ if (hasID) }} return nil, fmt.Errorf("failed to get value of {{ $field | fieldName }}: %w", err) {{ else }} return fmt.Errorf("failed to get value of {{ $field | fieldName }}: %w", err) {{ end }}
+		if known[item.{{ ($field | relationPrimaryKey).GetName | camelCase }}] {
+			delete(known, item.{{ ($field | relationPrimaryKey).GetName | camelCase }})
+			if _, err := s.Update(ctx, item.{{ ($field | relationPrimaryKey).GetName | camelCase }}, new{{ $field | relationStructureName }}Update(item), WithRelations()); err != nil {
+				return fmt.Errorf("failed to update {{ $field | fieldName }}: %w", err)
+			}
+			continue
 		}
-		{{- end}}
-		{{- end}}
-		{{- end}}
 
-		query = query.Values(
-			{{- range $index, $field := fields }}
-			{{- if not ($field | isRelation) }}
-			{{- if not ($field | isAutoIncrement ) }}
-			{{- if not ($field | isDefaultUUID ) }}
-
-			{{- if ($field | isRepeated) }}
-				{{ $field | fieldName | lowerCamelCase }},
+		if item.{{ ($field | relationPrimaryKey).GetName | camelCase }} != newKey {
+			{{- if or (($field | relationPrimaryKey) | isAutoIncrement) (($field | relationPrimaryKey) | isDefaultUUID) }}
+			// the keys of the new items are generated by the database
+			return fmt.Errorf("{{ $field | fieldName }} %v: %w", item.{{ ($field | relationPrimaryKey).GetName | camelCase }}, ErrForeignChild)
 			{{- else }}
-			
-				{{- if (findPointer $field) }}
-				nullValue(model.{{ $field | fieldName }}),
-				{{- else }}
-				model.{{ $field | fieldName }},
-				{{- end }}
+			if _, err := s.FindBy{{ ($field | relationPrimaryKey).GetName | camelCase }}(ctx, item.{{ ($field | relationPrimaryKey).GetName | camelCase }}); err == nil {
+				return fmt.Errorf("{{ $field | fieldName }} %v: %w", item.{{ ($field | relationPrimaryKey).GetName | camelCase }}, ErrForeignChild)
+			} else if !errors.Is(err, ErrRowNotFound) {
+				return fmt.Errorf("failed to find {{ $field | fieldName }}: %w", err)
+			}
+			{{- end }}
+		}
+		{{- if ($field | hasIDFromRelation) }}
 
-			{{- end}}
+		created, err := s.Create(ctx, item, WithRelations())
+		if err != nil {
+			return fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err)
+		}
+		if created != nil {
+			item.{{ ($field | relationPrimaryKey).GetName | camelCase }} = *created
+		}
+		{{- else }}
 
-			{{- end}}
-			{{- end}}
-			{{- end}}
-			{{- end}}
-		)
+		if err := s.Create(ctx, item, WithRelations()); err != nil {
+			return fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err)
+		}
+		{{- end }}
 	}
 
-	{{ if (hasID) }}
-	if options.ignoreConflictField != "" {
-		query = query.Suffix("ON CONFLICT ("+options.ignoreConflictField+") DO NOTHING RETURNING \"{{ getPrimaryKey.GetName }}\"")
-	} else {
-		{{- if (hasCompositePrimaryKey) }}
-		// Composite primary key: using first PK field for RETURNING
-		query = query.Suffix("RETURNING \"{{ getPrimaryKey.GetName }}\"")
-		{{- else }}
-		query = query.Suffix("RETURNING \"{{ getPrimaryKey.GetName }}\"")
-		{{- end }}
+	// delete the rows which are not in the items anymore
+	for key := range known {
+		if _, err := s.DeleteBy{{ ($field | relationPrimaryKey).GetName | camelCase }}(ctx, key); err != nil {
+			return fmt.Errorf("failed to delete {{ $field | fieldName }}: %w", err)
+		}
 	}
-	{{ else }}
-	if options.ignoreConflictField != "" {
-		query = query.Suffix("ON CONFLICT ("+options.ignoreConflictField+") DO NOTHING")
+
+	return nil
+}
+{{- end }}
+{{- end }}
+
+// UpdateMany updates all {{ structureName }} matching the provided filters based on non-nil fields
+// and returns the number of updated rows.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) UpdateMany(ctx context.Context, updateData *{{structureName}}Update, builders ...*QueryBuilder) (int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.UpdateMany")
+	res, err := t.doUpdateMany(ctx, updateData, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doUpdateMany is UpdateMany without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doUpdateMany{{ else }}UpdateMany{{ end }}(ctx context.Context, updateData *{{structureName}}Update, builders ...*QueryBuilder) (int64, error) {
+	if updateData == nil {
+		return 0, fmt.Errorf("update data is nil")
+	}
+
+	query, err := t.buildUpdateQuery(updateData)
+	if err != nil {
+		return 0, err
+	}
+
+	// set default options
+	options := &Options{}
+
+	var withFilter bool
+	for _, builder := range builders {
+		if builder == nil {
+			continue
+		}
+
+		// apply filter options
+		for _, option := range builder.filterOptions {
+			// extract WHERE clause from the filter
+			whereParts, args, err := option.Apply(sq.Select("*")).ToSql()
+			if err != nil {
+				return 0, fmt.Errorf("failed to build filter: %w", err)
+			}
+			query = query.Where(strings.TrimPrefix(whereParts, "SELECT * WHERE "), args...)
+			withFilter = true
+		}
+
+		// apply options
+		for _, o := range builder.options {
+			o(options)
+		}
+	}
+
+	if !withFilter {
+		return 0, fmt.Errorf("filters are required for update operation")
 	}
-	{{ end }}
 
 	sqlQuery, args, err := query.ToSql()
 	if err != nil {
-		{{ if (hasID) }} return nil, fmt.Errorf("failed to build query: %w", err) {{ else }} return fmt.Errorf("failed to build query: %w", err) {{ end }}
+		return 0, fmt.Errorf("failed to build query: %w", err)
 	}
 	t.logQuery(ctx, sqlQuery, args...)
 
-	rows, err := t.DB(ctx, true).QueryContext(ctx, sqlQuery, args...)
+	result, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...)
 	if err != nil {
-		if IsPgUniqueViolation(err) {
-			{{ if (hasID) }} return nil, fmt.Errorf("%w: %s", ErrRowAlreadyExist, PgPrettyErr(err).Error()) {{ else }} return fmt.Errorf("%w: %s", ErrRowAlreadyExist, PgPrettyErr(err).Error()) {{ end }}
-		}
-		{{ if (hasID) }} return nil, fmt.Errorf("failed to execute bulk insert: %w", err) {{ else }} return fmt.Errorf("failed to execute bulk insert: %w", err) {{ end }}
+		return 0, fmt.Errorf("failed to update {{ tableName }}: %w", MapError(err))
 	}
-	defer func() {
-		if err := rows.Close(); err != nil {
-			t.logError(ctx, err, "failed to close rows")
-		}
-	}()
 
-	{{ if (hasID) }} var returnIDs []string {{ end }} {{ if (hasID) }}
-	for rows.Next() {
-		var {{ getPrimaryKey.GetName }} string
-		if err := rows.Scan(&{{ getPrimaryKey.GetName }}); err != nil {
-			{{ if (hasID) }} return nil, fmt.Errorf("failed to scan {{ getPrimaryKey.GetName }}: %w", err) {{ else }} return fmt.Errorf("failed to scan {{ getPrimaryKey.GetName }}: %w", err) {{ end }}
-		}
-		returnIDs = append(returnIDs, {{ getPrimaryKey.GetName }})
+	return rowsAffected(result, options)
+}
+`
+
+const StructureTemplate = `
+// {{ structureName }} is a struct for the "{{ tableName }}" table.
+type {{ structureName }} struct {
+{{ range $field := fields }}
+	{{ $field | fieldName }} {{ $field | fieldType }}{{if not ($field | isRelation) }}` + " `db:\"{{ $field | sourceName }}\"`" + `{{end}}{{end}}
+}
+
+// TableName returns the table name.
+func (t *{{ structureName }}) TableName() string {
+	return "{{ tableName }}"
+}
+
+// ScanRow scans a row into a {{ structureName }}.
+func (t *{{ structureName }}) ScanRow(r *sql.Row) error {
+	return r.Scan({{ range $field := fields }} {{if not ($field | isRelation) }} &t.{{ $field | fieldName }}, {{ end }}{{ end }})
+}
+
+// ScanRows scans a single row into the {{ structureName }}.
+func (t *{{ structureName }}) ScanRows(r *sql.Rows) error {
+	return r.Scan(
+		{{- range $index, $field := fields }}
+		{{- if not ($field | isRelation) }}
+		&t.{{ $field | fieldName }},
+		{{- end}}
+		{{- end }}
+	)
+}
+{{- if (hasCompositePrimaryKey) }}
+
+// {{ structureName }}Key is the composite primary key of the "{{ tableName }}" table.
+type {{ structureName }}Key struct {
+{{- range $field := getPrimaryKeys }}
+	{{ $field | fieldName }} {{ $field | fieldType }}
+{{- end }}
+}
+
+// Key returns the composite primary key of the {{ structureName }}.
+func (t *{{ structureName }}) Key() {{ structureName }}Key {
+	return {{ structureName }}Key{
+	{{- range $field := getPrimaryKeys }}
+		{{ $field | fieldName }}: t.{{ $field | fieldName }},
+	{{- end }}
 	}
-	{{ end }}
+}
 
-	if err := rows.Err(); err != nil {
-		{{ if (hasID) }} return nil, fmt.Errorf("rows iteration error: %w", err) {{ else }} return fmt.Errorf("rows iteration error: %w", err) {{ end }}
+// eq returns the columns of the key with their values.
+func (k {{ structureName }}Key) eq() sq.Eq {
+	return sq.Eq{
+	{{- range $field := getPrimaryKeys }}
+		"{{ $field | sourceName }}": k.{{ $field | fieldName }},
+	{{- end }}
 	}
+}
 
-	return {{ if (hasID) }} returnIDs, nil {{ else }} nil {{ end }}
+// {{ structureName }}KeyEq returns a condition that matches the row with the given key.
+func {{ structureName }}KeyEq(key {{ structureName }}Key) FilterApplier {
+	return And(
+	{{- range $field := getPrimaryKeys }}
+		Eq("{{ $field | sourceName }}", key.{{ $field | fieldName }}),
+	{{- end }}
+	)
 }
+{{- end }}
 `
 
-const TableCreateMethodTemplate = `
-// Create creates a new {{ structureName }}.
-{{ if (hasID) }} func (t *{{ storageName | lowerCamelCase }}) Create(ctx context.Context, model *{{structureName}}, opts ...Option) (*{{IDType}}, error) { {{ else }} func (t *{{ storageName | lowerCamelCase }}) Create(ctx context.Context, model *{{structureName}}, opts ...Option) error { {{ end }}
-	if model == nil {
-		{{ if (hasID) }}return nil, fmt.Errorf("model is nil") {{ else }}return fmt.Errorf("model is nil") {{ end }}
+const TableBatchCreateMethodTemplate = `
+// BatchCreate creates multiple {{ structureName }} records in a single batch.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) BatchCreate(ctx context.Context, models []*{{structureName}}, opts ...Option) ({{ if (hasID) }}[]string, {{ end }}error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.BatchCreate")
+	{{ if (hasID) }}ids, {{ end }}err := t.doBatchCreate(ctx, models, opts...)
+	endSpan(span, err)
+	return {{ if (hasID) }}ids, {{ end }}err
+}
+
+// doBatchCreate is BatchCreate without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doBatchCreate{{ else }}BatchCreate{{ end }}(ctx context.Context, models []*{{structureName}}, opts ...Option) ({{ if (hasID) }}[]string, {{ end }}error) {
+	if len(models) == 0 {
+		{{ if (hasID) }} return nil, fmt.Errorf("no models to insert") {{ else }} return fmt.Errorf("no models to insert") {{ end }}
 	}
 
-	// set default options
 	options := &Options{}
 	for _, o := range opts {
 		o(options)
 	}
 
-	{{- range $index, $field := fields }}
-	{{- if not ($field | isRelation) }}
-	{{- if ($field | isRepeated) }}
-	// get value of {{ $field | fieldName | lowerCamelCase }}
-	{{ $field | fieldName | lowerCamelCase }}, err := model.{{ $field | fieldName }}.Value()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get value of {{ $field | fieldName }}: %w", err)
+	{{- if and (hasID) (hasChildRelations) }}
+
+	// create the models and their relations in one transaction
+	if options.relations {
+		if options.ignoreConflictField != "" {
+			return nil, fmt.Errorf("relations are not supported with ignored conflicts in batch create")
+		}
+
+		if _, ok := TxFromContext(ctx); !ok {
+			var ids []string
+			err := t.config.txManager().ExecFuncWithTx(ctx, func(ctx context.Context) error {
+				var err error
+				ids, err = t.BatchCreate(ctx, models, opts...)
+				return err
+			})
+			return ids, err
+		}
 	}
-	{{- end}}
-	{{- end}}
-	{{- end}}
+	{{- end }}
 
-	query := t.queryBuilder.Insert("{{ tableName }}").
+	query := t.queryBuilder.Insert(t.TableName()).
 		Columns(
 			{{- range $index, $field := fields }}
 			{{- if not ($field | isRelation) }}
@@ -877,13 +1319,37 @@ const TableCreateMethodTemplate = `
 			{{- end}}
 			{{- end}}
 			{{- end}}
-		).
-		Values(
+		)
+
+	for _, model := range models {
+		if model == nil {
+			{{ if (hasID) }} return nil, fmt.Errorf("one of the models is nil") {{ else }} return fmt.Errorf("one of the models is nil") {{ end }}
+		}
+
+		{{- if (hasAutoTime) }}
+
+		// fill automatic time fields
+		t.fillAutoTime(model)
+		{{- end }}
+
+		{{- range $index, $field := fields }}
+		{{- if not ($field | isRelation) }}
+		{{- if ($field | isRepeated) }}
+		// get value of {{ $field | fieldName | lowerCamelCase }}
+		{{ $field | fieldName | lowerCamelCase }}, err := model.{{ $field | fieldName }}.Value()
+		if err != nil {
+			{{ if (hasID) }} return nil, fmt.Errorf("failed to get value of {{ $field | fieldName }}: %w", err) {{ else }} return fmt.Errorf("failed to get value of {{ $field | fieldName }}: %w", err) {{ end }}
+		}
+		{{- end}}
+		{{- end}}
+		{{- end}}
+
+		query = query.Values(
 			{{- range $index, $field := fields }}
 			{{- if not ($field | isRelation) }}
 			{{- if not ($field | isAutoIncrement ) }}
 			{{- if not ($field | isDefaultUUID ) }}
-			
+
 			{{- if ($field | isRepeated) }}
 				{{ $field | fieldName | lowerCamelCase }},
 			{{- else }}
@@ -900,7 +1366,9 @@ const TableCreateMethodTemplate = `
 			{{- end}}
 			{{- end}}
 			{{- end}}
-	)
+		)
+	}
+
 	{{ if (hasID) }}
 	if options.ignoreConflictField != "" {
 		query = query.Suffix("ON CONFLICT ("+options.ignoreConflictField+") DO NOTHING RETURNING \"{{ getPrimaryKey.GetName }}\"")
@@ -924,56 +1392,266 @@ const TableCreateMethodTemplate = `
 	}
 	t.logQuery(ctx, sqlQuery, args...)
 
-	{{ if (hasID) }}var id {{IDType}}
-	err = t.DB(ctx, true).QueryRowContext(ctx,sqlQuery, args...).Scan(&id) {{ else }} _, err = t.DB(ctx, true).ExecContext(ctx,sqlQuery, args...) {{ end }}
+	rows, err := t.DB(ctx, true).QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
-		if IsPgUniqueViolation(err) {
-			{{ if (hasID) }}return nil, fmt.Errorf("%w: %s", ErrRowAlreadyExist, PgPrettyErr(err).Error()) {{ else }}return fmt.Errorf("%w: %s", ErrRowAlreadyExist, PgPrettyErr(err).Error()) {{ end }}
+		{{ if (hasID) }} return nil, fmt.Errorf("failed to execute bulk insert: %w", MapError(err)) {{ else }} return fmt.Errorf("failed to execute bulk insert: %w", MapError(err)) {{ end }}
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			t.logError(ctx, err, "failed to close rows")
 		}
+	}()
 
-		{{ if (hasID) }} return nil, fmt.Errorf("failed to create {{ structureName }}: %w", err) {{ else }} return fmt.Errorf("failed to create {{ structureName }}: %w", err) {{ end }}
+	{{ if (hasID) }} var returnIDs []string {{ end }} {{ if (hasID) }}
+	{{- if (hasChildRelations) }}
+	ids := make([]{{IDType}}, 0, len(models))
+	{{- end }}
+	for rows.Next() {
+		var {{ getPrimaryKey.GetName }} {{IDType}}
+		if err := rows.Scan(&{{ getPrimaryKey.GetName }}); err != nil {
+			{{ if (hasID) }} return nil, fmt.Errorf("failed to scan {{ getPrimaryKey.GetName }}: %w", MapError(err)) {{ else }} return fmt.Errorf("failed to scan {{ getPrimaryKey.GetName }}: %w", MapError(err)) {{ end }}
+		}
+		{{- if (hasChildRelations) }}
+		ids = append(ids, {{ getPrimaryKey.GetName }})
+		{{- end }}
+		returnIDs = append(returnIDs, fmt.Sprint({{ getPrimaryKey.GetName }}))
 	}
+	{{ end }}
 
-	{{ if (hasID) }}
-	{{- range $index, $field := fields }}
-	{{- if and ($field | isRelation) ($field | relationAllowSubCreating) }}
-	    if options.relations && model.{{ $field | fieldName }} != nil { {{ if ($field | isRepeated) }}
-			for _, item := range model.{{ $field | fieldName }} {
-				item.{{ $field | getRefID }} = id
-				s, err := New{{ $field | relationStorageName }}(t.config)
-				if err != nil {
-					return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err)
-				}
+	if err := rows.Err(); err != nil {
+		{{ if (hasID) }} return nil, fmt.Errorf("rows iteration error: %w", MapError(err)) {{ else }} return fmt.Errorf("rows iteration error: %w", MapError(err)) {{ end }}
+	}
 
-                {{ if ($field | hasIDFromRelation) }} _, err = s.Create(ctx, item) {{ else }} err = s.Create(ctx, item) {{ end }}
-				if err != nil {
-				{{ if (hasID) }} return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ else }} return fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ end }}
+	{{- if and (hasID) (hasChildRelations) }}
+
+	if options.relations {
+		// release the connection before the relations are created
+		if err := rows.Close(); err != nil {
+			return nil, fmt.Errorf("failed to close rows: %w", MapError(err))
+		}
+
+		if len(ids) != len(models) {
+			return nil, fmt.Errorf("expected %d created {{ structureName }}, got %d", len(models), len(ids))
+		}
+		{{- range $index, $field := fields }}
+		{{- if and ($field | isRelation) ($field | relationAllowSubCreating) }}
+
+		// create the {{ $field | fieldName }} of all models in one batch
+		var {{ $field | fieldName | lowerCamelCase }}Items []*{{ $field | relationStructureName }}
+		for i, model := range models {
+			{{- if ($field | isRepeated) }}
+			for _, item := range model.{{ $field | fieldName }} {
+				if item == nil {
+					continue
 				}
-			} {{ else }}
+				item.{{ $field | getRefID }} = {{ if ($field | isRefOptional) }}&{{ end }}ids[i]
+				{{ $field | fieldName | lowerCamelCase }}Items = append({{ $field | fieldName | lowerCamelCase }}Items, item)
+			}
+			{{- else }}
+			if model.{{ $field | fieldName }} != nil {
+				model.{{ $field | fieldName }}.{{ $field | getRefID }} = {{ if ($field | isRefOptional) }}&{{ end }}ids[i]
+				{{ $field | fieldName | lowerCamelCase }}Items = append({{ $field | fieldName | lowerCamelCase }}Items, model.{{ $field | fieldName }})
+			}
+			{{- end }}
+		}
+		if len({{ $field | fieldName | lowerCamelCase }}Items) > 0 {
 			s, err := New{{ $field | relationStorageName }}(t.config)
 			if err != nil {
-				{{ if (hasID) }} return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ else }} return fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ end }}
+				return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err)
 			}
-
-			model.{{ $field | fieldName }}.{{ $field | getRefID }} = id
-			{{ if ($field | hasIDFromRelation) }} _, err = s.Create(ctx, model.{{ $field | fieldName }}) {{ else }} err = s.Create(ctx, model.{{ $field | fieldName }}) {{ end }}
+			{{ if ($field | hasIDFromRelation) }}_, err = s.BatchCreate(ctx, {{ $field | fieldName | lowerCamelCase }}Items, WithRelations()){{ else }}err = s.BatchCreate(ctx, {{ $field | fieldName | lowerCamelCase }}Items, WithRelations()){{ end }}
 			if err != nil {
-				{{ if (hasID) }} return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ else }} return fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ end }}
-			} {{- end}}
-	    } {{- end}}
-	{{- end}}
-	{{- end}}
+				return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err)
+			}
+		}
+		{{- end }}
+		{{- end }}
+	}
+	{{- end }}
 
-	{{ if (hasID) }} return &id, nil {{ else }} return nil {{ end }}
+	return {{ if (hasID) }} returnIDs, nil {{ else }} nil {{ end }}
 }
 `
 
-const TableStorageTemplate = `
-// {{ storageName | lowerCamelCase }} is a struct for the "{{ tableName }}" table.
-type {{ storageName | lowerCamelCase }} struct {
-	config *Config
-	queryBuilder sq.StatementBuilderType
-}
+const TableCreateMethodTemplate = `
+// Create creates a new {{ structureName }}.
+{{- if otel }}
+{{ if (hasID) }}func (t *{{ storageName | lowerCamelCase }}) Create(ctx context.Context, model *{{structureName}}, opts ...Option) (*{{IDType}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Create")
+	res, err := t.doCreate(ctx, model, opts...)
+	endSpan(span, err)
+	return res, err
+}{{ else }}func (t *{{ storageName | lowerCamelCase }}) Create(ctx context.Context, model *{{structureName}}, opts ...Option) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Create")
+	err := t.doCreate(ctx, model, opts...)
+	endSpan(span, err)
+	return err
+}{{ end }}
+
+// doCreate is Create without its span.
+{{- end }}
+{{ if (hasID) }} func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doCreate{{ else }}Create{{ end }}(ctx context.Context, model *{{structureName}}, opts ...Option) (*{{IDType}}, error) { {{ else }} func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doCreate{{ else }}Create{{ end }}(ctx context.Context, model *{{structureName}}, opts ...Option) error { {{ end }}
+	if model == nil {
+		{{ if (hasID) }}return nil, fmt.Errorf("model is nil") {{ else }}return fmt.Errorf("model is nil") {{ end }}
+	}
+
+	// set default options
+	options := &Options{}
+	for _, o := range opts {
+		o(options)
+	}
+
+	{{- if and (hasID) (hasChildRelations) }}
+
+	// create the model and its relations in one transaction
+	if options.relations {
+		if _, ok := TxFromContext(ctx); !ok {
+			var id *{{IDType}}
+			err := t.config.txManager().ExecFuncWithTx(ctx, func(ctx context.Context) error {
+				var err error
+				id, err = t.Create(ctx, model, opts...)
+				return err
+			})
+			return id, err
+		}
+	}
+	{{- end }}
+
+	{{- if (hasAutoTime) }}
+
+	// fill automatic time fields
+	t.fillAutoTime(model)
+	{{- end }}
+
+
+	query, err := t.insertQuery(model)
+	if err != nil {
+		{{ if (hasID) }} return nil, err {{ else }} return err {{ end }}
+	}
+	{{ if (hasID) }}
+	if options.ignoreConflictField != "" {
+		query = query.Suffix("ON CONFLICT ("+options.ignoreConflictField+") DO NOTHING RETURNING \"{{ getPrimaryKey.GetName }}\"")
+	} else {
+		{{- if (hasCompositePrimaryKey) }}
+		// Composite primary key: using first PK field for RETURNING
+		query = query.Suffix("RETURNING \"{{ getPrimaryKey.GetName }}\"")
+		{{- else }}
+		query = query.Suffix("RETURNING \"{{ getPrimaryKey.GetName }}\"")
+		{{- end }}
+	}
+	{{ else }}
+	if options.ignoreConflictField != "" {
+		query = query.Suffix("ON CONFLICT ("+options.ignoreConflictField+") DO NOTHING")
+	}
+	{{ end }}
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		{{ if (hasID) }} return nil, fmt.Errorf("failed to build query: %w", err) {{ else }} return fmt.Errorf("failed to build query: %w", err) {{ end }}
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	{{ if (hasID) }}var id {{IDType}}
+	err = t.DB(ctx, true).QueryRowContext(ctx,sqlQuery, args...).Scan(&id) {{ else }} _, err = t.DB(ctx, true).ExecContext(ctx,sqlQuery, args...) {{ end }}
+	if err != nil {
+		{{ if (hasID) }} return nil, fmt.Errorf("failed to create {{ structureName }}: %w", MapError(err)) {{ else }} return fmt.Errorf("failed to create {{ structureName }}: %w", MapError(err)) {{ end }}
+	}
+
+	{{ if (hasID) }}
+	{{- range $index, $field := fields }}
+	{{- if and ($field | isRelation) ($field | relationAllowSubCreating) }}
+	    if options.relations && model.{{ $field | fieldName }} != nil { {{ if ($field | isRepeated) }}
+			for _, item := range model.{{ $field | fieldName }} {
+				item.{{ $field | getRefID }} = {{ if ($field | isRefOptional) }}&{{ end }}id
+				s, err := New{{ $field | relationStorageName }}(t.config)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err)
+				}
+
+                {{ if ($field | hasIDFromRelation) }} _, err = s.Create(ctx, item, WithRelations()) {{ else }} err = s.Create(ctx, item, WithRelations()) {{ end }}
+				if err != nil {
+				{{ if (hasID) }} return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ else }} return fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ end }}
+				}
+			} {{ else }}
+			s, err := New{{ $field | relationStorageName }}(t.config)
+			if err != nil {
+				{{ if (hasID) }} return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ else }} return fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ end }}
+			}
+
+			model.{{ $field | fieldName }}.{{ $field | getRefID }} = {{ if ($field | isRefOptional) }}&{{ end }}id
+			{{ if ($field | hasIDFromRelation) }} _, err = s.Create(ctx, model.{{ $field | fieldName }}, WithRelations()) {{ else }} err = s.Create(ctx, model.{{ $field | fieldName }}, WithRelations()) {{ end }}
+			if err != nil {
+				{{ if (hasID) }} return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ else }} return fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ end }}
+			} {{- end}}
+	    } {{- end}}
+	{{- end}}
+	{{- end}}
+
+	{{ if (hasID) }} return &id, nil {{ else }} return nil {{ end }}
+}
+
+// insertQuery builds the INSERT statement for the {{ structureName }}.
+func (t *{{ storageName | lowerCamelCase }}) insertQuery(model *{{structureName}}) (sq.InsertBuilder, error) {
+
+	{{- range $index, $field := fields }}
+	{{- if not ($field | isRelation) }}
+	{{- if ($field | isRepeated) }}
+	// get value of {{ $field | fieldName | lowerCamelCase }}
+	{{ $field | fieldName | lowerCamelCase }}, err := model.{{ $field | fieldName }}.Value()
+	if err != nil {
+		return sq.InsertBuilder{}, fmt.Errorf("failed to get value of {{ $field | fieldName }}: %w", err)
+	}
+	{{- end}}
+	{{- end}}
+	{{- end}}
+
+	query := t.queryBuilder.Insert("{{ tableName }}").
+		Columns(
+			{{- range $index, $field := fields }}
+			{{- if not ($field | isRelation) }}
+			{{- if not ($field | isAutoIncrement ) }}
+			{{- if not ($field | isDefaultUUID ) }}
+			"{{ $field | sourceName }}",
+			{{- end}}
+			{{- end}}
+			{{- end}}
+			{{- end}}
+		).
+		Values(
+			{{- range $index, $field := fields }}
+			{{- if not ($field | isRelation) }}
+			{{- if not ($field | isAutoIncrement ) }}
+			{{- if not ($field | isDefaultUUID ) }}
+			
+			{{- if ($field | isRepeated) }}
+				{{ $field | fieldName | lowerCamelCase }},
+			{{- else }}
+			
+				{{- if (findPointer $field) }}
+				nullValue(model.{{ $field | fieldName }}),
+				{{- else }}
+				model.{{ $field | fieldName }},
+				{{- end }}
+
+			{{- end}}
+
+			{{- end}}
+			{{- end}}
+			{{- end}}
+			{{- end}}
+	)
+
+
+	return query, nil
+}
+`
+
+const TableStorageTemplate = `
+// {{ storageName | lowerCamelCase }} is a struct for the "{{ tableName }}" table.
+type {{ storageName | lowerCamelCase }} struct {
+	config *Config
+	queryBuilder sq.StatementBuilderType
+}
 
 {{ if .CRUDSchemas }}
 // {{structureName}}TableManager is an interface for managing the {{ tableName }} table.
@@ -1001,13 +1679,24 @@ type {{structureName}}CRUDOperations interface {
 	{{- else }}
 	BatchCreate(ctx context.Context, models []*{{structureName}}, opts ...Option) error
 	{{- end }}
-	Update(ctx context.Context, id {{IDType}}, updateData *{{structureName}}Update) error
+	{{- if (hasID) }}
+	BatchUpsert(ctx context.Context, models []*{{structureName}}, updateFields []string, opts ...Option) ([]string, error)
+	{{- else }}
+	BatchUpsert(ctx context.Context, models []*{{structureName}}, updateFields []string, opts ...Option) error
+	{{- end }}
+	CopyFrom(ctx context.Context, models []*{{structureName}}) (int64, error)
+	CopyFromChan(ctx context.Context, models <-chan *{{structureName}}) (int64, error)
+	Update(ctx context.Context, id {{ keyType }}, updateData *{{structureName}}Update, opts ...Option) (int64, error)
+	CreateReturning(ctx context.Context, model *{{structureName}}, opts ...Option) (*{{structureName}}, error)
+	UpsertReturning(ctx context.Context, model *{{structureName}}, updateFields []string, opts ...Option) (*{{structureName}}, error)
+	UpdateReturning(ctx context.Context, id {{ keyType }}, updateData *{{structureName}}Update) (*{{structureName}}, error)
+	UpdateMany(ctx context.Context, updateData *{{structureName}}Update, builders ...*QueryBuilder) (int64, error)
 	{{- if (hasPrimaryKey) }}
-	DeleteBy{{ getPrimaryKey.GetName | camelCase }}(ctx context.Context, {{getPrimaryKey.GetName | lowerCamelCase}} {{IDType}}, opts ...Option) error
+	DeleteBy{{ primaryKeyName | camelCase }}(ctx context.Context, {{primaryKeyName | lowerCamelCase}} {{ keyType }}, opts ...Option) (int64, error)
 	{{- end }}
 	{{- if (hasPrimaryKey) }}
-	FindBy{{ getPrimaryKey.GetName | camelCase }}(ctx context.Context, id {{IDType}}, opts ...Option) (*{{ structureName }}, error)
-	Get{{ getPrimaryKey.GetName | camelCase }}Field(ctx context.Context, {{getPrimaryKey.GetName | lowerCamelCase}} {{IDType}}, field string) (interface{}, error)
+	FindBy{{ primaryKeyName | camelCase }}(ctx context.Context, id {{ keyType }}, opts ...Option) (*{{ structureName }}, error)
+	Get{{ primaryKeyName | camelCase }}Field(ctx context.Context, {{primaryKeyName | lowerCamelCase}} {{ keyType }}, field string) (interface{}, error)
 	{{- end }}
 }
 
@@ -1036,11 +1725,56 @@ type {{structureName}}RelationLoading interface {
 	LoadBatch{{ $field | pluralFieldName }} (ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) error
 	{{- end }}
 	{{- end }}
+	{{- range $index, $field := fields }}
+	{{- if and ($field | isRelation) ($field | isRepeated) (not ($field | isManyToMany)) (not ($field | isCompositeRelation)) }}
+	Load{{ $field | pluralFieldName }}Count (ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) (map[{{ $field | relationKeyType }}]int64, error)
+	{{- end }}
+	{{- end }}
+}
+
+{{- if (hasManyToMany) }}
+
+// {{structureName}}RelationLinking is an interface for linking many-to-many relations.
+type {{structureName}}RelationLinking interface {
+	{{- range $index, $field := fields }}
+	{{- if ($field | isManyToMany) }}
+	Attach{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error
+	Detach{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error
+	Sync{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error
+	{{- end }}
+	{{- end }}
+}
+{{- end }}
+
+{{- if (hasSelfRelation) }}
+
+// {{structureName}}TreeOperations is an interface for recursive queries over the self relation.
+type {{structureName}}TreeOperations interface {
+	Ancestors(ctx context.Context, {{ treeKey.GetName | lowerCamelCase }} {{ treeKey | fieldType }}) ([]*{{structureName}}Node, error)
+	Descendants(ctx context.Context, {{ treeKey.GetName | lowerCamelCase }} {{ treeKey | fieldType }}, maxDepth int) ([]*{{structureName}}Node, error)
+	Tree(ctx context.Context, rootID {{ treeKey | fieldType }}) (*{{structureName}}Node, error)
+}
+{{- end }}
+
+{{- if (hasUniqueKeys) }}
+
+// {{structureName}}UniqueOperations is an interface for finding by the unique fields and indexes.
+type {{structureName}}UniqueOperations interface {
+	{{- range $key := uniqueKeys }}
+	FindBy{{ $key.Name }}(ctx context.Context, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}, {{ end }}opts ...Option) (*{{ structureName }}, error)
+	ExistsBy{{ $key.Name }}(ctx context.Context{{ range $key.Fields }}, {{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}{{ end }}) (bool, error)
+	DeleteBy{{ $key.Name }}(ctx context.Context, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}, {{ end }}opts ...Option) (int64, error)
+	{{- if eq (len $key.Fields) 1 }}
+	{{- $field := index $key.Fields 0 }}
+	FindManyBy{{ $field.GetName | plural | camelCase }}(ctx context.Context, {{ $field.GetName | plural | lowerCamelCase }} []{{ if (findPointer $field) }}{{ $field | fieldTypeWP }}{{ else }}{{ $field | fieldType }}{{ end }}, opts ...Option) (map[{{ if (findPointer $field) }}{{ $field | fieldTypeWP }}{{ else }}{{ $field | fieldType }}{{ end }}]*{{ structureName }}, error)
+	{{- end }}
+	{{- end }}
 }
+{{- end }}
 
 // {{structureName}}AdvancedDeletion is an interface for advanced deletion operations.
 type {{structureName}}AdvancedDeletion interface {
-	DeleteMany(ctx context.Context, builders ...*QueryBuilder) error
+	DeleteMany(ctx context.Context, builders ...*QueryBuilder) (int64, error)
 }
 
 // {{structureName}}RawQueryOperations is an interface for executing raw queries.
@@ -1059,6 +1793,15 @@ type {{ storageName }} interface {
 	{{structureName}}SearchOperations
 	{{structureName}}PaginationOperations
 	{{structureName}}RelationLoading
+	{{- if (hasManyToMany) }}
+	{{structureName}}RelationLinking
+	{{- end }}
+	{{- if (hasSelfRelation) }}
+	{{structureName}}TreeOperations
+	{{- end }}
+	{{- if (hasUniqueKeys) }}
+	{{structureName}}UniqueOperations
+	{{- end }}
 	{{structureName}}AdvancedDeletion
 	{{structureName}}RawQueryOperations
 }
@@ -1106,6 +1849,51 @@ func (t *{{ storageName | lowerCamelCase }}) logError(ctx context.Context, err e
 	}
 }
 
+{{- if (hasAutoTime) }}
+
+// now returns the current time from the configured clock.
+func (t *{{ storageName | lowerCamelCase }}) now() time.Time {
+	if t.config.Clock != nil {
+		return t.config.Clock()
+	}
+	return time.Now()
+}
+
+// fillAutoTime fills the automatic time fields of the {{ structureName }} that are not set yet.
+func (t *{{ storageName | lowerCamelCase }}) fillAutoTime(model *{{ structureName }}) {
+	now := t.now()
+	{{- range $index, $field := fields }}
+	{{- if or ($field | isAutoCreateTime) ($field | isAutoUpdateTime) }}
+	if {{ autoTimeIsZero $field (printf "model.%s" ($field | fieldName)) }} {
+		{{- if ($field | findPointer) }}
+		{{ $field | fieldName | lowerCamelCase }} := {{ $field | autoTimeValue }}
+		model.{{ $field | fieldName }} = &{{ $field | fieldName | lowerCamelCase }}
+		{{- else }}
+		model.{{ $field | fieldName }} = {{ $field | autoTimeValue }}
+		{{- end }}
+	}
+	{{- end }}
+	{{- end }}
+}
+{{- end }}
+{{- if (hasAutoUpdateTime) }}
+
+// touchAutoUpdateTime sets the automatic update time fields of the {{ structureName }} to the current time.
+func (t *{{ storageName | lowerCamelCase }}) touchAutoUpdateTime(model *{{ structureName }}) {
+	now := t.now()
+	{{- range $index, $field := fields }}
+	{{- if ($field | isAutoUpdateTime) }}
+	{{- if ($field | findPointer) }}
+	{{ $field | fieldName | lowerCamelCase }} := {{ $field | autoTimeValue }}
+	model.{{ $field | fieldName }} = &{{ $field | fieldName | lowerCamelCase }}
+	{{- else }}
+	model.{{ $field | fieldName }} = {{ $field | autoTimeValue }}
+	{{- end }}
+	{{- end }}
+	{{- end }}
+}
+{{- end }}
+
 // TableName returns the table name.
 func (t *{{ storageName | lowerCamelCase }}) TableName() string {
 	return "{{ tableName }}"
@@ -1125,18 +1913,26 @@ func (t *{{ storageName | lowerCamelCase }}) DB(ctx context.Context, isWrite boo
 		if tx == nil {
 			t.logError(ctx, fmt.Errorf("transaction is nil"), "failed to get transaction from context")
 			// set default connection
-			return &dbWrapper{db: t.config.DB.DBWrite, config: t.config}
+			return &dbWrapper{db: t.config.DB.DBWrite, config: t.config, table: t.TableName()}
 		}
 
-		return &dbWrapper{db: tx, config: t.config}
+		return &dbWrapper{db: tx, config: t.config, table: t.TableName()}
 	}
 
 	// Use the appropriate connection based on the operation type.
-	if isWrite {
-		return &dbWrapper{db: t.config.DB.DBWrite, config: t.config}
-	} else {
-		return &dbWrapper{db: t.config.DB.DBRead, config: t.config}
+	if isWrite || UsePrimary(ctx) {
+		return &dbWrapper{db: t.config.DB.DBWrite, config: t.config, table: t.TableName()}
+	}
+
+	// Spread the reads over the replicas, falling back to the primary when none is healthy.
+	if len(t.config.DB.Replicas) > 0 {
+		if replica := selectReplica(t.config.DB.Replicas, t.config.DB.ReplicaSelector); replica != nil {
+			return &dbWrapper{db: &replicaRead{replica: replica, primary: t.config.DB.DBWrite}, config: t.config, table: t.TableName()}
+		}
+		return &dbWrapper{db: t.config.DB.DBWrite, config: t.config, table: t.TableName()}
 	}
+
+	return &dbWrapper{db: t.config.DB.DBRead, config: t.config, table: t.TableName()}
 }
 
 {{ if .CRUDSchemas }}
@@ -1186,7 +1982,16 @@ func (t *{{ storageName | lowerCamelCase }}) CreateTable(ctx context.Context) er
 		{{- end}}
 		{{- end}}
 		{{- range $index, $field := fields }}
-		{{- if ($field | isRelation) }}
+		{{- if ($field | isManyToMany) }}
+		{{- $rel := ($field | relation) }}
+		-- Join table for {{ $field | relationTableName }}
+		CREATE TABLE IF NOT EXISTS {{ $rel.Through }} (
+		{{ $rel.ThroughField }} {{ $field | relationKey | postgresType }} NOT NULL,
+		{{ $rel.ThroughReference }} {{ $field | relationRefKey | postgresType }} NOT NULL,
+		PRIMARY KEY ({{ $rel.ThroughField }}, {{ $rel.ThroughReference }})
+		);
+		CREATE INDEX IF NOT EXISTS {{ $rel.Through }}_{{ $rel.ThroughReference }}_idx ON {{ $rel.Through }} USING btree ({{ $rel.ThroughReference }});
+		{{- else if ($field | isRelation) }}
 		{{- if ($field | isForeign) }}
 		-- Foreign keys for {{ $field | relationTableName }}
 		ALTER TABLE {{ tableName }}
@@ -1234,11 +2039,26 @@ func (t *{{ storageName | lowerCamelCase }}) UpgradeTable(ctx context.Context) e
 {{- range $index, $field := fields }}
 {{- if and ($field | isRelation) }}
 // Load{{ $field | pluralFieldName }} loads the {{ $field | pluralFieldName }} relation.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) Load{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, builders ...*QueryBuilder) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Load{{ $field | pluralFieldName }}")
+	err := t.doLoad{{ $field | pluralFieldName }}(ctx, model, builders...)
+	endSpan(span, err)
+	return err
+}
+
+// doLoad{{ $field | pluralFieldName }} is Load{{ $field | pluralFieldName }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doLoad{{ $field | pluralFieldName }}{{ else }}Load{{ $field | pluralFieldName }}{{ end }}(ctx context.Context, model *{{structureName}}, builders ...*QueryBuilder) error {
 	if model == nil {
 		return fmt.Errorf("{{structureName}} is nil")
 	}
 
+	{{- if or ($field | isManyToMany) ($field | isCompositeRelation) }}
+
+	return t.LoadBatch{{ $field | pluralFieldName }}(ctx, []*{{structureName}}{model}, builders...)
+	{{- else }}
+
 	// New{{ $field | relationStorageName }} creates a new {{ $field | relationStorageName }}.
 	s, err := New{{ $field | relationStorageName }}(t.config)
 	if err != nil {
@@ -1274,6 +2094,7 @@ func (t *{{ storageName | lowerCamelCase }}) Load{{ $field | pluralFieldName }}(
 		model.{{ $field | fieldName }} = relationModel
 	{{- end }}
 	return nil
+	{{- end }}
 }
 {{- end }}
 {{- end }}
@@ -1281,60 +2102,206 @@ func (t *{{ storageName | lowerCamelCase }}) Load{{ $field | pluralFieldName }}(
 {{- range $index, $field := fields }}
 {{- if and ($field | isRelation) }}
 // LoadBatch{{ $field | pluralFieldName }} loads the {{ $field | pluralFieldName }} relation.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) LoadBatch{{ $field | pluralFieldName }}(ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) error {
-	requestItems := make([]interface{}, 0, len(items))
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.LoadBatch{{ $field | pluralFieldName }}")
+	err := t.doLoadBatch{{ $field | pluralFieldName }}(ctx, items, builders...)
+	endSpan(span, err)
+	return err
+}
+
+// doLoadBatch{{ $field | pluralFieldName }} is LoadBatch{{ $field | pluralFieldName }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doLoadBatch{{ $field | pluralFieldName }}{{ else }}LoadBatch{{ $field | pluralFieldName }}{{ end }}(ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) error {
+	{{- if ($field | isManyToMany) }}
+	{{- $rel := ($field | relation) }}
+	keys := make([]interface{}, 0, len(items))
 	for _, item := range items {
-		{{- if ($field | isOptional) }}
-			// Check if the field is nil for optional fields
-			if item.{{ $field | getFieldID }} == nil {
-				// Skip nil values for optional fields
-				continue
-			}
-			// Append dereferenced value for optional fields
-			requestItems = append(requestItems, *item.{{ $field | getFieldID }})
-		{{- else }}
-			// Append the value directly for non-optional fields
-			requestItems = append(requestItems, item.{{ $field | getFieldID }})
-		{{- end }}
+		keys = append(keys, item.{{ $field | getFieldID }})
+	}
+	if len(keys) == 0 {
+		return nil
 	}
 
-	// New{{ $field | relationStorageName }} creates a new {{ $field | relationStorageName }}.
-	s, err := New{{ $field | relationStorageName }}(t.config)
+	// Read the links from the join table
+	sqlQuery, args, err := t.queryBuilder.Select("{{ $rel.ThroughField }}", "{{ $rel.ThroughReference }}").
+		From("{{ $rel.Through }}").
+		Where(sq.Eq{"{{ $rel.ThroughField }}": keys}).
+		ToSql()
 	if err != nil {
-		return fmt.Errorf("failed to create {{ $field | relationStorageName }}: %w", err)
+		return fmt.Errorf("failed to build query: %w", err)
 	}
+	t.logQuery(ctx, sqlQuery, args...)
 
-	// Add the filter for the relation
-	{{- if ($field | isOptional) }}
-		// Ensure that requestItems are not empty before adding the builder
-		if len(requestItems) > 0 {
-			builders = append(builders, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}In(requestItems...)))
-		}
-	{{- else }}
-		builders = append(builders, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}In(requestItems...)))
-	{{- end }}
-
-	results, err := s.FindMany(ctx, builders...)
+	rows, err := t.DB(ctx, false).QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
-		return fmt.Errorf("failed to find many {{ $field | relationStorageName }}: %w", err)
+		return fmt.Errorf("failed to find {{ $rel.Through }} links: %w", MapError(err))
 	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			t.logError(ctx, err, "failed to close rows")
+		}
+	}()
 
-	{{- if ($field | isRepeated) }}
-	resultMap := make(map[interface{}][]*{{ $field | relationStructureName }})
-	{{- else }}
-	resultMap := make(map[interface{}]*{{ $field | relationStructureName }})
-	{{- end }}
-	for _, result := range results {
-		{{- if ($field | isRepeated) }}
-		resultMap[result.{{ $field | getRefID }}] = append(resultMap[result.{{ $field | getRefID }}], result)
-		{{- else }}
-		resultMap[result.{{ $field | getRefID }}] = result
-		{{- end }}
+	parents := make(map[{{ $field | relationRefKey | fieldType }}][]{{ $field | relationKey | fieldType }})
+	refs := make([]interface{}, 0)
+	for rows.Next() {
+		var key {{ $field | relationKey | fieldType }}
+		var ref {{ $field | relationRefKey | fieldType }}
+		if err := rows.Scan(&key, &ref); err != nil {
+			return fmt.Errorf("failed to scan {{ $rel.Through }} link: %w", MapError(err))
+		}
+		if _, ok := parents[ref]; !ok {
+			refs = append(refs, ref)
+		}
+		parents[ref] = append(parents[ref], key)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate over rows: %w", MapError(err))
 	}
 
-	// Assign {{ $field | relationStructureName }} to items
-	for _, item := range items {
-		{{- if ($field | isOptional) }}
+	related := make(map[{{ $field | relationKey | fieldType }}][]*{{ $field | relationStructureName }})
+	if len(refs) > 0 {
+		// New{{ $field | relationStorageName }} creates a new {{ $field | relationStorageName }}.
+		s, err := New{{ $field | relationStorageName }}(t.config)
+		if err != nil {
+			return fmt.Errorf("failed to create {{ $field | relationStorageName }}: %w", err)
+		}
+
+		builders = append(builders, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}In(refs...)))
+		results, err := s.FindMany(ctx, builders...)
+		if err != nil {
+			return fmt.Errorf("failed to find many {{ $field | relationStorageName }}: %w", err)
+		}
+
+		// Keep the order of the results for every item
+		for _, result := range results {
+			for _, key := range parents[result.{{ $field | getRefID }}] {
+				related[key] = append(related[key], result)
+			}
+		}
+	}
+
+	// Assign {{ $field | relationStructureName }} to items
+	for _, item := range items {
+		item.{{ $field | fieldName }} = related[item.{{ $field | getFieldID }}]
+	}
+
+	return nil
+	{{- else if ($field | isCompositeRelation) }}
+	conditions := make([]FilterApplier, 0, len(items))
+	seen := make(map[[{{ len ($field | relationFields) }}]interface{}]bool, len(items))
+	for _, item := range items {
+		key := [{{ len ($field | relationFields) }}]interface{}{ {{- range $i, $f := ($field | relationFields) }}{{ if $i }}, {{ end }}item.{{ $f | fieldName }}{{ end -}} }
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		conditions = append(conditions, And(
+			{{- range $i, $ref := ($field | relationReferences) }}
+			Eq("{{ $ref.GetName }}", key[{{ $i }}]),
+			{{- end }}
+		))
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	// New{{ $field | relationStorageName }} creates a new {{ $field | relationStorageName }}.
+	s, err := New{{ $field | relationStorageName }}(t.config)
+	if err != nil {
+		return fmt.Errorf("failed to create {{ $field | relationStorageName }}: %w", err)
+	}
+
+	// Add the filter for the relation, a row matches one of the keys
+	builders = append(builders, FilterBuilder(Or(conditions...)), partitionBuilder("{{ range $i, $ref := ($field | relationReferences) }}{{ if $i }}, {{ end }}{{ $ref.GetName }}{{ end }}"))
+
+	results, err := s.FindMany(ctx, builders...)
+	if err != nil {
+		return fmt.Errorf("failed to find many {{ $field | relationStorageName }}: %w", err)
+	}
+
+	{{- if ($field | isRepeated) }}
+	resultMap := make(map[[{{ len ($field | relationFields) }}]interface{}][]*{{ $field | relationStructureName }})
+	{{- else }}
+	resultMap := make(map[[{{ len ($field | relationFields) }}]interface{}]*{{ $field | relationStructureName }})
+	{{- end }}
+	for _, result := range results {
+		key := [{{ len ($field | relationFields) }}]interface{}{ {{- range $i, $ref := ($field | relationReferences) }}{{ if $i }}, {{ end }}result.{{ $ref | fieldName }}{{ end -}} }
+		{{- if ($field | isRepeated) }}
+		resultMap[key] = append(resultMap[key], result)
+		{{- else }}
+		resultMap[key] = result
+		{{- end }}
+	}
+
+	// Assign {{ $field | relationStructureName }} to items
+	for _, item := range items {
+		key := [{{ len ($field | relationFields) }}]interface{}{ {{- range $i, $f := ($field | relationFields) }}{{ if $i }}, {{ end }}item.{{ $f | fieldName }}{{ end -}} }
+		if v, ok := resultMap[key]; ok {
+			item.{{ $field | fieldName }} = v
+		}
+	}
+
+	return nil
+	{{- else }}
+	requestItems := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		{{- if ($field | isOptional) }}
+			// Check if the field is nil for optional fields
+			if item.{{ $field | getFieldID }} == nil {
+				// Skip nil values for optional fields
+				continue
+			}
+			// Append dereferenced value for optional fields
+			requestItems = append(requestItems, *item.{{ $field | getFieldID }})
+		{{- else }}
+			// Append the value directly for non-optional fields
+			requestItems = append(requestItems, item.{{ $field | getFieldID }})
+		{{- end }}
+	}
+	if len(requestItems) == 0 {
+		return nil
+	}
+
+	// New{{ $field | relationStorageName }} creates a new {{ $field | relationStorageName }}.
+	s, err := New{{ $field | relationStorageName }}(t.config)
+	if err != nil {
+		return fmt.Errorf("failed to create {{ $field | relationStorageName }}: %w", err)
+	}
+
+	// Add the filter for the relation
+	builders = append(builders, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}In(requestItems...)), partitionBuilder("{{ ($field | relation).Reference }}"))
+
+	results, err := s.FindMany(ctx, builders...)
+	if err != nil {
+		return fmt.Errorf("failed to find many {{ $field | relationStorageName }}: %w", err)
+	}
+
+	{{- if ($field | isRepeated) }}
+	resultMap := make(map[interface{}][]*{{ $field | relationStructureName }})
+	{{- else }}
+	resultMap := make(map[interface{}]*{{ $field | relationStructureName }})
+	{{- end }}
+	for _, result := range results {
+		{{- if ($field | isRefOptional) }}
+		if result.{{ $field | getRefID }} == nil {
+			continue
+		}
+		key := *result.{{ $field | getRefID }}
+		{{- else }}
+		key := result.{{ $field | getRefID }}
+		{{- end }}
+		{{- if ($field | isRepeated) }}
+		resultMap[key] = append(resultMap[key], result)
+		{{- else }}
+		resultMap[key] = result
+		{{- end }}
+	}
+
+	// Assign {{ $field | relationStructureName }} to items
+	for _, item := range items {
+		{{- if ($field | isOptional) }}
 			// Skip assignment if the field is nil
 			if item.{{ $field | getFieldID }} == nil {
 				continue
@@ -1352,136 +2319,177 @@ func (t *{{ storageName | lowerCamelCase }}) LoadBatch{{ $field | pluralFieldNam
 	}
 
 	return nil
+	{{- end }}
 }
 {{- end }}
 {{- end }}
-`
 
-const TableUpsertMethodTemplate = `
-// Upsert creates a new {{ structureName }} or updates existing one on conflict.
-{{ if (hasID) }} func (t *{{ storageName | lowerCamelCase }}) Upsert(ctx context.Context, model *{{structureName}}, updateFields []string, opts ...Option) (*{{IDType}}, error) { {{ else }} func (t *{{ storageName | lowerCamelCase }}) Upsert(ctx context.Context, model *{{structureName}}, updateFields []string, opts ...Option) error { {{ end }}
-	if model == nil {
-		{{ if (hasID) }}return nil, fmt.Errorf("model is nil") {{ else }}return fmt.Errorf("model is nil") {{ end }}
+{{- range $index, $field := fields }}
+{{- if and ($field | isRelation) ($field | isRepeated) (not ($field | isManyToMany)) (not ($field | isCompositeRelation)) }}
+{{- $rel := ($field | relation) }}
+
+// Load{{ $field | pluralFieldName }}Count counts the {{ $field | pluralFieldName }} of every item with one GROUP BY query.
+// The filters of the builders apply to the related rows, items without related rows have no entry.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Load{{ $field | pluralFieldName }}Count(ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) (map[{{ $field | relationKeyType }}]int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Load{{ $field | pluralFieldName }}Count")
+	res, err := t.doLoad{{ $field | pluralFieldName }}Count(ctx, items, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doLoad{{ $field | pluralFieldName }}Count is Load{{ $field | pluralFieldName }}Count without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doLoad{{ $field | pluralFieldName }}Count{{ else }}Load{{ $field | pluralFieldName }}Count{{ end }}(ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) (map[{{ $field | relationKeyType }}]int64, error) {
+	keys := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		{{- if ($field | isOptional) }}
+		if item.{{ $field | getFieldID }} == nil {
+			continue
+		}
+		keys = append(keys, *item.{{ $field | getFieldID }})
+		{{- else }}
+		keys = append(keys, item.{{ $field | getFieldID }})
+		{{- end }}
 	}
 
-	// set default options
-	options := &Options{}
-	for _, o := range opts {
-		o(options)
+	counts := make(map[{{ $field | relationKeyType }}]int64, len(keys))
+	if len(keys) == 0 {
+		return counts, nil
 	}
 
-	{{- range $index, $field := fields }}
-	{{- if not ($field | isRelation) }}
-	{{- if ($field | isRepeated) }}
-	// get value of {{ $field | fieldName | lowerCamelCase }}
-	{{ $field | fieldName | lowerCamelCase }}, err := model.{{ $field | fieldName }}.Value()
+	query := t.queryBuilder.Select("{{ $rel.Reference }}", "COUNT(*)").
+		From("{{ $field | relationTableName }}").
+		Where(sq.Eq{"{{ $rel.Reference }}": keys}).
+		GroupBy("{{ $rel.Reference }}")
+	for _, builder := range builders {
+		if builder == nil {
+			continue
+		}
+
+		// apply filter options of the related rows
+		for _, option := range builder.filterOptions {
+			query = option.Apply(query)
+		}
+	}
+
+	sqlQuery, args, err := query.ToSql()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get value of {{ $field | fieldName }}: %w", err)
+		return nil, fmt.Errorf("failed to build query: %w", err)
 	}
-	{{- end}}
-	{{- end}}
-	{{- end}}
+	t.logQuery(ctx, sqlQuery, args...)
 
-	// Build INSERT query
-	query := t.queryBuilder.Insert("{{ tableName }}").
-		Columns(
-			{{- range $index, $field := fields }}
-			{{- if not ($field | isRelation) }}
-			{{- if not ($field | isAutoIncrement ) }}
-			{{- if not ($field | isDefaultUUID ) }}
-			"{{ $field | sourceName }}",
-			{{- end}}
-			{{- end}}
-			{{- end}}
-			{{- end}}
-		).
-		Values(
-			{{- range $index, $field := fields }}
-			{{- if not ($field | isRelation) }}
-			{{- if not ($field | isAutoIncrement ) }}
-			{{- if not ($field | isDefaultUUID ) }}
-			
-			{{- if ($field | isRepeated) }}
-				{{ $field | fieldName | lowerCamelCase }},
-			{{- else }}
-			
-				{{- if (findPointer $field) }}
-				nullValue(model.{{ $field | fieldName }}),
-				{{- else }}
-				model.{{ $field | fieldName }},
-				{{- end }}
+	rows, err := t.DB(ctx, false).QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count {{ $field | pluralFieldName }}: %w", MapError(err))
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			t.logError(ctx, err, "failed to close rows")
+		}
+	}()
 
-			{{- end}}
+	for rows.Next() {
+		var key {{ $field | relationKeyType }}
+		var count int64
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan {{ $field | pluralFieldName }} count: %w", MapError(err))
+		}
+		counts[key] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over rows: %w", MapError(err))
+	}
 
-			{{- end}}
-			{{- end}}
-			{{- end}}
-			{{- end}}
-		)
+	return counts, nil
+}
+{{- end }}
+{{- end }}
 
-	// Build UPDATE SET clause based on updateFields
-	updateSet := make([]string, 0, len(updateFields))
-	for _, field := range updateFields {
+{{- range $index, $field := fields }}
+{{- if and ($field | isRelation) }}
+
+// {{ structureName }}Rel{{ $field | fieldName }} identifies the {{ $field | fieldName }} relation of {{ structureName }} for Preload.
+var {{ structureName }}Rel{{ $field | fieldName }} = Relation{name: "{{ structureName }}.{{ $field | fieldName }}"}
+{{- end }}
+{{- end }}
+
+// preload loads the given relations into items with one batched query per relation.
+// Nested relations are preloaded by the related storage.
+func (t *{{ storageName | lowerCamelCase }}) preload(ctx context.Context, items []*{{structureName}}, relations []Relation) error {
+	for _, relation := range mergeRelations(relations) {
+		switch relation.name {
 		{{- range $index, $field := fields }}
-		{{- if not ($field | isRelation) }}
-		{{- if not ($field | isAutoIncrement ) }}
-		{{- if not ($field | isDefaultUUID ) }}
-		{{- if not ($field | isPrimaryKey ) }}
-		if field == "{{ $field | sourceName }}" {
-			updateSet = append(updateSet, "{{ $field | sourceName }} = EXCLUDED.{{ $field | sourceName }}")
+		{{- if and ($field | isRelation) }}
+		case {{ structureName }}Rel{{ $field | fieldName }}.name:
+			if err := t.LoadBatch{{ $field | pluralFieldName }}(ctx, items, relation.builders...); err != nil {
+				return fmt.Errorf("failed to preload %s: %w", relation, err)
+			}
+		{{- end }}
+		{{- end }}
+		default:
+			return fmt.Errorf("unknown relation %s for {{ structureName }}", relation)
 		}
-		{{- end}}
-		{{- end}}
-		{{- end}}
-		{{- end}}
-		{{- end}}
 	}
 
-	// Note: You can manually add updated_at to updateFields if needed
+	return nil
+}
+`
 
-	// Build the complete suffix with ON CONFLICT, UPDATE SET, and RETURNING in one string
-	var suffixBuilder strings.Builder
+const TableUpsertMethodTemplate = `
+// Upsert creates a new {{ structureName }} or updates existing one on conflict.
+{{- if defaultConflictTarget }}
+// The conflict target is ({{ defaultConflictTarget }}) unless set with WithIgnoreConflictField.
+{{- else }}
+// The conflict target must be set with WithIgnoreConflictField.
+{{- end }}
+{{- if otel }}
+{{ if (hasID) }}func (t *{{ storageName | lowerCamelCase }}) Upsert(ctx context.Context, model *{{structureName}}, updateFields []string, opts ...Option) (*{{IDType}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Upsert")
+	res, err := t.doUpsert(ctx, model, updateFields, opts...)
+	endSpan(span, err)
+	return res, err
+}{{ else }}func (t *{{ storageName | lowerCamelCase }}) Upsert(ctx context.Context, model *{{structureName}}, updateFields []string, opts ...Option) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Upsert")
+	err := t.doUpsert(ctx, model, updateFields, opts...)
+	endSpan(span, err)
+	return err
+}{{ end }}
 
-	// Add ON CONFLICT clause
-	{{- if (hasPrimaryKey) }}
-	if options.ignoreConflictField != "" {
-		suffixBuilder.WriteString("ON CONFLICT (")
-		suffixBuilder.WriteString(options.ignoreConflictField)
-		suffixBuilder.WriteString(") DO UPDATE SET ")
-	} else {
-		{{- if (hasCompositePrimaryKey) }}
-		// Composite primary key: {{ range $index, $pk := getPrimaryKeys }}{{ if $index }} + {{ end }}{{ $pk.GetName }}{{ end }}
-		suffixBuilder.WriteString("ON CONFLICT ({{ range $index, $pk := getPrimaryKeys }}{{ if $index }}, {{ end }}{{ $pk.GetName }}{{ end }}) DO UPDATE SET ")
-		{{- else }}
-		suffixBuilder.WriteString("ON CONFLICT ({{ getPrimaryKey.GetName }}) DO UPDATE SET ")
-		{{- end }}
+// doUpsert is Upsert without its span.
+{{- end }}
+{{ if (hasID) }} func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doUpsert{{ else }}Upsert{{ end }}(ctx context.Context, model *{{structureName}}, updateFields []string, opts ...Option) (*{{IDType}}, error) { {{ else }} func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doUpsert{{ else }}Upsert{{ end }}(ctx context.Context, model *{{structureName}}, updateFields []string, opts ...Option) error { {{ end }}
+	if model == nil {
+		{{ if (hasID) }}return nil, fmt.Errorf("model is nil") {{ else }}return fmt.Errorf("model is nil") {{ end }}
 	}
-	{{- else }}
-	// For tables without primary key, you need to specify conflict target
-	if options.ignoreConflictField != "" {
-		suffixBuilder.WriteString("ON CONFLICT (")
-		suffixBuilder.WriteString(options.ignoreConflictField)
-		suffixBuilder.WriteString(") DO UPDATE SET ")
-	} else {
-		// This is a placeholder - you may need to customize based on your unique constraints
-		suffixBuilder.WriteString("ON CONFLICT DO UPDATE SET ")
+
+	// set default options
+	options := &Options{}
+	for _, o := range opts {
+		o(options)
 	}
+
+	{{- if (hasAutoTime) }}
+
+	// fill automatic time fields
+	t.fillAutoTime(model)
+	{{- end }}
+	{{- if (hasAutoUpdateTime) }}
+	t.touchAutoUpdateTime(model)
 	{{- end }}
 
-	// Add UPDATE SET fields
-	if len(updateSet) > 0 {
-		suffixBuilder.WriteString(strings.Join(updateSet, ", "))
-	} else {
-		// Default update field to ensure ON CONFLICT is not empty (Postgres requires at least one field)
-		{{- $firstField := false }}
-		{{- range $index, $field := fields }}
-		{{- if and (not ($field | isRelation)) (not ($field | isAutoIncrement)) (not ($field | isDefaultUUID)) (not ($field | isPrimaryKey)) (not $firstField) }}
-		{{- $firstField = true }}
-		suffixBuilder.WriteString("{{ $field | sourceName }} = EXCLUDED.{{ $field | sourceName }}")
-		{{- end }}
-		{{- end }}
+
+	query, err := t.insertQuery(model)
+	if err != nil {
+		{{ if (hasID) }} return nil, err {{ else }} return err {{ end }}
 	}
 
+	// Build the complete suffix with ON CONFLICT, UPDATE SET, and RETURNING in one string
+	var suffixBuilder strings.Builder
+
+	// Add ON CONFLICT and UPDATE SET clauses
+	suffixBuilder.WriteString(t.upsertClause(updateFields, options))
+
 	{{ if (hasID) }}
 	// Add RETURNING clause
 	suffixBuilder.WriteString(" RETURNING \"{{ getPrimaryKey.GetName }}\"")
@@ -1507,26 +2515,29 @@ const TableUpsertMethodTemplate = `
 			rows, err = t.DB(ctx, true).QueryContext(ctx, sqlQuery, args...)
 		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to execute upsert query: %w", err)
+			return nil, fmt.Errorf("failed to execute upsert query: %w", MapError(err))
 		}
 	}
 	defer rows.Close()
 
 	if rows.Next() {
 		if scanErr := rows.Scan(&id); scanErr != nil {
-			return nil, fmt.Errorf("failed to scan returning id: %w", scanErr)
+			return nil, fmt.Errorf("failed to scan returning id: %w", MapError(scanErr))
 		}
 	} else {
+		if rowsErr := rows.Err(); rowsErr != nil {
+			return nil, fmt.Errorf("failed to execute upsert query: %w", MapError(rowsErr))
+		}
 		return nil, fmt.Errorf("no rows returned on upsert")
 	}
 
 	if rowsErr := rows.Err(); rowsErr != nil {
-		return nil, fmt.Errorf("rows iteration error: %w", rowsErr)
+		return nil, fmt.Errorf("rows iteration error: %w", MapError(rowsErr))
 	}
 	{{ else }}
 	_, err = t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...)
 	if err != nil {
-		return fmt.Errorf("failed to upsert {{ structureName }}: %w", err)
+		return fmt.Errorf("failed to upsert {{ structureName }}: %w", MapError(err))
 	}
 	{{ end }}
 
@@ -1535,13 +2546,13 @@ const TableUpsertMethodTemplate = `
 	{{- if and ($field | isRelation) ($field | relationAllowSubCreating) }}
 	    if options.relations && model.{{ $field | fieldName }} != nil { {{ if ($field | isRepeated) }}
 			for _, item := range model.{{ $field | fieldName }} {
-				item.{{ $field | getRefID }} = id
+				item.{{ $field | getRefID }} = {{ if ($field | isRefOptional) }}&{{ end }}id
 				s, err := New{{ $field | relationStorageName }}(t.config)
 				if err != nil {
 					return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err)
 				}
 
-                {{ if ($field | hasIDFromRelation) }} _, err = s.Create(ctx, item) {{ else }} err = s.Create(ctx, item) {{ end }}
+                {{ if ($field | hasIDFromRelation) }} _, err = s.Create(ctx, item, WithRelations()) {{ else }} err = s.Create(ctx, item, WithRelations()) {{ end }}
 				if err != nil {
 				{{ if (hasID) }} return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ else }} return fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ end }}
 				}
@@ -1551,8 +2562,8 @@ const TableUpsertMethodTemplate = `
 				{{ if (hasID) }} return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ else }} return fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ end }}
 			}
 
-			model.{{ $field | fieldName }}.{{ $field | getRefID }} = id
-			{{ if ($field | hasIDFromRelation) }} _, err = s.Create(ctx, model.{{ $field | fieldName }}) {{ else }} err = s.Create(ctx, model.{{ $field | fieldName }}) {{ end }}
+			model.{{ $field | fieldName }}.{{ $field | getRefID }} = {{ if ($field | isRefOptional) }}&{{ end }}id
+			{{ if ($field | hasIDFromRelation) }} _, err = s.Create(ctx, model.{{ $field | fieldName }}, WithRelations()) {{ else }} err = s.Create(ctx, model.{{ $field | fieldName }}, WithRelations()) {{ end }}
 			if err != nil {
 				{{ if (hasID) }} return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ else }} return fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ end }}
 			} {{- end}}
@@ -1562,4 +2573,1051 @@ const TableUpsertMethodTemplate = `
 
 	{{ if (hasID) }} return &id, nil {{ else }} return nil {{ end }}
 }
+
+// upsertClause builds the ON CONFLICT clause of an upsert.
+func (t *{{ storageName | lowerCamelCase }}) upsertClause(updateFields []string, options *Options) string {
+	conflictTarget := t.conflictTarget(options)
+	if conflictTarget == "" {
+		// without a primary key or unique index the conflict target must be set with WithIgnoreConflictField
+		return "ON CONFLICT DO UPDATE SET " + t.upsertSet(updateFields)
+	}
+	return "ON CONFLICT (" + conflictTarget + ") DO UPDATE SET " + t.upsertSet(updateFields)
+}
+
+// conflictTarget returns the conflict target of the upserts: the one set with WithIgnoreConflictField
+// or the default one, empty when the table has none.
+func (t *{{ storageName | lowerCamelCase }}) conflictTarget(options *Options) string {
+	conflictTarget := "{{ defaultConflictTarget }}"
+	if options.ignoreConflictField != "" {
+		conflictTarget = options.ignoreConflictField
+	}
+	return conflictTarget
+}
+
+// upsertSet builds the UPDATE SET clause of an upsert from the given fields.
+func (t *{{ storageName | lowerCamelCase }}) upsertSet(updateFields []string) string {
+	updateSet := make([]string, 0, len(updateFields))
+	for _, field := range updateFields {
+		{{- range $index, $field := fields }}
+		{{- if not ($field | isRelation) }}
+		{{- if not ($field | isAutoIncrement ) }}
+		{{- if not ($field | isDefaultUUID ) }}
+		{{- if not ($field | isPrimaryKey ) }}
+		{{- if not ($field | isAutoUpdateTime ) }}
+		if field == "{{ $field | sourceName }}" {
+			updateSet = append(updateSet, "{{ $field | sourceName }} = EXCLUDED.{{ $field | sourceName }}")
+		}
+		{{- end}}
+		{{- end}}
+		{{- end}}
+		{{- end}}
+		{{- end}}
+		{{- end}}
+	}
+
+	{{- range $index, $field := fields }}
+	{{- if ($field | isAutoUpdateTime) }}
+	// {{ $field | sourceName }} is refreshed automatically on conflict
+	updateSet = append(updateSet, "{{ $field | sourceName }} = EXCLUDED.{{ $field | sourceName }}")
+	{{- end }}
+	{{- end }}
+
+	if len(updateSet) == 0 {
+		// Default update field to ensure ON CONFLICT is not empty (Postgres requires at least one field)
+		{{- $firstField := false }}
+		{{- range $index, $field := fields }}
+		{{- if and (not ($field | isRelation)) (not ($field | isAutoIncrement)) (not ($field | isDefaultUUID)) (not ($field | isPrimaryKey)) (not $firstField) }}
+		{{- $firstField = true }}
+		updateSet = append(updateSet, "{{ $field | sourceName }} = EXCLUDED.{{ $field | sourceName }}")
+		{{- end }}
+		{{- end }}
+	}
+
+	return strings.Join(updateSet, ", ")
+}
+`
+
+const TableBatchUpsertMethodTemplate = `
+{{- range $index, $fields := getStructureUniqueIndexes }}
+// {{ structureName }}UniqueIndex{{ $fields | sliceToString | camelCase }} is the conflict target of the unique index on ({{ $fields | conflictColumns }}).
+// Pass it to WithIgnoreConflictField to upsert by this index.
+const {{ structureName }}UniqueIndex{{ $fields | sliceToString | camelCase }} = "{{ $fields | conflictColumns }}"
+{{- end }}
+
+// BatchUpsert creates multiple {{ structureName }} records or updates the existing ones on conflict.
+{{- if defaultConflictTarget }}
+// The conflict target is ({{ defaultConflictTarget }}) unless set with WithIgnoreConflictField.
+{{- else }}
+// The conflict target must be set with WithIgnoreConflictField.
+{{- end }}
+// Models sharing the values of the conflict target are upserted once, the last one wins,
+// since Postgres can't update a row twice in one statement.
+// Large batches are split into chunks below the bind parameter limit and executed in one transaction.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) BatchUpsert(ctx context.Context, models []*{{structureName}}, updateFields []string, opts ...Option) ({{ if (hasID) }}[]string, {{ end }}error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.BatchUpsert")
+	{{ if (hasID) }}ids, {{ end }}err := t.doBatchUpsert(ctx, models, updateFields, opts...)
+	endSpan(span, err)
+	return {{ if (hasID) }}ids, {{ end }}err
+}
+
+// doBatchUpsert is BatchUpsert without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doBatchUpsert{{ else }}BatchUpsert{{ end }}(ctx context.Context, models []*{{structureName}}, updateFields []string, opts ...Option) ({{ if (hasID) }}[]string, {{ end }}error) {
+	if len(models) == 0 {
+		{{ if (hasID) }} return nil, fmt.Errorf("no models to upsert") {{ else }} return fmt.Errorf("no models to upsert") {{ end }}
+	}
+
+	options := &Options{}
+	for _, o := range opts {
+		o(options)
+	}
+
+	if options.relations {
+		{{ if (hasID) }} return nil, fmt.Errorf("relations are not supported in batch upsert") {{ else }} return fmt.Errorf("relations are not supported in batch upsert") {{ end }}
+	}
+
+	conflictTarget := t.conflictTarget(options)
+	if conflictTarget == "" {
+		{{ if (hasID) }} return nil, fmt.Errorf("conflict target is required for batch upsert") {{ else }} return fmt.Errorf("conflict target is required for batch upsert") {{ end }}
+	}
+
+	suffix := "ON CONFLICT (" + conflictTarget + ") DO UPDATE SET " + t.upsertSet(updateFields)
+	{{- if (hasID) }}
+	suffix += " RETURNING \"{{ getPrimaryKey.GetName }}\""
+	{{- end }}
+
+	columns := []string{
+		{{- range $index, $field := fields }}
+		{{- if not ($field | isRelation) }}
+		{{- if not ($field | isAutoIncrement ) }}
+		{{- if not ($field | isDefaultUUID ) }}
+		"{{ $field | sourceName }}",
+		{{- end}}
+		{{- end}}
+		{{- end}}
+		{{- end}}
+	}
+
+	// Postgres can't update a row twice in one statement
+	models = t.dedupeUpsert(models, conflictTarget)
+
+	// every row binds one parameter per column
+	chunkSize := maxBindParams / len(columns)
+
+	{{ if (hasID) }}var returnIDs []string{{ end }}
+	upsert := func(ctx context.Context) error {
+		for start := 0; start < len(models); start += chunkSize {
+			end := start + chunkSize
+			if end > len(models) {
+				end = len(models)
+			}
+
+			{{ if (hasID) }}ids, err := t.batchUpsertChunk(ctx, models[start:end], columns, suffix){{ else }}err := t.batchUpsertChunk(ctx, models[start:end], columns, suffix){{ end }}
+			if err != nil {
+				return err
+			}
+			{{- if (hasID) }}
+			returnIDs = append(returnIDs, ids...)
+			{{- end }}
+		}
+		return nil
+	}
+
+	var err error
+	if len(models) <= chunkSize {
+		err = upsert(ctx)
+	} else {
+		// keep the chunks atomic
+		err = t.config.txManager().ExecFuncWithTx(ctx, upsert)
+	}
+	if err != nil {
+		{{ if (hasID) }} return nil, err {{ else }} return err {{ end }}
+	}
+
+	return {{ if (hasID) }} returnIDs, nil {{ else }} nil {{ end }}
+}
+
+// dedupeUpsert keeps the last of the models sharing the values of the conflict target columns.
+// Models with a NULL in the target never conflict and are all kept. When the target is not
+// a list of columns of the table, the models are returned as is.
+func (t *{{ storageName | lowerCamelCase }}) dedupeUpsert(models []*{{structureName}}, conflictTarget string) []*{{structureName}} {
+	columns := strings.Split(conflictTarget, ",")
+	keys := make([]string, len(models))
+	last := make(map[string]int, len(models))
+	for i, model := range models {
+		if model == nil {
+			continue
+		}
+
+		var key strings.Builder
+		for _, column := range columns {
+			value, ok := t.conflictValue(model, strings.Trim(column, "\" "))
+			if !ok {
+				return models
+			}
+			if value == nil {
+				key.Reset()
+				break
+			}
+			fmt.Fprintf(&key, "%v\x00", value)
+		}
+		if key.Len() == 0 {
+			continue
+		}
+		keys[i] = key.String()
+		last[keys[i]] = i
+	}
+	if len(last) == 0 {
+		return models
+	}
+
+	deduped := make([]*{{structureName}}, 0, len(models))
+	for i, model := range models {
+		if keys[i] == "" || last[keys[i]] == i {
+			deduped = append(deduped, model)
+		}
+	}
+	return deduped
+}
+
+// conflictValue returns the value of the column of the model, nil for NULL, and false for an unknown column.
+func (t *{{ storageName | lowerCamelCase }}) conflictValue(model *{{structureName}}, column string) (interface{}, bool) {
+	switch column {
+	{{- range $index, $field := fields }}
+	{{- if not ($field | isRelation) }}
+	case "{{ $field | sourceName }}":
+		{{- if (findPointer $field) }}
+		if model.{{ $field | fieldName }} == nil {
+			return nil, true
+		}
+		return *model.{{ $field | fieldName }}, true
+		{{- else }}
+		return model.{{ $field | fieldName }}, true
+		{{- end }}
+	{{- end }}
+	{{- end }}
+	}
+	return nil, false
+}
+
+// batchUpsertChunk upserts a single chunk of {{ structureName }} records.
+func (t *{{ storageName | lowerCamelCase }}) batchUpsertChunk(ctx context.Context, models []*{{structureName}}, columns []string, suffix string) ({{ if (hasID) }}[]string, {{ end }}error) {
+	query := t.queryBuilder.Insert(t.TableName()).Columns(columns...)
+
+	for _, model := range models {
+		if model == nil {
+			{{ if (hasID) }} return nil, fmt.Errorf("one of the models is nil") {{ else }} return fmt.Errorf("one of the models is nil") {{ end }}
+		}
+
+		{{- if (hasAutoTime) }}
+
+		// fill automatic time fields
+		t.fillAutoTime(model)
+		{{- end }}
+		{{- if (hasAutoUpdateTime) }}
+		t.touchAutoUpdateTime(model)
+		{{- end }}
+
+		{{- range $index, $field := fields }}
+		{{- if not ($field | isRelation) }}
+		{{- if ($field | isRepeated) }}
+		// get value of {{ $field | fieldName | lowerCamelCase }}
+		{{ $field | fieldName | lowerCamelCase }}, err := model.{{ $field | fieldName }}.Value()
+		if err != nil {
+			{{ if (hasID) }} return nil, fmt.Errorf("failed to get value of {{ $field | fieldName }}: %w", err) {{ else }} return fmt.Errorf("failed to get value of {{ $field | fieldName }}: %w", err) {{ end }}
+		}
+		{{- end}}
+		{{- end}}
+		{{- end}}
+
+		query = query.Values(
+			{{- range $index, $field := fields }}
+			{{- if not ($field | isRelation) }}
+			{{- if not ($field | isAutoIncrement ) }}
+			{{- if not ($field | isDefaultUUID ) }}
+
+			{{- if ($field | isRepeated) }}
+				{{ $field | fieldName | lowerCamelCase }},
+			{{- else }}
+
+				{{- if (findPointer $field) }}
+				nullValue(model.{{ $field | fieldName }}),
+				{{- else }}
+				model.{{ $field | fieldName }},
+				{{- end }}
+
+			{{- end}}
+
+			{{- end}}
+			{{- end}}
+			{{- end}}
+			{{- end}}
+		)
+	}
+	query = query.Suffix(suffix)
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		{{ if (hasID) }} return nil, fmt.Errorf("failed to build query: %w", err) {{ else }} return fmt.Errorf("failed to build query: %w", err) {{ end }}
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	{{ if (hasID) }}
+	rows, err := t.DB(ctx, true).QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute batch upsert: %w", MapError(err))
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			t.logError(ctx, err, "failed to close rows")
+		}
+	}()
+
+	var returnIDs []string
+	for rows.Next() {
+		var {{ getPrimaryKey.GetName | lowerCamelCase }} string
+		if err := rows.Scan(&{{ getPrimaryKey.GetName | lowerCamelCase }}); err != nil {
+			return nil, fmt.Errorf("failed to scan {{ getPrimaryKey.GetName }}: %w", MapError(err))
+		}
+		returnIDs = append(returnIDs, {{ getPrimaryKey.GetName | lowerCamelCase }})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", MapError(err))
+	}
+
+	return returnIDs, nil
+	{{- else }}
+	if _, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...); err != nil {
+		return fmt.Errorf("failed to execute batch upsert: %w", MapError(err))
+	}
+
+	return nil
+	{{- end }}
+}
+`
+
+const TableCopyFromMethodTemplate = `
+// CopyFrom bulk loads the {{ structureName }} records with the COPY protocol and returns the number of copied rows.
+// With lib/pq it runs in the transaction from the context or in a new one; with pgx it runs on a connection
+// of its own and returns ErrCopyInTransaction inside a transaction.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) CopyFrom(ctx context.Context, models []*{{structureName}}) (int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.CopyFrom")
+	res, err := t.doCopyFrom(ctx, models)
+	endSpan(span, err)
+	return res, err
+}
+
+// doCopyFrom is CopyFrom without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doCopyFrom{{ else }}CopyFrom{{ end }}(ctx context.Context, models []*{{structureName}}) (int64, error) {
+	if len(models) == 0 {
+		return 0, nil
+	}
+
+	i := 0
+	return t.copyIn(ctx, func() (*{{structureName}}, bool, error) {
+		if i == len(models) {
+			return nil, false, nil
+		}
+		i++
+		return models[i-1], true, nil
+	})
+}
+
+// CopyFromChan bulk loads the {{ structureName }} records received from the channel until it is closed.
+// With lib/pq it runs in the transaction from the context or in a new one; with pgx it runs on a connection
+// of its own and returns ErrCopyInTransaction inside a transaction.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) CopyFromChan(ctx context.Context, models <-chan *{{structureName}}) (int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.CopyFromChan")
+	res, err := t.doCopyFromChan(ctx, models)
+	endSpan(span, err)
+	return res, err
+}
+
+// doCopyFromChan is CopyFromChan without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doCopyFromChan{{ else }}CopyFromChan{{ end }}(ctx context.Context, models <-chan *{{structureName}}) (int64, error) {
+	return t.copyIn(ctx, func() (*{{structureName}}, bool, error) {
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case model, ok := <-models:
+			return model, ok, nil
+		}
+	})
+}
+
+// copyIn streams the {{ structureName }} records returned by next with the COPY protocol.
+// The COPY passes through the interceptors as a single query, whose SQL and Args they can't change.
+func (t *{{ storageName | lowerCamelCase }}) copyIn(ctx context.Context, next func() (*{{structureName}}, bool, error)) (int64, error) {
+	columns := []string{
+	{{- range $index, $field := fields }}
+	{{- if not ($field | isRelation) }}
+	{{- if not ($field | isAutoIncrement ) }}
+	{{- if not ($field | isDefaultUUID ) }}
+	"{{ $field | sourceName }}",
+	{{- end}}
+	{{- end}}
+	{{- end}}
+	{{- end}}
+	}
+
+	return t.config.copyIn(ctx, t.TableName(), columns, func() ([]interface{}, bool, error) {
+		model, ok, err := next()
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		if model == nil {
+			return nil, false, fmt.Errorf("one of the models is nil")
+		}
+
+		{{- if (hasAutoTime) }}
+
+		// fill automatic time fields
+		t.fillAutoTime(model)
+		{{- end }}
+
+		values := []interface{}{
+			{{- range $index, $field := fields }}
+			{{- if not ($field | isRelation) }}
+			{{- if not ($field | isAutoIncrement ) }}
+			{{- if not ($field | isDefaultUUID ) }}
+			{{- if (findPointer $field) }}
+			nullValue(model.{{ $field | fieldName }}),
+			{{- else }}
+			model.{{ $field | fieldName }},
+			{{- end }}
+			{{- end}}
+			{{- end}}
+			{{- end}}
+			{{- end}}
+		}
+		for i, v := range values {
+			if values[i], err = copyValue(v); err != nil {
+				return nil, false, fmt.Errorf("failed to get copy value: %w", err)
+			}
+		}
+		return values, true, nil
+	})
+}
+`
+
+const TableReturningMethodTemplate = `
+// CreateReturning creates a new {{ structureName }} and scans the inserted row back into the model,
+// including values set by the database such as defaults and triggers.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) CreateReturning(ctx context.Context, model *{{structureName}}, opts ...Option) (*{{structureName}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.CreateReturning")
+	res, err := t.doCreateReturning(ctx, model, opts...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doCreateReturning is CreateReturning without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doCreateReturning{{ else }}CreateReturning{{ end }}(ctx context.Context, model *{{structureName}}, opts ...Option) (*{{structureName}}, error) {
+	if model == nil {
+		return nil, fmt.Errorf("model is nil")
+	}
+
+	// set default options
+	options := &Options{}
+	for _, o := range opts {
+		o(options)
+	}
+
+	if options.relations {
+		return nil, fmt.Errorf("relations are not supported in create returning")
+	}
+
+	{{- if (hasAutoTime) }}
+
+	// fill automatic time fields
+	t.fillAutoTime(model)
+	{{- end }}
+
+	query, err := t.insertQuery(model)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.ignoreConflictField != "" {
+		query = query.Suffix("ON CONFLICT (" + options.ignoreConflictField + ") DO NOTHING")
+	}
+	query = query.Suffix("RETURNING " + strings.Join(t.Columns(), ", "))
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	if err := model.ScanRow(t.DB(ctx, true).QueryRowContext(ctx, sqlQuery, args...)); err != nil {
+		// ON CONFLICT DO NOTHING returns no row
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRowAlreadyExist
+		}
+		return nil, fmt.Errorf("failed to create {{ structureName }}: %w", MapError(err))
+	}
+
+	return model, nil
+}
+
+// UpsertReturning creates a new {{ structureName }} or updates the existing one on conflict
+// and scans the resulting row back into the model.
+{{- if defaultConflictTarget }}
+// The conflict target is ({{ defaultConflictTarget }}) unless set with WithIgnoreConflictField.
+{{- else }}
+// The conflict target must be set with WithIgnoreConflictField.
+{{- end }}
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) UpsertReturning(ctx context.Context, model *{{structureName}}, updateFields []string, opts ...Option) (*{{structureName}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.UpsertReturning")
+	res, err := t.doUpsertReturning(ctx, model, updateFields, opts...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doUpsertReturning is UpsertReturning without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doUpsertReturning{{ else }}UpsertReturning{{ end }}(ctx context.Context, model *{{structureName}}, updateFields []string, opts ...Option) (*{{structureName}}, error) {
+	if model == nil {
+		return nil, fmt.Errorf("model is nil")
+	}
+
+	// set default options
+	options := &Options{}
+	for _, o := range opts {
+		o(options)
+	}
+
+	{{- if (hasAutoTime) }}
+
+	// fill automatic time fields
+	t.fillAutoTime(model)
+	{{- end }}
+	{{- if (hasAutoUpdateTime) }}
+	t.touchAutoUpdateTime(model)
+	{{- end }}
+
+	query, err := t.insertQuery(model)
+	if err != nil {
+		return nil, err
+	}
+
+	query = query.Suffix(t.upsertClause(updateFields, options) + " RETURNING " + strings.Join(t.Columns(), ", "))
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	if err := model.ScanRow(t.DB(ctx, true).QueryRowContext(ctx, sqlQuery, args...)); err != nil {
+		return nil, fmt.Errorf("failed to upsert {{ structureName }}: %w", MapError(err))
+	}
+
+	return model, nil
+}
+
+// UpdateReturning updates an existing {{ structureName }} based on non-nil fields and returns the updated row.
+// It returns ErrRowNotFound if no row matches the id.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) UpdateReturning(ctx context.Context, id {{ keyType }}, updateData *{{structureName}}Update) (*{{structureName}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.UpdateReturning")
+	res, err := t.doUpdateReturning(ctx, id, updateData)
+	endSpan(span, err)
+	return res, err
+}
+
+// doUpdateReturning is UpdateReturning without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doUpdateReturning{{ else }}UpdateReturning{{ end }}(ctx context.Context, id {{ keyType }}, updateData *{{structureName}}Update) (*{{structureName}}, error) {
+	if updateData == nil {
+		return nil, fmt.Errorf("update data is nil")
+	}
+
+	query, err := t.buildUpdateQuery(updateData)
+	if err != nil {
+		return nil, err
+	}
+
+	query = query.Where({{ if (hasCompositePrimaryKey) }}id.eq(){{ else }}"{{ getPrimaryKey.GetName }} = ?", id{{ end }}).
+		Suffix("RETURNING " + strings.Join(t.Columns(), ", "))
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	model := &{{structureName}}{}
+	if err := model.ScanRow(t.DB(ctx, true).QueryRowContext(ctx, sqlQuery, args...)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRowNotFound
+		}
+		return nil, fmt.Errorf("failed to update {{ structureName }}: %w", MapError(err))
+	}
+
+	return model, nil
+}
+`
+
+const TableManyToManyMethodTemplate = `
+{{- range $index, $field := fields }}
+{{- if ($field | isManyToMany) }}
+{{- $rel := ($field | relation) }}
+// Attach{{ $field | pluralFieldName }} links the related {{ $field | relationStructureName }} rows to the model
+// through the "{{ $rel.Through }}" table. Existing links are kept.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Attach{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Attach{{ $field | pluralFieldName }}")
+	err := t.doAttach{{ $field | pluralFieldName }}(ctx, model, related...)
+	endSpan(span, err)
+	return err
+}
+
+// doAttach{{ $field | pluralFieldName }} is Attach{{ $field | pluralFieldName }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doAttach{{ $field | pluralFieldName }}{{ else }}Attach{{ $field | pluralFieldName }}{{ end }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error {
+	if model == nil {
+		return fmt.Errorf("{{structureName}} is nil")
+	}
+	if len(related) == 0 {
+		return nil
+	}
+
+	query := t.queryBuilder.Insert("{{ $rel.Through }}").Columns("{{ $rel.ThroughField }}", "{{ $rel.ThroughReference }}")
+	for _, item := range related {
+		if item == nil {
+			return fmt.Errorf("{{ $field | relationStructureName }} is nil")
+		}
+		query = query.Values(model.{{ $field | getFieldID }}, item.{{ $field | getRefID }})
+	}
+	query = query.Suffix("ON CONFLICT DO NOTHING")
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	if _, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...); err != nil {
+		return fmt.Errorf("failed to attach {{ $field | pluralFieldName }}: %w", MapError(err))
+	}
+
+	return nil
+}
+
+// Detach{{ $field | pluralFieldName }} removes the links between the model and the related {{ $field | relationStructureName }} rows.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Detach{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Detach{{ $field | pluralFieldName }}")
+	err := t.doDetach{{ $field | pluralFieldName }}(ctx, model, related...)
+	endSpan(span, err)
+	return err
+}
+
+// doDetach{{ $field | pluralFieldName }} is Detach{{ $field | pluralFieldName }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doDetach{{ $field | pluralFieldName }}{{ else }}Detach{{ $field | pluralFieldName }}{{ end }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error {
+	if model == nil {
+		return fmt.Errorf("{{structureName}} is nil")
+	}
+	if len(related) == 0 {
+		return nil
+	}
+
+	refs := make([]interface{}, 0, len(related))
+	for _, item := range related {
+		if item == nil {
+			return fmt.Errorf("{{ $field | relationStructureName }} is nil")
+		}
+		refs = append(refs, item.{{ $field | getRefID }})
+	}
+
+	sqlQuery, args, err := t.queryBuilder.Delete("{{ $rel.Through }}").
+		Where(sq.Eq{"{{ $rel.ThroughField }}": model.{{ $field | getFieldID }}, "{{ $rel.ThroughReference }}": refs}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	if _, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...); err != nil {
+		return fmt.Errorf("failed to detach {{ $field | pluralFieldName }}: %w", MapError(err))
+	}
+
+	return nil
+}
+
+// Sync{{ $field | pluralFieldName }} makes the related {{ $field | relationStructureName }} rows the only ones linked to the model.
+// Links to other rows are removed. It runs in the transaction from the context or in a new one.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Sync{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Sync{{ $field | pluralFieldName }}")
+	err := t.doSync{{ $field | pluralFieldName }}(ctx, model, related...)
+	endSpan(span, err)
+	return err
+}
+
+// doSync{{ $field | pluralFieldName }} is Sync{{ $field | pluralFieldName }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doSync{{ $field | pluralFieldName }}{{ else }}Sync{{ $field | pluralFieldName }}{{ end }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error {
+	if model == nil {
+		return fmt.Errorf("{{structureName}} is nil")
+	}
+
+	refs := make([]interface{}, 0, len(related))
+	for _, item := range related {
+		if item == nil {
+			return fmt.Errorf("{{ $field | relationStructureName }} is nil")
+		}
+		refs = append(refs, item.{{ $field | getRefID }})
+	}
+
+	query := t.queryBuilder.Delete("{{ $rel.Through }}").Where(sq.Eq{"{{ $rel.ThroughField }}": model.{{ $field | getFieldID }}})
+	if len(refs) > 0 {
+		query = query.Where(sq.NotEq{"{{ $rel.ThroughReference }}": refs})
+	}
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	return t.config.txManager().ExecFuncWithTx(ctx, func(ctx context.Context) error {
+		t.logQuery(ctx, sqlQuery, args...)
+		if _, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...); err != nil {
+			return fmt.Errorf("failed to sync {{ $field | pluralFieldName }}: %w", MapError(err))
+		}
+
+		return t.Attach{{ $field | pluralFieldName }}(ctx, model, related...)
+	})
+}
+{{- end }}
+{{- end }}
+`
+
+const TableTreeMethodTemplate = `
+// {{ structureName }}Node is a {{ structureName }} found by a recursive tree query.
+// Depth is the distance to the row the query started from.
+type {{ structureName }}Node struct {
+	*{{ structureName }}
+	Depth    int
+	Children []*{{ structureName }}Node
+}
+
+// Ancestors returns the parents of the {{ structureName }} up to the root, the nearest one first.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Ancestors(ctx context.Context, {{ treeKey.GetName | lowerCamelCase }} {{ treeKey | fieldType }}) ([]*{{ structureName }}Node, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Ancestors")
+	res, err := t.doAncestors(ctx, {{ treeKey.GetName | lowerCamelCase }})
+	endSpan(span, err)
+	return res, err
+}
+
+// doAncestors is Ancestors without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doAncestors{{ else }}Ancestors{{ end }}(ctx context.Context, {{ treeKey.GetName | lowerCamelCase }} {{ treeKey | fieldType }}) ([]*{{ structureName }}Node, error) {
+	nodes, err := t.tree(ctx, {{ treeKey.GetName | lowerCamelCase }}, true, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, ErrRowNotFound
+	}
+
+	return nodes[1:], nil
+}
+
+// Descendants returns the children of the {{ structureName }} ordered by depth.
+// A maxDepth of zero or less returns the whole subtree.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Descendants(ctx context.Context, {{ treeKey.GetName | lowerCamelCase }} {{ treeKey | fieldType }}, maxDepth int) ([]*{{ structureName }}Node, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Descendants")
+	res, err := t.doDescendants(ctx, {{ treeKey.GetName | lowerCamelCase }}, maxDepth)
+	endSpan(span, err)
+	return res, err
+}
+
+// doDescendants is Descendants without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doDescendants{{ else }}Descendants{{ end }}(ctx context.Context, {{ treeKey.GetName | lowerCamelCase }} {{ treeKey | fieldType }}, maxDepth int) ([]*{{ structureName }}Node, error) {
+	nodes, err := t.tree(ctx, {{ treeKey.GetName | lowerCamelCase }}, false, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, ErrRowNotFound
+	}
+
+	return nodes[1:], nil
+}
+
+// Tree returns the {{ structureName }} with all its descendants nested in Children.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Tree(ctx context.Context, rootID {{ treeKey | fieldType }}) (*{{ structureName }}Node, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Tree")
+	res, err := t.doTree(ctx, rootID)
+	endSpan(span, err)
+	return res, err
+}
+
+// doTree is Tree without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doTree{{ else }}Tree{{ end }}(ctx context.Context, rootID {{ treeKey | fieldType }}) (*{{ structureName }}Node, error) {
+	nodes, err := t.tree(ctx, rootID, false, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, ErrRowNotFound
+	}
+
+	byKey := make(map[{{ treeKey | fieldType }}]*{{ structureName }}Node, len(nodes))
+	for _, node := range nodes {
+		byKey[node.{{ treeKey | fieldName }}] = node
+	}
+	for _, node := range nodes[1:] {
+		{{- if (findPointer treeParentKey) }}
+		if node.{{ treeParentKey | fieldName }} == nil {
+			continue
+		}
+		if parent, ok := byKey[*node.{{ treeParentKey | fieldName }}]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+		{{- else }}
+		if parent, ok := byKey[node.{{ treeParentKey | fieldName }}]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+		{{- end }}
+	}
+
+	return nodes[0], nil
+}
+
+// tree walks the "{{ tableName }}" table with a recursive query starting from the given row.
+// The starting row is returned first with a depth of zero.
+func (t *{{ storageName | lowerCamelCase }}) tree(ctx context.Context, {{ treeKey.GetName | lowerCamelCase }} {{ treeKey | fieldType }}, ancestors bool, maxDepth int) ([]*{{ structureName }}Node, error) {
+	columns := make([]string, 0, len(t.Columns()))
+	for _, column := range t.Columns() {
+		columns = append(columns, t.TableName()+"."+column)
+	}
+
+	join := "{{ tableName }}.{{ treeParentKey.GetName }} = tree.{{ treeKey.GetName }}"
+	if ancestors {
+		join = "{{ tableName }}.{{ treeKey.GetName }} = tree.{{ treeParentKey.GetName }}"
+	}
+
+	args := []interface{}{ {{- treeKey.GetName | lowerCamelCase -}} }
+	recursive := "SELECT " + strings.Join(columns, ", ") + ", tree.depth + 1 FROM {{ tableName }} JOIN tree ON " + join
+	if maxDepth > 0 {
+		recursive += " WHERE tree.depth < ?"
+		args = append(args, maxDepth)
+	}
+
+	query := t.queryBuilder.Select(append(t.Columns(), "depth")...).
+		Prefix("WITH RECURSIVE tree AS (SELECT "+strings.Join(columns, ", ")+", 0 AS depth FROM {{ tableName }} WHERE {{ treeKey.GetName }} = ? UNION ALL "+recursive+")", args...).
+		From("tree").
+		OrderBy("depth", "{{ treeKey.GetName }}")
+
+	// execute query
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	rows, err := t.DB(ctx, false).QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", MapError(err))
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			t.logError(ctx, err, "failed to close rows")
+		}
+	}()
+
+	var nodes []*{{ structureName }}Node
+	for rows.Next() {
+		node := &{{ structureName }}Node{ {{- structureName }}: &{{ structureName }}{}}
+		if err := rows.Scan(
+			{{- range $field := fields }}
+			{{- if not ($field | isRelation) }}
+			&node.{{ $field | fieldName }},
+			{{- end }}
+			{{- end }}
+			&node.Depth,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan {{ structureName }}: %w", MapError(err))
+		}
+		nodes = append(nodes, node)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over rows: %w", MapError(err))
+	}
+
+	return nodes, nil
+}
+`
+
+const TableUniqueMethodTemplate = `
+{{- range $key := uniqueKeys }}
+
+// FindBy{{ $key.Name }} retrieves a {{ structureName }} by its unique {{ range $i, $f := $key.Fields }}{{ if $i }} and {{ end }}{{ $f.GetName }}{{ end }}.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) FindBy{{ $key.Name }}(ctx context.Context, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}, {{ end }}opts ...Option) (*{{ structureName }}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.FindBy{{ $key.Name }}")
+	res, err := t.doFindBy{{ $key.Name }}(ctx, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }}, {{ end }}opts...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doFindBy{{ $key.Name }} is FindBy{{ $key.Name }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doFindBy{{ $key.Name }}{{ else }}FindBy{{ $key.Name }}{{ end }}(ctx context.Context, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}, {{ end }}opts ...Option) (*{{ structureName }}, error) {
+	builder := NewQueryBuilder()
+	{
+		builder.WithFilter({{ range $i, $f := $key.Fields }}{{ if $i }}, {{ end }}Eq("{{ $f.GetName }}", {{ $f.GetName | lowerCamelCase }}){{ end }})
+		builder.WithOptions(opts...)
+	}
+
+	// Use FindOne to get a single result
+	model, err := t.FindOne(ctx, builder)
+	if err != nil {
+		return nil, fmt.Errorf("find one {{ structureName }}: %w", err)
+	}
+
+	return model, nil
+}
+
+// ExistsBy{{ $key.Name }} reports whether a {{ structureName }} with the given {{ range $i, $f := $key.Fields }}{{ if $i }} and {{ end }}{{ $f.GetName }}{{ end }} exists.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) ExistsBy{{ $key.Name }}(ctx context.Context{{ range $key.Fields }}, {{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}{{ end }}) (bool, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.ExistsBy{{ $key.Name }}")
+	res, err := t.doExistsBy{{ $key.Name }}(ctx{{ range $key.Fields }}, {{ .GetName | lowerCamelCase }}{{ end }})
+	endSpan(span, err)
+	return res, err
+}
+
+// doExistsBy{{ $key.Name }} is ExistsBy{{ $key.Name }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doExistsBy{{ $key.Name }}{{ else }}ExistsBy{{ $key.Name }}{{ end }}(ctx context.Context{{ range $key.Fields }}, {{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}{{ end }}) (bool, error) {
+	count, err := t.Count(ctx, FilterBuilder({{ range $i, $f := $key.Fields }}{{ if $i }}, {{ end }}Eq("{{ $f.GetName }}", {{ $f.GetName | lowerCamelCase }}){{ end }}))
+	if err != nil {
+		return false, fmt.Errorf("count {{ structureName }}: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// DeleteBy{{ $key.Name }} deletes the {{ structureName }} with the given {{ range $i, $f := $key.Fields }}{{ if $i }} and {{ end }}{{ $f.GetName }}{{ end }} and returns the number of deleted rows.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) DeleteBy{{ $key.Name }}(ctx context.Context, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}, {{ end }}opts ...Option) (int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.DeleteBy{{ $key.Name }}")
+	res, err := t.doDeleteBy{{ $key.Name }}(ctx, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }}, {{ end }}opts...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doDeleteBy{{ $key.Name }} is DeleteBy{{ $key.Name }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doDeleteBy{{ $key.Name }}{{ else }}DeleteBy{{ $key.Name }}{{ end }}(ctx context.Context, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}, {{ end }}opts ...Option) (int64, error) {
+	{{- if (hasCascadeDelete) }}
+	// find and delete the row in one transaction
+	if _, ok := TxFromContext(ctx); !ok {
+		var deleted int64
+		err := t.config.txManager().ExecFuncWithTx(ctx, func(ctx context.Context) error {
+			var err error
+			deleted, err = t.DeleteBy{{ $key.Name }}(ctx, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }}, {{ end }}opts...)
+			return err
+		})
+		return deleted, err
+	}
+
+	// delete through the primary key, so the cascading relations are deleted as well.
+	// The row is locked, so a concurrent change of the key can't make it delete another row.
+	model, err := t.SelectForUpdate(ctx, FilterBuilder({{ range $i, $f := $key.Fields }}{{ if $i }}, {{ end }}Eq("{{ $f.GetName }}", {{ $f.GetName | lowerCamelCase }}){{ end }}))
+	if err != nil {
+		if errors.Is(err, ErrRowNotFound) {
+			options := &Options{}
+			for _, o := range opts {
+				o(options)
+			}
+			if options.mustAffect {
+				return 0, ErrNotFound
+			}
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return t.DeleteBy{{ primaryKeyName | camelCase }}(ctx, model.{{ getPrimaryKey | fieldName }}, opts...)
+	{{- else }}
+	builder := NewQueryBuilder()
+	{
+		builder.WithFilter({{ range $i, $f := $key.Fields }}{{ if $i }}, {{ end }}Eq("{{ $f.GetName }}", {{ $f.GetName | lowerCamelCase }}){{ end }})
+		builder.WithOptions(opts...)
+	}
+
+	return t.DeleteMany(ctx, builder)
+	{{- end }}
+}
+{{- if eq (len $key.Fields) 1 }}
+{{- $field := index $key.Fields 0 }}
+
+// FindManyBy{{ $field.GetName | plural | camelCase }} retrieves the {{ structureName }} rows with the given {{ $field.GetName | plural }}, keyed by {{ $field.GetName }}.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) FindManyBy{{ $field.GetName | plural | camelCase }}(ctx context.Context, {{ $field.GetName | plural | lowerCamelCase }} []{{ if (findPointer $field) }}{{ $field | fieldTypeWP }}{{ else }}{{ $field | fieldType }}{{ end }}, opts ...Option) (map[{{ if (findPointer $field) }}{{ $field | fieldTypeWP }}{{ else }}{{ $field | fieldType }}{{ end }}]*{{ structureName }}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.FindManyBy{{ $field.GetName | plural | camelCase }}")
+	res, err := t.doFindManyBy{{ $field.GetName | plural | camelCase }}(ctx, {{ $field.GetName | plural | lowerCamelCase }}, opts...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doFindManyBy{{ $field.GetName | plural | camelCase }} is FindManyBy{{ $field.GetName | plural | camelCase }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doFindManyBy{{ $field.GetName | plural | camelCase }}{{ else }}FindManyBy{{ $field.GetName | plural | camelCase }}{{ end }}(ctx context.Context, {{ $field.GetName | plural | lowerCamelCase }} []{{ if (findPointer $field) }}{{ $field | fieldTypeWP }}{{ else }}{{ $field | fieldType }}{{ end }}, opts ...Option) (map[{{ if (findPointer $field) }}{{ $field | fieldTypeWP }}{{ else }}{{ $field | fieldType }}{{ end }}]*{{ structureName }}, error) {
+	result := make(map[{{ if (findPointer $field) }}{{ $field | fieldTypeWP }}{{ else }}{{ $field | fieldType }}{{ end }}]*{{ structureName }}, len({{ $field.GetName | plural | lowerCamelCase }}))
+	if len({{ $field.GetName | plural | lowerCamelCase }}) == 0 {
+		return result, nil
+	}
+
+	values := make([]interface{}, 0, len({{ $field.GetName | plural | lowerCamelCase }}))
+	for _, v := range {{ $field.GetName | plural | lowerCamelCase }} {
+		values = append(values, v)
+	}
+
+	builder := NewQueryBuilder()
+	{
+		builder.WithFilter(In("{{ $field.GetName }}", values...))
+		builder.WithOptions(opts...)
+	}
+
+	items, err := t.FindMany(ctx, builder)
+	if err != nil {
+		return nil, fmt.Errorf("find many {{ structureName }}: %w", err)
+	}
+
+	for _, item := range items {
+		{{- if (findPointer $field) }}
+		if item.{{ $field | fieldName }} == nil {
+			continue
+		}
+		result[*item.{{ $field | fieldName }}] = item
+		{{- else }}
+		result[item.{{ $field | fieldName }}] = item
+		{{- end }}
+	}
+
+	return result, nil
+}
+{{- end }}
+{{- end }}
 `
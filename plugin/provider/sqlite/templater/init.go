@@ -22,6 +22,8 @@ type initTemplater struct {
 
 	// is include connection
 	IncludeConnection bool
+
+	Otel bool
 }
 
 // NewInitTemplater returns a new initTemplater.
@@ -30,6 +32,7 @@ func NewInitTemplater(state *statepkg.State) statepkg.Templater {
 		state: state,
 
 		IncludeConnection: state.IncludeConnection,
+		Otel:              state.Otel,
 	}
 }
 
@@ -67,6 +70,22 @@ func (i *initTemplater) BuildTemplate() string {
 			Name: "transaction",
 			Body: tmplpkg.TransactionManagerTemplate,
 		},
+		helperpkg.IncludeTemplate{
+			Name: "replicas",
+			Body: tmplpkg.ReplicasTemplate,
+		},
+		helperpkg.IncludeTemplate{
+			Name: "interceptors",
+			Body: tmplpkg.InterceptorsTemplate,
+		},
+		helperpkg.IncludeTemplate{
+			Name: "slowquery",
+			Body: tmplpkg.SlowQueryTemplate,
+		},
+		helperpkg.IncludeTemplate{
+			Name: "tracing",
+			Body: tmplpkg.TracingTemplate,
+		},
 		helperpkg.IncludeTemplate{
 			Name: "options",
 			Body: tmplpkg.OptionsTemplate,
@@ -91,13 +110,56 @@ func (i *initTemplater) Imports() *importpkg.ImportSet {
 		importpkg.ImportContext,
 		importpkg.ImportFMT,
 		importpkg.ImportSquirrel,
-		importpkg.ImportLibSqlite3,
+		importpkg.ImportLibSqlite3WOAlias,
 	)
 	tmp := i.BuildTemplate()
+	if strings.Contains(tmp, "time.Time") {
+		is.Add(importpkg.ImportTime)
+	}
+	if strings.Contains(tmp, "strconv.") {
+		is.Add(importpkg.ImportStrconv)
+	}
+	if strings.Contains(tmp, "sql.") {
+		is.Add(importpkg.ImportDb)
+	}
+	if strings.Contains(tmp, "driver.") {
+		is.Add(importpkg.ImportSQLDriver)
+	}
+	if strings.Contains(tmp, "math.") {
+		is.Add(importpkg.ImportMath)
+	}
+	if strings.Contains(tmp, "json.") {
+		is.Add(importpkg.ImportJson)
+	}
+	if strings.Contains(tmp, "errors.") {
+		is.Add(importpkg.ImportStdErrors)
+	}
+	if strings.Contains(tmp, "strings.") {
+		is.Add(importpkg.ImportStrings)
+	}
+	if strings.Contains(tmp, "atomic.") {
+		is.Add(importpkg.ImportSyncAtomic)
+	}
+	if strings.Contains(tmp, "net.Error") {
+		is.Add(importpkg.ImportNet)
+	}
+	if strings.Contains(tmp, "rand.") {
+		is.Add(importpkg.ImportMathRand)
+	}
 	if strings.Contains(tmp, "structpb.") {
 		is.Add(importpkg.ImportStructPB)
 	}
 
+	if i.Otel {
+		is.Add(
+			importpkg.ImportRegexp,
+			importpkg.ImportOtel,
+			importpkg.ImportOtelAttribute,
+			importpkg.ImportOtelCodes,
+			importpkg.ImportOtelTrace,
+		)
+	}
+
 	return is
 }
 
@@ -380,6 +380,51 @@ func NotIn(field string, values ...interface{}) FilterApplier {
 	return NotInCondition{Field: field, Values: values}
 }
 
+// ExistsCondition represents a correlated EXISTS subquery on a related table.
+type ExistsCondition struct {
+	Table   string
+	// Alias names the table in the subquery, so that a self-referencing relation
+	// doesn't compare the table with itself.
+	Alias   string
+	On      string
+	Not     bool
+	Filters []FilterApplier
+}
+
+// ToSql builds the subquery, its placeholders are numbered by the outer query.
+func (c ExistsCondition) ToSql() (string, []interface{}, error) {
+	from := c.Table
+	if c.Alias != "" {
+		from += " AS " + c.Alias
+	}
+
+	sub := sq.Select("1").From(from).Where(c.On)
+	for _, filter := range c.Filters {
+		if filter != nil {
+			sub = filter.Apply(sub)
+		}
+	}
+
+	subQuery, args, err := sub.ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+	if c.Not {
+		return "NOT EXISTS (" + subQuery + ")", args, nil
+	}
+	return "EXISTS (" + subQuery + ")", args, nil
+}
+
+// Apply applies the condition to the query.
+func (c ExistsCondition) Apply(query sq.SelectBuilder) sq.SelectBuilder {
+	return query.Where(c)
+}
+
+// ApplyDelete applies the condition to the query.
+func (c ExistsCondition) ApplyDelete(query sq.DeleteBuilder) sq.DeleteBuilder {
+	return query.Where(c)
+}
+
 // OrderCondition represents the ORDER BY condition.
 type OrderCondition struct {
 	Column string
@@ -0,0 +1,190 @@
+package tmpl
+
+// InterceptorsTemplate is the template for the query interceptors.
+// This is included in the init template.
+const InterceptorsTemplate = `
+// Operation is the kind of a query.
+type Operation string
+
+const (
+	OperationCreate Operation = "create"
+	OperationFind   Operation = "find"
+	OperationUpdate Operation = "update"
+	OperationDelete Operation = "delete"
+	OperationRaw    Operation = "raw"
+)
+
+// Query is a query passing through the interceptors.
+// Interceptors may change SQL and Args before calling next.
+type Query struct {
+	Table     string
+	Operation Operation
+	SQL       string
+	Args      []interface{}
+}
+
+// QueryResult is the result of a query. Only the field of the executing method is set:
+// Rows for QueryContext, Row for QueryRowContext and Result for ExecContext.
+type QueryResult struct {
+	Rows   *sql.Rows
+	Row    *sql.Row
+	Result sql.Result
+}
+
+// QueryHandler executes a query.
+type QueryHandler func(ctx context.Context, query *Query) (*QueryResult, error)
+
+// QueryInterceptor wraps the execution of every query; it calls next to execute the query.
+// For QueryRowContext the error is the error of the row. When an interceptor fails QueryRowContext
+// without calling next, the row fails with the error of the interceptor.
+type QueryInterceptor func(ctx context.Context, query *Query, next QueryHandler) (*QueryResult, error)
+
+// operationKey is the key used to store the operation of the queries in the context.
+type operationKey struct{}
+
+// withOperation returns a context whose queries have the given operation.
+func withOperation(ctx context.Context, operation Operation) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// queryOperation returns the operation stored in the context, or the one of the statement.
+func queryOperation(ctx context.Context, query string) Operation {
+	if operation, ok := ctx.Value(operationKey{}).(Operation); ok {
+		return operation
+	}
+
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return OperationRaw
+	}
+
+	switch strings.ToUpper(fields[0]) {
+	case "INSERT":
+		return OperationCreate
+	case "SELECT":
+		return OperationFind
+	case "UPDATE":
+		return OperationUpdate
+	case "DELETE":
+		return OperationDelete
+	}
+	return OperationRaw
+}
+
+// tracingEnabled is true when the queries are traced with OpenTelemetry.
+const tracingEnabled = {{ if .Otel }}true{{ else }}false{{ end }}
+
+// intercepted returns true if the queries have to run through the wrapper,
+// for the tracing, the interceptors or the slow query detection.
+func (c *Config) intercepted() bool {
+	return tracingEnabled || len(c.Interceptors) > 0 || (c.SlowQueryHandler != nil && c.SlowQueryThreshold > 0)
+}
+
+// queryInterceptors returns the interceptors running around every query.
+func (c *Config) queryInterceptors() []QueryInterceptor {
+{{- if .Otel }}
+	return append([]QueryInterceptor{c.traceQuery}, c.Interceptors...)
+{{- else }}
+	return c.Interceptors
+{{- end }}
+}
+
+// intercept runs the query through the interceptors, the first one being the outermost, and then exec.
+func intercept(ctx context.Context, interceptors []QueryInterceptor, query *Query, exec QueryHandler) (*QueryResult, error) {
+	handler := exec
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, query *Query) (*QueryResult, error) {
+			return interceptor(ctx, query, next)
+		}
+	}
+
+	result, err := handler(ctx, query)
+	if result == nil {
+		result = &QueryResult{}
+	}
+	return result, err
+}
+
+// errQueryNotRun is the error of a row whose query the interceptors neither ran nor failed.
+var errQueryNotRun = fmt.Errorf("query was not run by the interceptors")
+
+// errRow returns a row failing with err. Only database/sql sets the error of a *sql.Row,
+// so the row is queried from a connector whose connections fail with err.
+func errRow(err error) *sql.Row {
+	if err == nil {
+		err = errQueryNotRun
+	}
+	db := sql.OpenDB(errConnector{err: err})
+	defer db.Close()
+	return db.QueryRowContext(context.Background(), "")
+}
+
+// errConnector is a driver.Connector whose connections fail with err.
+type errConnector struct {
+	err error
+}
+
+// Connect implements driver.Connector interface.
+func (c errConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, c.err
+}
+
+// Driver implements driver.Connector interface.
+func (c errConnector) Driver() driver.Driver {
+	return c
+}
+
+// Open implements driver.Driver interface.
+func (c errConnector) Open(string) (driver.Conn, error) {
+	return nil, c.err
+}
+
+// dbWrapper wraps DB connections to run the queries through the interceptors.
+type dbWrapper struct {
+	db QueryExecer
+	config *Config
+	table string
+}
+
+// intercept runs a query through the interceptors of the config.
+func (w *dbWrapper) intercept(ctx context.Context, query string, args []interface{}, exec QueryHandler) (*QueryResult, error) {
+	return intercept(ctx, w.config.queryInterceptors(), &Query{
+		Table:     w.table,
+		Operation: queryOperation(ctx, query),
+		SQL:       query,
+		Args:      args,
+	}, w.config.slowQueries(w.db, exec))
+}
+
+// QueryContext implements QueryExecer interface.
+func (w *dbWrapper) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	result, err := w.intercept(ctx, query, args, func(ctx context.Context, q *Query) (*QueryResult, error) {
+		rows, err := w.db.QueryContext(ctx, q.SQL, q.Args...)
+		return &QueryResult{Rows: rows}, err
+	})
+	return result.Rows, err
+}
+
+// ExecContext implements QueryExecer interface.
+func (w *dbWrapper) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := w.intercept(ctx, query, args, func(ctx context.Context, q *Query) (*QueryResult, error) {
+		res, err := w.db.ExecContext(ctx, q.SQL, q.Args...)
+		return &QueryResult{Result: res}, err
+	})
+	return result.Result, err
+}
+
+// QueryRowContext implements QueryExecer interface.
+func (w *dbWrapper) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	result, err := w.intercept(ctx, query, args, func(ctx context.Context, q *Query) (*QueryResult, error) {
+		row := w.db.QueryRowContext(ctx, q.SQL, q.Args...)
+		return &QueryResult{Row: row}, row.Err()
+	})
+	if result.Row == nil {
+		// the query was not run, fail the row with the error of the interceptor.
+		return errRow(err)
+	}
+	return result.Row
+}
+`
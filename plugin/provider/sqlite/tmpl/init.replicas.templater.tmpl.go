@@ -0,0 +1,197 @@
+package tmpl
+
+// ReplicasTemplate is the template for the read replicas.
+// This is included in the init template.
+const ReplicasTemplate = `
+// Replica is a read replica, e.g. a read-only pool of the database file, serving the reads outside transactions.
+// It implements QueryExecer and counts the running queries.
+type Replica struct {
+	inFlight  int64 // number of running queries, accessed atomically.
+	downUntil int64 // unix nanoseconds until the replica is healthy again, accessed atomically.
+
+	// DB is the connection to the replica.
+	DB *sql.DB
+	// Cooldown is how long the replica is skipped after a connection error.
+	// Defaults to 5 seconds.
+	Cooldown time.Duration
+}
+
+// NewReplica returns a new Replica.
+func NewReplica(db *sql.DB) *Replica {
+	return &Replica{DB: db}
+}
+
+// InFlight returns the number of running queries.
+// A query counts until it returns, not until its rows are closed.
+func (r *Replica) InFlight() int64 {
+	return atomic.LoadInt64(&r.inFlight)
+}
+
+// Healthy returns true if the replica can serve reads.
+func (r *Replica) Healthy() bool {
+	return time.Now().UnixNano() >= atomic.LoadInt64(&r.downUntil)
+}
+
+// MarkUnhealthy skips the replica for its cooldown.
+func (r *Replica) MarkUnhealthy() {
+	cooldown := r.Cooldown
+	if cooldown <= 0 {
+		cooldown = 5 * time.Second
+	}
+	atomic.StoreInt64(&r.downUntil, time.Now().Add(cooldown).UnixNano())
+}
+
+// observe marks the replica unhealthy if the error is a connection error.
+func (r *Replica) observe(err error) {
+	if isConnError(err) {
+		r.MarkUnhealthy()
+	}
+}
+
+// QueryContext implements QueryExecer interface.
+func (r *Replica) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	atomic.AddInt64(&r.inFlight, 1)
+	defer atomic.AddInt64(&r.inFlight, -1)
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	r.observe(err)
+	return rows, err
+}
+
+// ExecContext implements QueryExecer interface.
+func (r *Replica) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	atomic.AddInt64(&r.inFlight, 1)
+	defer atomic.AddInt64(&r.inFlight, -1)
+
+	result, err := r.DB.ExecContext(ctx, query, args...)
+	r.observe(err)
+	return result, err
+}
+
+// QueryRowContext implements QueryExecer interface.
+func (r *Replica) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	atomic.AddInt64(&r.inFlight, 1)
+	defer atomic.AddInt64(&r.inFlight, -1)
+
+	row := r.DB.QueryRowContext(ctx, query, args...)
+	r.observe(row.Err())
+	return row
+}
+
+// isConnError returns true if the error is caused by the connection rather than the query.
+func isConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr)
+}
+
+// ReplicaSelector picks the replica serving a read among the healthy ones.
+type ReplicaSelector interface {
+	Select(replicas []*Replica) *Replica
+}
+
+// RoundRobinSelector picks the replicas in turn. It is the default selector.
+type RoundRobinSelector struct {
+	next uint64
+}
+
+// Select implements ReplicaSelector interface.
+func (s *RoundRobinSelector) Select(replicas []*Replica) *Replica {
+	n := atomic.AddUint64(&s.next, 1) - 1
+	return replicas[n%uint64(len(replicas))]
+}
+
+// RandomSelector picks a random replica.
+type RandomSelector struct{}
+
+// Select implements ReplicaSelector interface.
+func (RandomSelector) Select(replicas []*Replica) *Replica {
+	return replicas[rand.Intn(len(replicas))]
+}
+
+// LeastInFlightSelector picks the replica with the fewest running queries.
+type LeastInFlightSelector struct{}
+
+// Select implements ReplicaSelector interface.
+func (LeastInFlightSelector) Select(replicas []*Replica) *Replica {
+	best := replicas[0]
+	for _, r := range replicas[1:] {
+		if r.InFlight() < best.InFlight() {
+			best = r
+		}
+	}
+	return best
+}
+
+// defaultReplicaSelector is used when no ReplicaSelector is configured.
+var defaultReplicaSelector = &RoundRobinSelector{}
+
+// selectReplica returns a healthy replica or nil if there is none.
+func selectReplica(replicas []*Replica, selector ReplicaSelector) *Replica {
+	healthy := make([]*Replica, 0, len(replicas))
+	for _, r := range replicas {
+		if r.Healthy() {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	if selector == nil {
+		selector = defaultReplicaSelector
+	}
+	return selector.Select(healthy)
+}
+
+// replicaRead runs the reads on a replica, and once more on the primary when the replica
+// fails with a connection error.
+type replicaRead struct {
+	replica *Replica
+	primary QueryExecer
+}
+
+// QueryContext implements QueryExecer interface.
+func (r *replicaRead) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := r.replica.QueryContext(ctx, query, args...)
+	if isConnError(err) {
+		return r.primary.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+// ExecContext implements QueryExecer interface.
+func (r *replicaRead) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := r.replica.ExecContext(ctx, query, args...)
+	if isConnError(err) {
+		return r.primary.ExecContext(ctx, query, args...)
+	}
+	return result, err
+}
+
+// QueryRowContext implements QueryExecer interface.
+func (r *replicaRead) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	row := r.replica.QueryRowContext(ctx, query, args...)
+	if isConnError(row.Err()) {
+		return r.primary.QueryRowContext(ctx, query, args...)
+	}
+	return row
+}
+
+// primaryKey is the key used to send the reads of a context to the primary.
+type primaryKey struct{}
+
+// WithPrimary returns a context whose reads go to the primary, e.g. to read your own writes.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// UsePrimary returns true if the reads of the context go to the primary.
+func UsePrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+`
@@ -0,0 +1,102 @@
+package tmpl
+
+// SlowQueryTemplate is the template for the slow query detection.
+// This is included in the init template.
+const SlowQueryTemplate = `
+// SlowQuery is a query slower than Config.SlowQueryThreshold.
+type SlowQuery struct {
+	Table     string
+	Operation Operation
+	SQL       string
+	Args      []interface{}
+	// Duration is how long the query took. For QueryContext it is the time until the first row.
+	Duration time.Duration
+	// Err is the error of the query, if any.
+	Err error
+	// Plan is the output of EXPLAIN QUERY PLAN, one indented line per step,
+	// when Config.ExplainSlowQueries is set.
+	Plan string
+	// ExplainErr is the error of the EXPLAIN, if any.
+	ExplainErr error
+}
+
+// SlowQueryHandler is called with the queries slower than Config.SlowQueryThreshold.
+// To explain a query returning rows outside a transaction, the handler is called from another goroutine
+// once a connection is free, because the rows hold theirs until they are read.
+type SlowQueryHandler func(ctx context.Context, query *SlowQuery)
+
+// errExplainInTx is the ExplainErr of the queries returning rows inside a transaction,
+// whose connection is busy until the rows are read.
+var errExplainInTx = fmt.Errorf("can't explain a query returning rows inside a transaction")
+
+// slowQueries returns exec reporting the queries slower than the threshold of the config to its SlowQueryHandler.
+// The plan is explained on db, the connection or transaction running the query.
+func (c *Config) slowQueries(db QueryExecer, exec QueryHandler) QueryHandler {
+	if c.SlowQueryHandler == nil || c.SlowQueryThreshold <= 0 {
+		return exec
+	}
+
+	return func(ctx context.Context, query *Query) (*QueryResult, error) {
+		start := time.Now()
+		result, err := exec(ctx, query)
+		duration := time.Since(start)
+		if duration < c.SlowQueryThreshold {
+			return result, err
+		}
+
+		slow := &SlowQuery{
+			Table:     query.Table,
+			Operation: query.Operation,
+			SQL:       query.SQL,
+			Args:      query.Args,
+			Duration:  duration,
+			Err:       err,
+		}
+		switch _, inTx := db.(*sql.Tx); {
+		case !c.ExplainSlowQueries:
+		case result.Rows == nil && result.Row == nil:
+			slow.Plan, slow.ExplainErr = explain(ctx, db, query)
+		case inTx:
+			slow.ExplainErr = errExplainInTx
+		default:
+			// the rows hold their connection until they are read, explain once the pool has a free one.
+			go func() {
+				slow.Plan, slow.ExplainErr = explain(context.Background(), db, query)
+				c.SlowQueryHandler(ctx, slow)
+			}()
+			return result, err
+		}
+		c.SlowQueryHandler(ctx, slow)
+
+		return result, err
+	}
+}
+
+// explain returns the plan of the query. EXPLAIN QUERY PLAN does not run the query.
+func explain(ctx context.Context, db QueryExecer, query *Query) (string, error) {
+	rows, err := db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query.SQL, query.Args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to explain query: %w", err)
+	}
+	defer rows.Close()
+
+	var plan strings.Builder
+	depths := make(map[int]int)
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return "", fmt.Errorf("failed to scan plan: %w", err)
+		}
+
+		// steps are indented under their parent.
+		depths[id] = depths[parent] + 1
+		plan.WriteString(strings.Repeat("  ", depths[id]-1) + detail + "\n")
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to explain query: %w", err)
+	}
+
+	return plan.String(), nil
+}
+`
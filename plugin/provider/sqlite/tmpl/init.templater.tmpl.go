@@ -40,6 +40,31 @@ const InitStatementTemplate = `
 
 {{ template "transaction" . }}
 
+//
+// Read replicas.
+//
+
+{{ template "replicas" . }}
+
+//
+// Query interceptors.
+//
+
+{{ template "interceptors" . }}
+
+//
+// Slow queries.
+//
+
+{{ template "slowquery" . }}
+{{ if .Otel }}
+//
+// OpenTelemetry tracing.
+//
+
+{{ template "tracing" . }}
+{{ end }}
+
 //
 // Options.
 // 
@@ -59,6 +84,12 @@ type Option func(*Options)
 type Options struct {
 	// if true, then method was create/update relations
 	relations bool
+	// ignoreConflictField is the field to ignore conflict.
+	ignoreConflictField string
+	// mustAffect turns zero affected rows into ErrNotFound.
+	mustAffect bool
+	// preloads are the relations to load with the found rows.
+	preloads []Relation
 }
 
 // WithRelations sets the relations flag.
@@ -69,6 +100,40 @@ func WithRelations() Option {
 	}
 }
 
+// WithIgnoreConflictField sets the ignore conflict field.
+func WithIgnoreConflictField(field string) Option {
+	return func(o *Options) {
+		o.ignoreConflictField = field
+	}
+}
+
+// WithMustAffect makes Update, UpdateMany and the delete methods return ErrNotFound
+// when no rows were affected.
+func WithMustAffect() Option {
+	return func(o *Options) {
+		o.mustAffect = true
+	}
+}
+
+// WithPreload loads the given relations together with the found rows.
+func WithPreload(relations ...Relation) Option {
+	return func(o *Options) {
+		o.preloads = append(o.preloads, relations...)
+	}
+}
+
+// rowsAffected returns the number of rows affected by the result.
+func rowsAffected(result sql.Result, options *Options) (int64, error) {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if options.mustAffect && affected == 0 {
+		return 0, ErrNotFound
+	}
+	return affected, nil
+}
+
 // FilterApplier is a condition filters.
 type FilterApplier interface {
 	Apply(query sq.SelectBuilder) sq.SelectBuilder
@@ -85,6 +150,12 @@ type QueryBuilder struct {
 	sortOptions  []FilterApplier
 	// pagination is the pagination.
 	pagination    *Pagination
+	// preloads are the relations to load with the found rows.
+	preloads      []Relation
+	// limitPerParent limits the related rows loaded for every parent row.
+	limitPerParent *uint64
+	// partitionBy is the column of the parent row, set by the relation loaders.
+	partitionBy string
 }
 
 // NewQueryBuilder returns a new query builder.
@@ -116,6 +187,20 @@ func (b *QueryBuilder) WithPagination(pagination *Pagination) *QueryBuilder {
 	return b
 }
 
+// Preload adds relations to load together with the found rows.
+// Each relation is loaded with a single query per level, whatever the number of rows.
+func (b *QueryBuilder) Preload(relations ...Relation) *QueryBuilder {
+	b.preloads = append(b.preloads, relations...)
+	return b
+}
+
+// LimitPerParent limits the related rows loaded for every parent row, e.g. the latest 3 posts of every user.
+// It is supported by the LoadBatch relation loaders and Preload, the sort options order the rows of every parent.
+func (b *QueryBuilder) LimitPerParent(limit uint64) *QueryBuilder {
+	b.limitPerParent = &limit
+	return b
+}
+
 // Filter is a helper function to create a new query builder with filter options.
 func FilterBuilder(filterOptions ...FilterApplier) *QueryBuilder {
 	return NewQueryBuilder().WithFilter(filterOptions...)
@@ -145,6 +230,88 @@ func PaginateBuilder(limit, offset uint64) *QueryBuilder {
 	return NewQueryBuilder().WithPagination(NewPagination(limit, offset))
 }
 
+// PreloadBuilder is a helper function to create a new query builder with preloads.
+func PreloadBuilder(relations ...Relation) *QueryBuilder {
+	return NewQueryBuilder().Preload(relations...)
+}
+
+// LimitPerParentBuilder is a helper function to create a new query builder with a limit per parent row.
+func LimitPerParentBuilder(limit uint64) *QueryBuilder {
+	return NewQueryBuilder().LimitPerParent(limit)
+}
+
+// partitionBuilder returns a query builder which counts the limit per parent row on the column.
+func partitionBuilder(column string) *QueryBuilder {
+	builder := NewQueryBuilder()
+	builder.partitionBy = column
+	return builder
+}
+
+// Relation identifies a relation of a table, e.g. UserRelPosts.
+// Use Then to preload nested relations and With to filter or sort the related rows.
+type Relation struct {
+	// name is the relation name, "<Structure>.<Field>".
+	name string
+	// builders are applied when the related rows are loaded.
+	builders []*QueryBuilder
+}
+
+// Then returns a copy of the relation that also preloads the given relations of the related rows.
+func (r Relation) Then(relations ...Relation) Relation {
+	return r.With(PreloadBuilder(relations...))
+}
+
+// With returns a copy of the relation that loads the related rows with the given builders.
+func (r Relation) With(builders ...*QueryBuilder) Relation {
+	r.builders = append(append([]*QueryBuilder{}, r.builders...), builders...)
+	return r
+}
+
+// String returns the relation name.
+func (r Relation) String() string {
+	return r.name
+}
+
+// mergeRelations merges relations with the same name, so each relation is loaded once.
+func mergeRelations(relations []Relation) []Relation {
+	merged := make([]Relation, 0, len(relations))
+	index := make(map[string]int, len(relations))
+	for _, r := range relations {
+		if i, ok := index[r.name]; ok {
+			merged[i] = merged[i].With(r.builders...)
+			continue
+		}
+		index[r.name] = len(merged)
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// limitPerParentQuery wraps the query to keep at most limit rows for every value of the column.
+// The sort options order the rows of every parent and the result.
+func limitPerParentQuery(builder sq.StatementBuilderType, query sq.SelectBuilder, columns []string, column string, limit uint64, sortOptions []FilterApplier) (sq.SelectBuilder, error) {
+	orderBy := column
+	var orderArgs []interface{}
+	if len(sortOptions) > 0 {
+		sortQuery := sq.Select("*")
+		for _, option := range sortOptions {
+			sortQuery = option.Apply(sortQuery)
+		}
+		sortSQL, sortArgs, err := sortQuery.ToSql()
+		if err != nil {
+			return query, fmt.Errorf("failed to build sort: %w", err)
+		}
+		orderBy = strings.TrimPrefix(sortSQL, "SELECT * ORDER BY ")
+		orderArgs = sortArgs
+	}
+
+	partitioned := query.Column("ROW_NUMBER() OVER (PARTITION BY "+column+" ORDER BY "+orderBy+") AS partition_row", orderArgs...)
+	return builder.Select(columns...).
+		FromSelect(partitioned, "partitioned").
+		Where("partition_row <= ?", limit).
+		OrderByClause(orderBy, orderArgs...), nil
+}
+
 // Pagination is the pagination.
 type Pagination struct {
 	// limit is the limit.
@@ -290,12 +457,45 @@ func WithMaxLifetime(maxLifetime time.Duration) {{ clientName }}Option {
 const StorageTemplate = `
 // {{ storageName | lowerCamelCase }} is a map of provider to init function.
 type {{ storageName | lowerCamelCase }} struct {
-	db *sql.DB // The database connection.
+	config *Config // configuration for the {{ storageName }}.
 	tx *TxManager // The transaction manager.
 {{ range $value := storages }}
 {{ $value.Key }} {{ $value.Value }}{{ end }}
 }
 
+// configuration for the {{ storageName }}.
+type Config struct {
+	DB *sql.DB
+
+	// Replicas serve the reads outside transactions instead of DB.
+	// When none of them is healthy, the reads go to DB.
+	Replicas []*Replica
+	// ReplicaSelector picks one of the healthy replicas. Defaults to round-robin.
+	ReplicaSelector ReplicaSelector
+
+	QueryLogMethod    func(ctx context.Context, table string, query string, args ...interface{})
+	ErrorLogMethod    func(ctx context.Context, err error, message string)
+
+	// Interceptors wrap every query, the first one being the outermost.
+	Interceptors []QueryInterceptor
+	// SlowQueryThreshold is the duration from which a query is reported to SlowQueryHandler.
+	// Zero reports no query.
+	SlowQueryThreshold time.Duration
+	// SlowQueryHandler is called with the queries slower than SlowQueryThreshold.
+	SlowQueryHandler SlowQueryHandler
+	// ExplainSlowQueries attaches the plan of the slow queries, explained on the connection that ran them.
+	ExplainSlowQueries bool
+{{- if .Otel }}
+	// TracerProvider creates the spans of the queries and transactions.
+	// Defaults to the global provider.
+	TracerProvider trace.TracerProvider
+{{- end }}
+
+	// Clock returns the current time for auto_create_time and auto_update_time fields.
+	// Defaults to time.Now.
+	Clock func() time.Time
+}
+
 // {{ storageName }} is the interface for the {{ storageName }}.
 type {{ storageName }} interface { 
 	{{- range $value := storages }}
@@ -314,14 +514,32 @@ type {{ storageName }} interface {
 	UpgradeTables(ctx context.Context) error
 }
 
-// New{{ storageName }} returns a new {{ storageName }}.
+// New{{ storageName }} returns a new {{ storageName }} using db with the default config.
 func New{{ storageName }}(db *sql.DB) {{ storageName }} {
+	return new{{ storageName }}(&Config{DB: db})
+}
+
+// New{{ storageName }}WithConfig returns a new {{ storageName }} using the config.
+func New{{ storageName }}WithConfig(config *Config) ({{ storageName }}, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config is required")
+	}
+
+	if config.DB == nil {
+		return nil, fmt.Errorf("db is required")
+	}
+
+	return new{{ storageName }}(config), nil
+}
+
+// new{{ storageName }} returns a new {{ storageName }} using the checked config.
+func new{{ storageName }}(config *Config) *{{ storageName | lowerCamelCase }} {
 	return &{{ storageName | lowerCamelCase }}{
-		db: db,
-		tx: NewTxManager(db),
-	{{ range $value := storages }}
-		{{ $value.Key }}: New{{ $value.Value }}(db),
-	{{ end }}
+		config: config,
+		tx:     config.txManager(),
+	{{- range $value := storages }}
+		{{ $value.Key }}: new{{ $value.Value }}(config),
+	{{- end }}
 	}
 }
 
@@ -475,9 +693,130 @@ func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
 	return tx, ok
 }
 
+// savepointKey is the key used to store the innermost savepoint in the context.
+type savepointKey struct{}
+
+// savepoint is a nested transaction, begun inside an open transaction.
+type savepoint struct {
+	// level is 1 for the first nested transaction.
+	level int
+	// done is set once the savepoint was released or rolled back.
+	done bool
+}
+
+// name returns the name of the savepoint.
+func (s *savepoint) name() string {
+	return fmt.Sprintf("sp_%d", s.level)
+}
+
+// txHooksKey is the key used to store the hooks of the innermost transaction in the context.
+type txHooksKey struct{}
+
+// txHooks are the callbacks registered with OnCommit and OnRollback on a transaction or savepoint.
+type txHooks struct {
+	// parent is the hooks of the enclosing transaction, nil for the outermost one.
+	parent   *txHooks
+	commit   []func(context.Context)
+	rollback []func(context.Context)
+	// done is set once the hooks were run or handed over to the parent.
+	done bool
+}
+
+// hooksFromContext returns the hooks of the innermost transaction in the context.
+func hooksFromContext(ctx context.Context) *txHooks {
+	h, _ := ctx.Value(txHooksKey{}).(*txHooks)
+	return h
+}
+
+// run runs the commit or the rollback hooks in the order they were registered.
+// The hooks run at most once, outside of the transaction.
+func (h *txHooks) run(ctx context.Context, committed bool) {
+	if h == nil || h.done {
+		return
+	}
+	h.done = true
+
+	hooks := h.rollback
+	if committed {
+		hooks = h.commit
+	}
+
+	ctx = withoutTx(ctx)
+	for _, f := range hooks {
+		f(ctx)
+	}
+}
+
+// release hands the hooks of a released savepoint over to the enclosing transaction.
+func (h *txHooks) release() {
+	if h == nil || h.done || h.parent == nil {
+		return
+	}
+	h.done = true
+
+	h.parent.commit = append(h.parent.commit, h.commit...)
+	h.parent.rollback = append(h.parent.rollback, h.rollback...)
+}
+
+// withoutTx returns a context without the transaction, its savepoints and hooks.
+func withoutTx(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, txKey{}, nil)
+	ctx = context.WithValue(ctx, savepointKey{}, nil)
+	return context.WithValue(ctx, txHooksKey{}, nil)
+}
+
+// OnCommit registers f to run after the transaction in the context is committed.
+// Hooks registered in a nested transaction wait for the outermost commit
+// and are dropped when the nested transaction is rolled back.
+// Outside a transaction f runs immediately.
+func OnCommit(ctx context.Context, f func(context.Context)) {
+	if h := hooksFromContext(ctx); h != nil && !h.done {
+		h.commit = append(h.commit, f)
+		return
+	}
+	f(ctx)
+}
+
+// OnRollback registers f to run after the transaction in the context is rolled back,
+// or after a nested transaction is rolled back to its savepoint.
+// Outside a transaction f runs immediately.
+func OnRollback(ctx context.Context, f func(context.Context)) {
+	if h := hooksFromContext(ctx); h != nil && !h.done {
+		h.rollback = append(h.rollback, f)
+		return
+	}
+	f(ctx)
+}
+
+// TxDepth returns the transaction depth of the context:
+// 0 without a transaction, 1 in a transaction and one more for every nested transaction.
+func TxDepth(ctx context.Context) int {
+	if _, ok := TxFromContext(ctx); !ok {
+		return 0
+	}
+	if sp, ok := ctx.Value(savepointKey{}).(*savepoint); ok {
+		return sp.level + 1
+	}
+	return 1
+}
+
+// TxOptions are the options of a transaction.
+type TxOptions struct {
+	// Isolation is the isolation level, the driver default if zero.
+	Isolation sql.IsolationLevel
+	// ReadOnly begins a read-only transaction.
+	ReadOnly bool
+	// Deferrable is accepted for compatibility with the PostgreSQL provider and ignored by SQLite.
+	Deferrable bool
+}
+
 // TxManager is a transaction manager.
 type TxManager struct {
 	db *sql.DB
+{{- if .Otel }}
+	// tracerProvider creates the spans of the transactions.
+	tracerProvider trace.TracerProvider
+{{- end }}
 }
 
 // NewTxManager creates a new transaction manager.
@@ -486,40 +825,124 @@ func NewTxManager(db *sql.DB) *TxManager {
 		db: db,
 	}
 }
+{{ if .Otel }}
+// WithTracerProvider returns a transaction manager creating the spans of the transactions with provider.
+func (m *TxManager) WithTracerProvider(provider trace.TracerProvider) *TxManager {
+	return &TxManager{
+		db:             m.db,
+		tracerProvider: provider,
+	}
+}
+{{ end }}
+// txManager returns the transaction manager the storages use for their own transactions.
+func (c *Config) txManager() *TxManager {
+	return NewTxManager(c.DB){{ if .Otel }}.WithTracerProvider(c.TracerProvider){{ end }}
+}
 
 // Begin begins a transaction.
+// Inside an open transaction it creates a savepoint, which Commit releases and Rollback rolls back to.
 func (m *TxManager) Begin(ctx context.Context) (context.Context, error) {
-	if _, ok := TxFromContext(ctx); ok {
-		return ctx, nil
+	return m.BeginTx(ctx, TxOptions{})
+}
+
+// BeginTx begins a transaction with the options. The transaction is rolled back if ctx is canceled.
+// Inside an open transaction it creates a savepoint and the options are ignored.
+func (m *TxManager) BeginTx(ctx context.Context, opts TxOptions) (context.Context, error) {
+	if tx, ok := TxFromContext(ctx); ok {
+		sp := &savepoint{level: TxDepth(ctx)}
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT "+sp.name()); err != nil {
+			return ctx, fmt.Errorf("could not create savepoint: %w", err)
+		}
+
+		// store the savepoint and its hooks in the context.
+		ctx = context.WithValue(ctx, savepointKey{}, sp)
+		return context.WithValue(ctx, txHooksKey{}, &txHooks{parent: hooksFromContext(ctx)}), nil
 	}
 
-	tx, err := m.db.Begin()
+	{{- if .Otel }}
+	ctx = m.startTxSpan(ctx)
+	{{- end }}
+	tx, err := m.db.BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
 	if err != nil {
+		{{- if .Otel }}
+		endTxSpan(ctx, "begin", err)
+		{{- end }}
 		return ctx, fmt.Errorf("could not begin transaction: %w", err)
 	}
 
-	// store the transaction in the context.
-	return context.WithValue(ctx, txKey{}, tx), nil
+	// store the transaction and its hooks in the context.
+	ctx = context.WithValue(ctx, txKey{}, tx)
+	return context.WithValue(ctx, txHooksKey{}, &txHooks{}), nil
 }
 
-// IsTxOpen returns true if a transaction is open.
+// Commit commits a transaction or releases the savepoint of a nested one.
+// The OnCommit hooks run after the outermost transaction is committed.
 func (m *TxManager) Commit(ctx context.Context) error {
 	tx, ok := TxFromContext(ctx)
 	if !ok {
 		return fmt.Errorf("transactions wasn't opened")
 	}
 
+	if sp, ok := ctx.Value(savepointKey{}).(*savepoint); ok {
+		if sp.done {
+			return fmt.Errorf("savepoint %s was already closed", sp.name())
+		}
+		sp.done = true
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+sp.name()); err != nil {
+			// drop the changes made since the savepoint, the outer transaction goes on without them.
+			if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+sp.name()); rbErr != nil {
+				return fmt.Errorf("could not release savepoint: %w, and failed to rollback to it: %v", err, rbErr)
+			}
+			hooksFromContext(ctx).run(ctx, false)
+			return fmt.Errorf("could not release savepoint: %w", err)
+		}
+		hooksFromContext(ctx).release()
+		return nil
+	}
+
 	if err := tx.Commit(); err != nil {
+		{{- if .Otel }}
+		endTxSpan(ctx, "commit", err)
+		{{- end }}
+		hooksFromContext(ctx).run(ctx, false)
 		return fmt.Errorf("could not commit transaction: %w", err)
 	}
+	{{- if .Otel }}
+	endTxSpan(ctx, "commit", nil)
+	{{- end }}
+	hooksFromContext(ctx).run(ctx, true)
 
 	return nil
 }
 
-// Rollback rolls back a transaction.
+// Rollback rolls back a transaction or, in a nested one, the changes made since its savepoint.
+// Rolling back a closed savepoint does nothing.
 func (m *TxManager) Rollback(ctx context.Context) error {
+	if sp, ok := ctx.Value(savepointKey{}).(*savepoint); ok {
+		if sp.done {
+			return nil
+		}
+		sp.done = true
+
+		tx, _ := TxFromContext(ctx)
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+sp.name()); err != nil {
+			return fmt.Errorf("failed to rollback to savepoint: %w", err)
+		}
+		hooksFromContext(ctx).run(ctx, false)
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+sp.name()); err != nil {
+			return fmt.Errorf("failed to release savepoint: %w", err)
+		}
+		return nil
+	}
+
 	if tx, ok := TxFromContext(ctx); ok {
 		err := tx.Rollback()
+		{{- if .Otel }}
+		if !errors.Is(err, sql.ErrTxDone) {
+			endTxSpan(ctx, "rollback", err)
+		}
+		{{- end }}
+		hooksFromContext(ctx).run(ctx, false)
 		if err != nil && !errors.Is(err, sql.ErrTxDone) {
 			return err
 		}
@@ -529,13 +952,14 @@ func (m *TxManager) Rollback(ctx context.Context) error {
 }
 
 // ExecFuncWithTx executes a function with a transaction.
+// Inside an open transaction the function runs in a nested one, so its failure only rolls back its own changes.
 func (m *TxManager) ExecFuncWithTx(ctx context.Context, f func(context.Context) error) error {
-	// if a transaction is already open, just execute the function.
-	if m.IsTxOpen(ctx) {
-		return f(ctx)
-	}
+	return m.ExecFuncWithTxOptions(ctx, TxOptions{}, f)
+}
 
-	ctx, err := m.Begin(ctx)
+// ExecFuncWithTxOptions executes a function with a transaction begun with the options.
+func (m *TxManager) ExecFuncWithTxOptions(ctx context.Context, opts TxOptions, f func(context.Context) error) error {
+	ctx, err := m.BeginTx(ctx, opts)
 	if err != nil {
 		return err
 	}
@@ -572,13 +996,123 @@ type QueryExecer interface {
 // This is included in the init template.
 const ErrorsTemplate = `
 var (
-	// ErrNotFound is returned when a record is not found.
+	// ErrRowNotFound is returned when a record is not found.
 	ErrRowNotFound = fmt.Errorf("row not found")
+	// ErrNotFound is returned when a record is not found or, with WithMustAffect, no rows were affected.
+	ErrNotFound = ErrRowNotFound
 	// ErrNoTransaction is returned when a transaction is not provided.
 	ErrNoTransaction = fmt.Errorf("no transaction provided")
 	// ErrRowAlreadyExist is returned when a row already exist.
 	ErrRowAlreadyExist    = fmt.Errorf("row already exist")
 	// ErrModelIsNil is returned when a relation model is nil.
 	ErrModelIsNil = fmt.Errorf("model is nil")
+	// ErrForeignChild is returned when a nested update gets a child item with the key of a row of another parent.
+	ErrForeignChild = fmt.Errorf("child belongs to another parent")
 )
+
+// ErrUniqueViolation is returned when a write violates a unique constraint or the primary key.
+// errors.Is(err, ErrRowAlreadyExist) holds for it as well.
+type ErrUniqueViolation struct {
+	// Constraint is the name of the violated constraint, empty if the database does not report it.
+	Constraint string
+	// Columns are the columns of the violated constraint, empty if the database does not report them.
+	Columns []string
+	// Err is the driver error.
+	Err error
+}
+
+func (e *ErrUniqueViolation) Error() string { return fmt.Sprintf("unique violation: %v", e.Err) }
+func (e *ErrUniqueViolation) Unwrap() error { return e.Err }
+func (e *ErrUniqueViolation) Is(target error) bool { return target == ErrRowAlreadyExist }
+
+// ErrForeignKeyViolation is returned when a write references a missing row or deletes a referenced one.
+type ErrForeignKeyViolation struct {
+	// Constraint is the name of the violated constraint, empty if the database does not report it.
+	Constraint string
+	// Columns are the referencing columns, empty if the database does not report them.
+	Columns []string
+	// Err is the driver error.
+	Err error
+}
+
+func (e *ErrForeignKeyViolation) Error() string { return fmt.Sprintf("foreign key violation: %v", e.Err) }
+func (e *ErrForeignKeyViolation) Unwrap() error { return e.Err }
+
+// ErrCheckViolation is returned when a write violates a check constraint.
+type ErrCheckViolation struct {
+	// Constraint is the name of the violated constraint, empty if the database does not report it.
+	Constraint string
+	// Err is the driver error.
+	Err error
+}
+
+func (e *ErrCheckViolation) Error() string { return fmt.Sprintf("check violation: %v", e.Err) }
+func (e *ErrCheckViolation) Unwrap() error { return e.Err }
+
+// ErrSerialization is returned when a transaction conflicts with a concurrent one and may be retried.
+type ErrSerialization struct {
+	// Err is the driver error.
+	Err error
+}
+
+func (e *ErrSerialization) Error() string { return fmt.Sprintf("serialization failure: %v", e.Err) }
+func (e *ErrSerialization) Unwrap() error { return e.Err }
+
+// MapError maps a sqlite3 error to ErrUniqueViolation, ErrForeignKeyViolation, ErrCheckViolation
+// or ErrSerialization and sql.ErrNoRows to ErrNotFound. Other errors are returned as is.
+// SQLite does not report constraint names, busy and locked databases map to ErrSerialization.
+func MapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return &ErrUniqueViolation{Columns: sqliteConstraintColumns(sqliteErr.Error()), Err: err}
+	case sqlite3.ErrConstraintForeignKey:
+		return &ErrForeignKeyViolation{Err: err}
+	case sqlite3.ErrConstraintCheck:
+		return &ErrCheckViolation{Constraint: sqliteConstraintName(sqliteErr.Error()), Err: err}
+	}
+	switch sqliteErr.Code {
+	case sqlite3.ErrBusy, sqlite3.ErrLocked:
+		return &ErrSerialization{Err: err}
+	}
+	return err
+}
+
+// sqliteConstraintColumns returns the columns of a message like "UNIQUE constraint failed: users.tenant_id, users.email".
+func sqliteConstraintColumns(message string) []string {
+	i := strings.Index(message, "failed: ")
+	if i < 0 {
+		return nil
+	}
+	var columns []string
+	for _, column := range strings.Split(message[i+len("failed: "):], ", ") {
+		if dot := strings.LastIndex(column, "."); dot >= 0 {
+			column = column[dot+1:]
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// sqliteConstraintName returns the constraint of a message like "CHECK constraint failed: age_positive".
+func sqliteConstraintName(message string) string {
+	if i := strings.Index(message, "failed: "); i >= 0 {
+		return message[i+len("failed: "):]
+	}
+	return ""
+}
+
+// maxBindParams is the maximum number of bind parameters sqlite accepts in one statement.
+const maxBindParams = 32766
 `
@@ -0,0 +1,107 @@
+package tmpl
+
+// TracingTemplate is the template for the OpenTelemetry tracing.
+// This is included in the init template when the otel parameter is set.
+const TracingTemplate = `
+// tracerName is the instrumentation name of the spans.
+const tracerName = "github.com/cjp2600/protoc-gen-structify"
+
+// dbSystem is the db.system attribute of the spans.
+const dbSystem = "sqlite"
+
+// tracer returns the tracer of the provider, or of the global provider if it is nil.
+func tracer(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+var (
+	statementComments = regexp.MustCompile(` + "`" + `/\*.*?\*/|--[^\n]*` + "`" + `)
+	statementStrings  = regexp.MustCompile(` + "`" + `'(?:[^']|'')*'` + "`" + `)
+	statementNumbers  = regexp.MustCompile(` + "`" + `([^\w$])\d+(?:\.\d+)?` + "`" + `)
+)
+
+// sanitizeStatement removes the comments of a statement and replaces its literals with ?.
+func sanitizeStatement(query string) string {
+	query = statementComments.ReplaceAllString(query, "")
+	query = statementStrings.ReplaceAllString(query, "?")
+	query = statementNumbers.ReplaceAllString(query, "${1}?")
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// startSpan starts the span of a storage method, the parent of the spans of its queries.
+func (c *Config) startSpan(ctx context.Context, table, method string) (context.Context, trace.Span) {
+	return tracer(c.TracerProvider).Start(ctx, method,
+		trace.WithAttributes(
+			attribute.String("db.system", dbSystem),
+			attribute.String("db.sql.table", table),
+			attribute.String("code.function", method),
+		),
+	)
+}
+
+// endSpan ends the span of a storage method with the error it returns, rows not found aside.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, ErrRowNotFound) && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceQuery is the interceptor starting a span for every query.
+func (c *Config) traceQuery(ctx context.Context, query *Query, next QueryHandler) (*QueryResult, error) {
+	ctx, span := tracer(c.TracerProvider).Start(ctx, string(query.Operation)+" "+query.Table,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", dbSystem),
+			attribute.String("db.sql.table", query.Table),
+			attribute.String("db.operation", string(query.Operation)),
+			attribute.String("db.statement", sanitizeStatement(query.SQL)),
+		),
+	)
+	defer span.End()
+
+	result, err := next(ctx, query)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	if result != nil && result.Result != nil {
+		if rows, err := result.Result.RowsAffected(); err == nil {
+			span.SetAttributes(attribute.Int64("db.rows_affected", rows))
+		}
+	}
+
+	return result, err
+}
+
+// txSpanKey is the key used to store the span of the transaction in the context.
+type txSpanKey struct{}
+
+// startTxSpan starts the span of a transaction, the parent of the spans of its queries.
+func (m *TxManager) startTxSpan(ctx context.Context) context.Context {
+	ctx, span := tracer(m.tracerProvider).Start(ctx, "transaction",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", dbSystem)),
+	)
+	return context.WithValue(ctx, txSpanKey{}, span)
+}
+
+// endTxSpan ends the span of the transaction with its outcome: begin, commit or rollback.
+func endTxSpan(ctx context.Context, outcome string, err error) {
+	span, ok := ctx.Value(txSpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(attribute.String("db.transaction.outcome", outcome))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+`
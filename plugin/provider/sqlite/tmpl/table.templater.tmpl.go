@@ -5,17 +5,28 @@ const TableTemplate = `
 {{ template "structure" . }}
 {{ template "table_conditions" . }}
 {{ template "create_method" . }}
+{{ template "batch_upsert_method" . }}
 {{ template "update_method" . }}
+{{ template "returning_method" . }}
 {{ template "delete_method" . }}
 {{- if (hasPrimaryKey) }}
 {{ template "get_by_id_method" . }}
 {{- end }}
+{{- if (hasUniqueKeys) }}
+{{ template "unique_method" . }}
+{{- end }}
 {{ template "find_many_method" . }}
 {{ template "find_one_method" . }}
 {{ template "count_method" . }}
 {{ template "find_with_pagination" . }}
 {{ template "lock_method" . }}
 {{ template "raw_method" . }}
+{{- if (hasManyToMany) }}
+{{ template "many_to_many_method" . }}
+{{- end }}
+{{- if (hasSelfRelation) }}
+{{ template "tree_method" . }}
+{{- end }}
 `
 
 const TableConditionFilters = `
@@ -221,11 +232,59 @@ const TableConditionFilters = `
   {{ end }}
   {{ end }}
 {{ end }}
+
+{{ range $field := fields }}
+  {{- if ($field | isRelation) }}
+  {{- $rel := ($field | relation) }}
+  {{- if $rel }}
+	// {{ structureName }}Has{{ $field | pluralFieldName }} returns a condition that checks if the {{ structureName }} has {{ $field | fieldName }} matching all filters.
+    func {{ structureName }}Has{{ $field | pluralFieldName }}(filters ...FilterApplier) FilterApplier {
+      return {{ structureName | lowerCamelCase }}{{ $field | fieldName }}Exists(false, filters)
+    }
+
+	// {{ structureName }}HasNo{{ $field | pluralFieldName }} returns a condition that checks if the {{ structureName }} has no {{ $field | fieldName }} matching all filters.
+    func {{ structureName }}HasNo{{ $field | pluralFieldName }}(filters ...FilterApplier) FilterApplier {
+      return {{ structureName | lowerCamelCase }}{{ $field | fieldName }}Exists(true, filters)
+    }
+
+	// {{ structureName | lowerCamelCase }}{{ $field | fieldName }}Exists correlates the {{ $field | relationTableName }} rows with the {{ tableName }} row.
+    {{- $sub := ($field | relationTableName) }}
+    {{- if $rel.SelfReference }}{{ $sub = "sub" }}{{ end }}
+    func {{ structureName | lowerCamelCase }}{{ $field | fieldName }}Exists(not bool, filters []FilterApplier) FilterApplier {
+      return ExistsCondition{
+        Table:   "{{ $field | relationTableName }}",
+        {{- if $rel.SelfReference }}
+        Alias:   "{{ $sub }}",
+        {{- end }}
+        {{- if ($field | isManyToMany) }}
+        On:      "{{ $sub }}.{{ $rel.Reference }} IN (SELECT {{ $rel.Through }}.{{ $rel.ThroughReference }} FROM {{ $rel.Through }} WHERE {{ $rel.Through }}.{{ $rel.ThroughField }} = {{ tableName }}.{{ $rel.Field }})",
+        {{- else if ($field | isCompositeRelation) }}
+        On:      "{{ range $i, $ref := ($field | relationReferences) }}{{ if $i }} AND {{ end }}{{ $sub }}.{{ $ref.GetName }} = {{ tableName }}.{{ (index ($field | relationFields) $i).GetName }}{{ end }}",
+        {{- else }}
+        On:      "{{ $sub }}.{{ $rel.Reference }} = {{ tableName }}.{{ $rel.Field }}",
+        {{- end }}
+        Not:     not,
+        Filters: filters,
+      }
+    }
+  {{ end }}
+  {{- end }}
+{{ end }}
 `
 
 const TableFindWithPaginationMethodTemplate = `
 // FindManyWithPagination finds multiple {{ structureName }} with pagination support.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) FindManyWithPagination(ctx context.Context, limit int, page int, builders ...*QueryBuilder) ([]*{{structureName}}, *Paginator, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.FindManyWithPagination")
+	res, paginator, err := t.doFindManyWithPagination(ctx, limit, page, builders...)
+	endSpan(span, err)
+	return res, paginator, err
+}
+
+// doFindManyWithPagination is FindManyWithPagination without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doFindManyWithPagination{{ else }}FindManyWithPagination{{ end }}(ctx context.Context, limit int, page int, builders ...*QueryBuilder) ([]*{{structureName}}, *Paginator, error) {
 	// Count the total number of records
 	totalCount, err := t.Count(ctx, builders...)
 	if err != nil {
@@ -258,7 +317,17 @@ func (t *{{ storageName | lowerCamelCase }}) FindManyWithPagination(ctx context.
 
 const TableLockMethodTemplate = `
 // SelectForUpdate lock locks the {{ structureName }} for the given ID.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) SelectForUpdate(ctx context.Context, builders ...*QueryBuilder) (*{{structureName}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.SelectForUpdate")
+	res, err := t.doSelectForUpdate(ctx, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doSelectForUpdate is SelectForUpdate without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doSelectForUpdate{{ else }}SelectForUpdate{{ end }}(ctx context.Context, builders ...*QueryBuilder) (*{{structureName}}, error) {
 	query := t.queryBuilder.Select(t.Columns()...).From(t.TableName()).Suffix("FOR UPDATE")
 
 	// apply options from builder
@@ -279,7 +348,7 @@ func (t *{{ storageName | lowerCamelCase }}) SelectForUpdate(ctx context.Context
 		return nil, fmt.Errorf("failed to build query: %w", err)
 	}
 
-	row := t.DB(ctx).QueryRowContext(ctx, sqlQuery, args...)
+	row := t.DB(ctx, true).QueryRowContext(ctx, sqlQuery, args...)
 	var model {{ structureName }}
     if err := model.ScanRow(row); err != nil {
         if errors.Is(err, sql.ErrNoRows){
@@ -294,7 +363,17 @@ func (t *{{ storageName | lowerCamelCase }}) SelectForUpdate(ctx context.Context
 
 const TableCountMethodTemplate = `
 // Count counts {{ structureName }} based on the provided options.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) Count(ctx context.Context, builders ...*QueryBuilder) (int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Count")
+	res, err := t.doCount(ctx, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doCount is Count without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doCount{{ else }}Count{{ end }}(ctx context.Context, builders ...*QueryBuilder) (int64, error) {
 	// build query
 	query := t.queryBuilder.Select("COUNT(*)").From(t.TableName())
 
@@ -316,7 +395,7 @@ func (t *{{ storageName | lowerCamelCase }}) Count(ctx context.Context, builders
 		return 0, fmt.Errorf("failed to build query: %w", err)
 	}
 
-	row := t.DB(ctx).QueryRowContext(ctx, sqlQuery, args...)
+	row := t.DB(ctx, false).QueryRowContext(ctx, sqlQuery, args...)
 	var count int64
 	if err := row.Scan(&count); err != nil {
 		return 0, fmt.Errorf("failed to count {{ structureName }}: %w", err)
@@ -328,7 +407,17 @@ func (t *{{ storageName | lowerCamelCase }}) Count(ctx context.Context, builders
 
 const TableFindOneMethodTemplate = `
 // FindOne finds a single {{ structureName }} based on the provided options.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) FindOne(ctx context.Context, builders ...*QueryBuilder) (*{{structureName}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.FindOne")
+	res, err := t.doFindOne(ctx, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doFindOne is FindOne without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doFindOne{{ else }}FindOne{{ end }}(ctx context.Context, builders ...*QueryBuilder) (*{{structureName}}, error) {
 	// Use findMany but limit the results to 1
 	builders = append(builders, LimitBuilder(1))
 	results, err := t.FindMany(ctx, builders...)
@@ -346,14 +435,86 @@ func (t *{{ storageName | lowerCamelCase }}) FindOne(ctx context.Context, builde
 
 const TableFindManyMethodTemplate = `
 // FindMany finds multiple {{ structureName }} based on the provided options.
+{{- if otel }}
 func (t *{{ storageName | lowerCamelCase }}) FindMany(ctx context.Context, builders ...*QueryBuilder) ([]*{{structureName}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.FindMany")
+	res, err := t.doFindMany(ctx, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doFindMany is FindMany without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doFindMany{{ else }}FindMany{{ end }}(ctx context.Context, builders ...*QueryBuilder) ([]*{{structureName}}, error) {
 	query := t.queryBuilder.Select(t.Columns()...).From(t.TableName())
 
+	// set default options
+	options := &Options{}
+
+	// collect the limit per parent row of the relation loaders
+	var limitPerParent *uint64
+	var partitionBy string
+	var sortOptions []FilterApplier
+	var paginated bool
+
+	// apply options from builder
 	for _, builder := range builders {
 		if builder == nil {
 			continue
 		}
-		query = builder.Apply(query)
+
+		// apply filter options
+		for _, option := range builder.filterOptions {
+			query = option.Apply(query)
+		}
+
+		// apply pagination
+		if builder.pagination != nil {
+			paginated = true
+			if builder.pagination.limit != nil {
+				query = query.Limit(*builder.pagination.limit)
+			}
+			if builder.pagination.offset != nil {
+				query = query.Offset(*builder.pagination.offset)
+			}
+		}
+
+		// apply sorting
+		for _, option := range builder.sortOptions {
+			query = option.Apply(query)
+		}
+		sortOptions = append(sortOptions, builder.sortOptions...)
+
+		if builder.limitPerParent != nil {
+			limitPerParent = builder.limitPerParent
+		}
+		if builder.partitionBy != "" {
+			partitionBy = builder.partitionBy
+		}
+
+		// apply options
+		for _, o := range builder.options {
+			o(options)
+		}
+
+		// collect relations to preload
+		options.preloads = append(options.preloads, builder.preloads...)
+	}
+
+	// keep at most limitPerParent rows for every parent row
+	if limitPerParent != nil {
+		if partitionBy == "" {
+			return nil, fmt.Errorf("limit per parent is only supported by relation loaders")
+		}
+		if paginated {
+			return nil, fmt.Errorf("limit per parent can't be combined with limit or offset")
+		}
+
+		var err error
+		query, err = limitPerParentQuery(t.queryBuilder, query, t.Columns(), partitionBy, *limitPerParent, sortOptions)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	sqlQuery, args, err := query.ToSql()
@@ -385,23 +546,40 @@ func (t *{{ storageName | lowerCamelCase }}) FindMany(ctx context.Context, build
 		return nil, fmt.Errorf("failed to iterate over rows: %w", err)
 	}
 
+	// preload relations
+	if len(results) > 0 && len(options.preloads) > 0 {
+		if err := t.preload(ctx, results, options.preloads); err != nil {
+			return nil, err
+		}
+	}
+
 	return results, nil
 }
 `
 
 const TableGetByIDMethodTemplate = `
-// FindBy{{ getPrimaryKey.GetName | camelCase }} retrieves a {{ structureName }} by its {{ getPrimaryKey.GetName }}.
-func (t *{{ storageName | lowerCamelCase }}) FindBy{{ getPrimaryKey.GetName | camelCase }}(ctx context.Context, id {{IDType}}, opts ...Option) (*{{ structureName }}, error) {
+// FindBy{{ primaryKeyName | camelCase }} retrieves a {{ structureName }} by its {{ primaryKeyName }}.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) FindBy{{ primaryKeyName | camelCase }}(ctx context.Context, id {{ keyType }}, opts ...Option) (*{{ structureName }}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.FindBy{{ primaryKeyName | camelCase }}")
+	res, err := t.doFindBy{{ primaryKeyName | camelCase }}(ctx, id, opts...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doFindBy{{ primaryKeyName | camelCase }} is FindBy{{ primaryKeyName | camelCase }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doFindBy{{ primaryKeyName | camelCase }}{{ else }}FindBy{{ primaryKeyName | camelCase }}{{ end }}(ctx context.Context, id {{ keyType }}, opts ...Option) (*{{ structureName }}, error) {
 	builder := NewQueryBuilder()
 	{
-		builder.WithFilter({{ messageName }}{{ getPrimaryKey.GetName | camelCase }}Eq(id))
+		builder.WithFilter({{ if (hasCompositePrimaryKey) }}{{ structureName }}KeyEq(id){{ else }}{{ messageName }}{{ getPrimaryKey.GetName | camelCase }}Eq(id){{ end }})
 		builder.WithOptions(opts...)
 	}
 	
 	// Use FindOne to get a single result
 	model, err := t.FindOne(ctx, builder)
 	if err != nil {
-		return nil, errors.Wrap(err, "find one {{ structureName }}: ")
+		return nil, fmt.Errorf("find one {{ structureName }}: %w", err)
 	}
 
 	return model, nil
@@ -411,43 +589,130 @@ func (t *{{ storageName | lowerCamelCase }}) FindBy{{ getPrimaryKey.GetName | ca
 const TableRawQueryMethodTemplate = `
 // Query executes a raw query and returns the result.
 func (t *{{ storageName | lowerCamelCase }}) Query(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	return t.DB(ctx).ExecContext(ctx, query, args...)
+	return t.DB(ctx, true).ExecContext(withOperation(ctx, OperationRaw), query, args...)
 }
 
 // QueryRow executes a raw query and returns the result.
 func (t *{{ storageName | lowerCamelCase }}) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	return t.DB(ctx).QueryRowContext(ctx, query, args...)
+	return t.DB(ctx, false).QueryRowContext(withOperation(ctx, OperationRaw), query, args...)
 }
 
 // QueryRows executes a raw query and returns the result.
 func (t *{{ storageName | lowerCamelCase }}) QueryRows(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
-	return t.DB(ctx).QueryContext(ctx, query, args...)
+	return t.DB(ctx, false).QueryContext(withOperation(ctx, OperationRaw), query, args...)
 }
 `
 
 const TableDeleteMethodTemplate = `
 {{- if (hasPrimaryKey) }}
-// DeleteBy{{ getPrimaryKey.GetName | camelCase }} - deletes a {{ structureName }} by its {{ getPrimaryKey.GetName }}.
-func (t *{{ storageName | lowerCamelCase }}) DeleteBy{{ getPrimaryKey.GetName | camelCase }}(ctx context.Context, {{getPrimaryKey.GetName}} {{IDType}}, opts ...Option) error {
-    query := t.queryBuilder.Delete("{{ tableName }}").Where("{{ getPrimaryKey.GetName }} = ?", {{getPrimaryKey.GetName}})
-    sqlQuery, args, err := query.ToSql()
-    if err != nil {
-        return fmt.Errorf("failed to build query: %w", err)
-    }
-    t.logQuery(ctx, sqlQuery, args...)
-    _, err = t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...)
-    if err != nil {
-        return fmt.Errorf("failed to delete {{ structureName }}: %w", err)
-    }
-    return nil
+// DeleteBy{{ primaryKeyName | camelCase }} - deletes a {{ structureName }} by its {{ primaryKeyName }} and returns the number of deleted rows.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) DeleteBy{{ primaryKeyName | camelCase }}(ctx context.Context, {{primaryKeyName}} {{ keyType }}, opts ...Option) (int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.DeleteBy{{ primaryKeyName | camelCase }}")
+	res, err := t.doDeleteBy{{ primaryKeyName | camelCase }}(ctx, {{primaryKeyName}}, opts...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doDeleteBy{{ primaryKeyName | camelCase }} is DeleteBy{{ primaryKeyName | camelCase }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doDeleteBy{{ primaryKeyName | camelCase }}{{ else }}DeleteBy{{ primaryKeyName | camelCase }}{{ end }}(ctx context.Context, {{primaryKeyName}} {{ keyType }}, opts ...Option) (int64, error) {
+	// set default options
+	options := &Options{}
+	for _, o := range opts {
+		o(options)
+	}
+
+	{{- if (hasCascadeDelete) }}
+
+	// delete the model and its cascading relations in one transaction
+	if _, ok := TxFromContext(ctx); !ok {
+		var deleted int64
+		err := t.config.txManager().ExecFuncWithTx(ctx, func(ctx context.Context) error {
+			var err error
+			deleted, err = t.DeleteBy{{ primaryKeyName | camelCase }}(ctx, {{primaryKeyName}}, opts...)
+			return err
+		})
+		return deleted, err
+	}
+	{{- range $index, $field := fields }}
+	{{- if ($field | relationCascadeDelete) }}
+
+	if err := t.delete{{ $field | fieldName }}(ctx, {{getPrimaryKey.GetName}}); err != nil {
+		return 0, err
+	}
+	{{- end }}
+	{{- end }}
+	{{- end }}
+
+	query := t.queryBuilder.Delete("{{ tableName }}").Where({{ if (hasCompositePrimaryKey) }}key.eq(){{ else }}"{{ getPrimaryKey.GetName }} = ?", {{getPrimaryKey.GetName}}{{ end }})
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	result, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete {{ structureName }}: %w", MapError(err))
+	}
+
+	return rowsAffected(result, options)
+}
+{{- range $index, $field := fields }}
+{{- if ($field | relationCascadeDelete) }}
+
+// delete{{ $field | fieldName }} deletes the {{ $field | fieldName }} of the {{ structureName }} with the given {{ getPrimaryKey.GetName }}.
+func (t *{{ storageName | lowerCamelCase }}) delete{{ $field | fieldName }}(ctx context.Context, id {{IDType}}) error {
+	s, err := New{{ $field | relationStorageName }}WithConfig(t.config)
+	if err != nil {
+		return fmt.Errorf("failed to create {{ $field | relationStorageName }}: %w", err)
+	}
+	{{- if and ($field | relationHasCascadeDelete) ($field | relationPrimaryKey) }}
+
+	// delete one by one, so the nested relations are deleted as well
+	items, err := s.FindMany(ctx, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}Eq({{ if ($field | isRefOptional) }}&{{ end }}id)))
+	if err != nil {
+		return fmt.Errorf("failed to find {{ $field | fieldName }}: %w", err)
+	}
+	for _, item := range items {
+		if _, err := s.DeleteBy{{ ($field | relationPrimaryKey).GetName | camelCase }}(ctx, item.{{ ($field | relationPrimaryKey).GetName | camelCase }}); err != nil {
+			return fmt.Errorf("failed to delete {{ $field | fieldName }}: %w", err)
+		}
+	}
+	{{- else }}
+
+	if _, err := s.DeleteMany(ctx, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}Eq({{ if ($field | isRefOptional) }}&{{ end }}id))); err != nil {
+		return fmt.Errorf("failed to delete {{ $field | fieldName }}: %w", err)
+	}
+	{{- end }}
+
+	return nil
 }
 {{- end }}
+{{- end }}
+{{- end }}
 
 // DeleteMany removes entries from the {{ tableName }} table using the provided filters
-func (t *{{ storageName | lowerCamelCase }}) DeleteMany(ctx context.Context, builders ...*QueryBuilder) error {
+// and returns the number of deleted rows.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) DeleteMany(ctx context.Context, builders ...*QueryBuilder) (int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.DeleteMany")
+	res, err := t.doDeleteMany(ctx, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doDeleteMany is DeleteMany without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doDeleteMany{{ else }}DeleteMany{{ end }}(ctx context.Context, builders ...*QueryBuilder) (int64, error) {
 	// build query
 	query := t.queryBuilder.Delete("{{ tableName }}")
 
+	// set default options
+	options := &Options{}
+
 	var withFilter bool
 	for _, builder := range builders {
 		if builder == nil {
@@ -459,23 +724,29 @@ func (t *{{ storageName | lowerCamelCase }}) DeleteMany(ctx context.Context, bui
 			query = option.ApplyDelete(query)
 			withFilter = true
 		}
+
+		// apply options
+		for _, o := range builder.options {
+			o(options)
+		}
 	}
 
 	if !withFilter {
-		return fmt.Errorf("filters are required for delete operation")
+		return 0, fmt.Errorf("filters are required for delete operation")
 	}
 
 	sqlQuery, args, err := query.ToSql()
 	if err != nil {
-		return fmt.Errorf("failed to build query: %w", err)
+		return 0, fmt.Errorf("failed to build query: %w", err)
 	}
+	t.logQuery(ctx, sqlQuery, args...)
 
-	_, err = t.DB(ctx).ExecContext(ctx, sqlQuery, args...)
+	result, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...)
 	if err != nil {
-		return fmt.Errorf("failed to delete Address: %w", err)
+		return 0, fmt.Errorf("failed to delete {{ tableName }}: %w", MapError(err))
 	}
-	
-	return nil
+
+	return rowsAffected(result, options)
 }
 `
 
@@ -491,15 +762,63 @@ type {{ structureName }}Update struct {
 	{{- end}}
 	{{- end}}
 	{{- end}}
+
+	{{- range $index, $field := fields }}
+	{{- if and ($field | isRelation) ($field | relationAllowSubCreating) ($field | relationPrimaryKey) }}
+		// {{ $field | fieldName }} replaces the related rows when updated with WithRelations, nil keeps them
+		{{ $field | fieldName }} {{ $field | fieldType }}
+	{{- end }}
+	{{- end }}
 }
 
-// Update updates an existing {{ structureName }} based on non-nil fields.
-func (t *{{ storageName | lowerCamelCase }}) Update(ctx context.Context, id {{IDType}}, updateData *{{structureName}}Update) error {
-	if updateData == nil {
-		return fmt.Errorf("update data is nil")
+// new{{ structureName }}Update returns an update that sets all columns of the model
+// except the automatic time fields.
+func new{{ structureName }}Update(model *{{structureName}}) *{{ structureName }}Update {
+	return &{{ structureName }}Update{
+	{{- range $index, $field := fields }}
+	{{- if not ($field | isRelation) }}
+	{{- if not ($field | isAutoIncrement) }}
+	{{- if not ($field | isPrimary) }}
+	{{- if not (or ($field | isAutoCreateTime) ($field | isAutoUpdateTime)) }}
+		{{ $field | fieldName }}: {{ if not ($field | findPointer) }}&{{ end }}model.{{ $field | fieldName }},
+	{{- end }}
+	{{- end }}
+	{{- end }}
+	{{- end }}
+	{{- end }}
+
+	{{- range $index, $field := fields }}
+	{{- if and ($field | isRelation) ($field | relationAllowSubCreating) ($field | relationPrimaryKey) }}
+		{{ $field | fieldName }}: model.{{ $field | fieldName }},
+	{{- end }}
+	{{- end }}
+	}
+}
+
+// isEmpty returns true if the {{ structureName }}Update does not set any column.
+func (u *{{ structureName }}Update) isEmpty() bool {
+	{{- range $index, $field := fields }}
+	{{- if not ($field | isRelation) }}
+	{{- if not ($field | isAutoIncrement) }}
+	{{- if not ($field | isPrimary) }}
+	if u.{{ $field | fieldName }} != nil {
+		return false
 	}
+	{{- end }}
+	{{- end }}
+	{{- end }}
+	{{- end }}
+	return true
+}
 
+// buildUpdateQuery builds the UPDATE statement for the non-nil fields of the {{ structureName }}Update.
+func (t *{{ storageName | lowerCamelCase }}) buildUpdateQuery(updateData *{{structureName}}Update) (sq.UpdateBuilder, error) {
 	query := t.queryBuilder.Update("{{ tableName }}")
+	{{- if (hasAutoUpdateTime) }}
+
+	// current time for automatic update time fields
+	now := t.now()
+	{{- end }}
 
 	{{- range $index, $field := fields }}
 	{{- if not ($field | isRelation) }}
@@ -509,128 +828,389 @@ func (t *{{ storageName | lowerCamelCase }}) Update(ctx context.Context, id {{ID
 		{{- if ($field | isRepeated) }}
 		value, err := updateData.{{ $field | fieldName }}.Value()
 		if err != nil {
-			return fmt.Errorf("failed to get value of {{ $field | fieldName }}: %w", err)
+			return query, fmt.Errorf("failed to get value of {{ $field | fieldName }}: %w", err)
 		}
-		query = query.Set("{{ $field | fieldName }}", value)
+		query = query.Set("{{ $field | sourceName }}", value)
 		{{- else }}
-		query = query.Set("{{ $field | fieldName }}", updateData.{{ $field | fieldName }})
+		query = query.Set("{{ $field | sourceName }}", updateData.{{ $field | fieldName }})
 		{{- end}}
-	}
+	} {{- if ($field | isAutoUpdateTime) }} else {
+		query = query.Set("{{ $field | sourceName }}", {{ $field | autoTimeValue }})
+	} {{- end }}
 	{{- end}}
 	{{- end}}
 	{{- end}}
 	{{- end}}
 
-	query = query.Where("id = ?", id)
+	return query, nil
+}
 
-	sqlQuery, args, err := query.ToSql()
-	if err != nil {
-		return fmt.Errorf("failed to build query: %w", err)
+// Update updates an existing {{ structureName }} based on non-nil fields and returns the number of updated rows.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Update(ctx context.Context, id {{ keyType }}, updateData *{{structureName}}Update, opts ...Option) (int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Update")
+	res, err := t.doUpdate(ctx, id, updateData, opts...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doUpdate is Update without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doUpdate{{ else }}Update{{ end }}(ctx context.Context, id {{ keyType }}, updateData *{{structureName}}Update, opts ...Option) (int64, error) {
+	if updateData == nil {
+		return 0, fmt.Errorf("update data is nil")
 	}
 
-	_, err = t.DB(ctx).ExecContext(ctx,sqlQuery, args...)
-	if err != nil {
-		return fmt.Errorf("failed to update {{ structureName }}: %w", err)
+	// set default options
+	options := &Options{}
+	for _, o := range opts {
+		o(options)
 	}
 
-	return nil
-}
-`
+	{{- if (hasChildRelations) }}
+
+	if options.relations {
+		// update the model and its relations in one transaction
+		if _, ok := TxFromContext(ctx); !ok {
+			var affected int64
+			err := t.config.txManager().ExecFuncWithTx(ctx, func(ctx context.Context) error {
+				var err error
+				affected, err = t.Update(ctx, id, updateData, opts...)
+				return err
+			})
+			return affected, err
+		}
 
-const StructureTemplate = `
-// {{ structureName }} is a struct for the "{{ tableName }}" table.
-type {{ structureName }} struct {
-{{ range $field := fields }}
-	{{ $field | fieldName }} {{ $field | fieldType }}{{if not ($field | isRelation) }}` + " `db:\"{{ $field | sourceName }}\"`" + `{{end}}{{end}}
-}
+		var affected int64
+		if !updateData.isEmpty() {
+			var err error
+			affected, err = t.update(ctx, id, updateData, options)
+			if err != nil {
+				return 0, err
+			}
+		}
+		{{- range $index, $field := fields }}
+		{{- if and ($field | isRelation) ($field | relationAllowSubCreating) ($field | relationPrimaryKey) }}
 
-// TableName returns the table name.
-func (t *{{ structureName }}) TableName() string {
-	return "{{ tableName }}"
-}
+		if updateData.{{ $field | fieldName }} != nil {
+			{{- if ($field | isRepeated) }}
+			if err := t.update{{ $field | fieldName }}(ctx, id, updateData.{{ $field | fieldName }}); err != nil {
+			{{- else }}
+			if err := t.update{{ $field | fieldName }}(ctx, id, []*{{ $field | relationStructureName }}{updateData.{{ $field | fieldName }}}); err != nil {
+			{{- end }}
+				return 0, err
+			}
+		}
+		{{- end }}
+		{{- end }}
 
-// ScanRow scans a row into a {{ structureName }}.
-func (t *{{ structureName }}) ScanRow(r *sql.Row) error {
-	return r.Scan({{ range $field := fields }} {{if not ($field | isRelation) }} &t.{{ $field | fieldName }}, {{ end }}{{ end }})
-}
+		return affected, nil
+	}
+	{{- end }}
 
-// ScanRows scans a single row into the {{ structureName }}.
-func (t *{{ structureName }}) ScanRows(r *sql.Rows) error {
-	return r.Scan(
-		{{- range $index, $field := fields }}
-		{{- if not ($field | isRelation) }}
-		&t.{{ $field | fieldName }},
-		{{- end}}
-		{{- end }}
-	)
+	return t.update(ctx, id, updateData, options)
 }
-`
 
-const TableCreateMethodTemplate = `
-// Create creates a new {{ structureName }}.
-{{ if (hasID) }} func (t *{{ storageName | lowerCamelCase }}) Create(ctx context.Context, model *{{structureName}}, opts ...Option) (*{{IDType}}, error) { {{ else }} func (t *{{ storageName | lowerCamelCase }}) Create(ctx context.Context, model *{{structureName}}, opts ...Option) error { {{ end }}
-	if model == nil {
-		{{ if (hasID) }}return nil, fmt.Errorf("model is nil") {{ else }}return fmt.Errorf("model is nil") {{ end }}
+// update runs the UPDATE statement of the {{ structureName }} with the given {{ primaryKeyName }}.
+func (t *{{ storageName | lowerCamelCase }}) update(ctx context.Context, id {{ keyType }}, updateData *{{structureName}}Update, options *Options) (int64, error) {
+	query, err := t.buildUpdateQuery(updateData)
+	if err != nil {
+		return 0, err
 	}
 
-	{{- range $index, $field := fields }}
-	{{- if and ($field | isUUID) ($field | isPrimaryKey) (not ($field | isAutoIncrement)) }}
-	if model.{{ $field | fieldName }} == "" {
-		uuidStr, err := uuid.NewUUID()
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate uuid for {{ structureName }}: %w", err)
-		}
+	query = query.Where({{ if (hasCompositePrimaryKey) }}id.eq(){{ else }}"{{ getPrimaryKey.GetName }} = ?", id{{ end }})
 
-		model.{{ $field | fieldName }} = uuidStr.String()
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build query: %w", err)
 	}
-	{{- end}}
-	{{- end}}
+	t.logQuery(ctx, sqlQuery, args...)
 
-	// set default options
-	options := &Options{}
-	for _, o := range opts {
-		o(options)
+	result, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update {{ structureName }}: %w", MapError(err))
 	}
 
-	{{- range $index, $field := fields }}
-	{{- if not ($field | isRelation) }}
-	{{- if ($field | isRepeated) }}
-	// get value of {{ $field | fieldName | lowerCamelCase }}
-	{{ $field | fieldName | lowerCamelCase }}, err := model.{{ $field | fieldName }}.Value()
+	return rowsAffected(result, options)
+}
+{{- range $index, $field := fields }}
+{{- if and ($field | isRelation) ($field | relationAllowSubCreating) ($field | relationPrimaryKey) }}
+
+// update{{ $field | fieldName }} replaces the {{ $field | fieldName }} of the {{ structureName }} with the given items.
+// Known items are updated, new items are created and the missing ones are deleted. The keys of the created
+// items are set on them. An item with the key of a row of another {{ structureName }} returns ErrForeignChild.
+func (t *{{ storageName | lowerCamelCase }}) update{{ $field | fieldName }}(ctx context.Context, id {{IDType}}, items []*{{ $field | relationStructureName }}) error {
+	s, err := New{{ $field | relationStorageName }}WithConfig(t.config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get value of {{ $field | fieldName | lowerCamelCase }}: %w", err)
+		return fmt.Errorf("failed to create {{ $field | relationStorageName }}: %w", err)
 	}
-	{{- end}}
-	{{- end}}
-	{{- end}}
 
-	query := t.queryBuilder.Insert("{{ tableName }}").
-		Columns(
-			{{- range $index, $field := fields }}
-			{{- if not ($field | isRelation) }}
-			{{- if not ($field | isAutoIncrement ) }}
-			{{- if not ($field | isDefaultUUID ) }}
-			"{{ $field | sourceName }}",
-			{{- end}}
-			{{- end}}
-			{{- end}}
-			{{- end}}
-		).
-		Values(
-			{{- range $index, $field := fields }}
-			{{- if not ($field | isRelation) }}
-			{{- if not ($field | isAutoIncrement ) }}
-			{{- if not ($field | isDefaultUUID ) }}
-			{{- if ($field | isRepeated) }}
-			{{ $field | fieldName | lowerCamelCase }},
+	existing, err := s.FindMany(ctx, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}Eq({{ if ($field | isRefOptional) }}&{{ end }}id)))
+	if err != nil {
+		return fmt.Errorf("failed to find {{ $field | fieldName }}: %w", err)
+	}
+
+	known := make(map[{{ ($field | relationPrimaryKey) | fieldType }}]bool, len(existing))
+	for _, item := range existing {
+		known[item.{{ ($field | relationPrimaryKey).GetName | camelCase }}] = true
+	}
+
+	var newKey {{ ($field | relationPrimaryKey) | fieldType }}
+
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		item.{{ $field | getRefID }} = {{ if ($field | isRefOptional) }}&{{ end }}id
+
+		if known[item.{{ ($field | relationPrimaryKey).GetName | camelCase }}] {
+			delete(known, item.{{ ($field | relationPrimaryKey).GetName | camelCase }})
+			if _, err := s.Update(ctx, item.{{ ($field | relationPrimaryKey).GetName | camelCase }}, new{{ $field | relationStructureName }}Update(item), WithRelations()); err != nil {
+				return fmt.Errorf("failed to update {{ $field | fieldName }}: %w", err)
+			}
+			continue
+		}
+
+		if item.{{ ($field | relationPrimaryKey).GetName | camelCase }} != newKey {
+			{{- if or (($field | relationPrimaryKey) | isAutoIncrement) (($field | relationPrimaryKey) | isDefaultUUID) }}
+			// the keys of the new items are generated by the database
+			return fmt.Errorf("{{ $field | fieldName }} %v: %w", item.{{ ($field | relationPrimaryKey).GetName | camelCase }}, ErrForeignChild)
 			{{- else }}
-			model.{{ $field | fieldName }},
-			{{- end}}
-			{{- end}}
-			{{- end}}
-			{{- end}}
-			{{- end}}
-		)
+			if _, err := s.FindBy{{ ($field | relationPrimaryKey).GetName | camelCase }}(ctx, item.{{ ($field | relationPrimaryKey).GetName | camelCase }}); err == nil {
+				return fmt.Errorf("{{ $field | fieldName }} %v: %w", item.{{ ($field | relationPrimaryKey).GetName | camelCase }}, ErrForeignChild)
+			} else if !errors.Is(err, ErrRowNotFound) {
+				return fmt.Errorf("failed to find {{ $field | fieldName }}: %w", err)
+			}
+			{{- end }}
+		}
+		{{- if ($field | hasIDFromRelation) }}
+
+		created, err := s.Create(ctx, item, WithRelations())
+		if err != nil {
+			return fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err)
+		}
+		if created != nil {
+			item.{{ ($field | relationPrimaryKey).GetName | camelCase }} = *created
+		}
+		{{- else }}
+
+		if err := s.Create(ctx, item, WithRelations()); err != nil {
+			return fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err)
+		}
+		{{- end }}
+	}
+
+	// delete the rows which are not in the items anymore
+	for key := range known {
+		if _, err := s.DeleteBy{{ ($field | relationPrimaryKey).GetName | camelCase }}(ctx, key); err != nil {
+			return fmt.Errorf("failed to delete {{ $field | fieldName }}: %w", err)
+		}
+	}
+
+	return nil
+}
+{{- end }}
+{{- end }}
+
+// UpdateMany updates all {{ structureName }} matching the provided filters based on non-nil fields
+// and returns the number of updated rows.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) UpdateMany(ctx context.Context, updateData *{{structureName}}Update, builders ...*QueryBuilder) (int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.UpdateMany")
+	res, err := t.doUpdateMany(ctx, updateData, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doUpdateMany is UpdateMany without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doUpdateMany{{ else }}UpdateMany{{ end }}(ctx context.Context, updateData *{{structureName}}Update, builders ...*QueryBuilder) (int64, error) {
+	if updateData == nil {
+		return 0, fmt.Errorf("update data is nil")
+	}
+
+	query, err := t.buildUpdateQuery(updateData)
+	if err != nil {
+		return 0, err
+	}
+
+	// set default options
+	options := &Options{}
+
+	var withFilter bool
+	for _, builder := range builders {
+		if builder == nil {
+			continue
+		}
+
+		// apply filter options
+		for _, option := range builder.filterOptions {
+			// extract WHERE clause from the filter
+			whereParts, args, err := option.Apply(sq.Select("*")).ToSql()
+			if err != nil {
+				return 0, fmt.Errorf("failed to build filter: %w", err)
+			}
+			query = query.Where(strings.TrimPrefix(whereParts, "SELECT * WHERE "), args...)
+			withFilter = true
+		}
+
+		// apply options
+		for _, o := range builder.options {
+			o(options)
+		}
+	}
+
+	if !withFilter {
+		return 0, fmt.Errorf("filters are required for update operation")
+	}
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	result, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update {{ tableName }}: %w", MapError(err))
+	}
+
+	return rowsAffected(result, options)
+}
+`
+
+const StructureTemplate = `
+// {{ structureName }} is a struct for the "{{ tableName }}" table.
+type {{ structureName }} struct {
+{{ range $field := fields }}
+	{{ $field | fieldName }} {{ $field | fieldType }}{{if not ($field | isRelation) }}` + " `db:\"{{ $field | sourceName }}\"`" + `{{end}}{{end}}
+}
+
+// TableName returns the table name.
+func (t *{{ structureName }}) TableName() string {
+	return "{{ tableName }}"
+}
+
+// ScanRow scans a row into a {{ structureName }}.
+func (t *{{ structureName }}) ScanRow(r *sql.Row) error {
+	return r.Scan({{ range $field := fields }} {{if not ($field | isRelation) }} &t.{{ $field | fieldName }}, {{ end }}{{ end }})
+}
+
+// ScanRows scans a single row into the {{ structureName }}.
+func (t *{{ structureName }}) ScanRows(r *sql.Rows) error {
+	return r.Scan(
+		{{- range $index, $field := fields }}
+		{{- if not ($field | isRelation) }}
+		&t.{{ $field | fieldName }},
+		{{- end}}
+		{{- end }}
+	)
+}
+{{- if (hasCompositePrimaryKey) }}
+
+// {{ structureName }}Key is the composite primary key of the "{{ tableName }}" table.
+type {{ structureName }}Key struct {
+{{- range $field := getPrimaryKeys }}
+	{{ $field | fieldName }} {{ $field | fieldType }}
+{{- end }}
+}
+
+// Key returns the composite primary key of the {{ structureName }}.
+func (t *{{ structureName }}) Key() {{ structureName }}Key {
+	return {{ structureName }}Key{
+	{{- range $field := getPrimaryKeys }}
+		{{ $field | fieldName }}: t.{{ $field | fieldName }},
+	{{- end }}
+	}
+}
+
+// eq returns the columns of the key with their values.
+func (k {{ structureName }}Key) eq() sq.Eq {
+	return sq.Eq{
+	{{- range $field := getPrimaryKeys }}
+		"{{ $field | sourceName }}": k.{{ $field | fieldName }},
+	{{- end }}
+	}
+}
+
+// {{ structureName }}KeyEq returns a condition that matches the row with the given key.
+func {{ structureName }}KeyEq(key {{ structureName }}Key) FilterApplier {
+	return And(
+	{{- range $field := getPrimaryKeys }}
+		Eq("{{ $field | sourceName }}", key.{{ $field | fieldName }}),
+	{{- end }}
+	)
+}
+{{- end }}
+`
+
+const TableCreateMethodTemplate = `
+// Create creates a new {{ structureName }}.
+{{- if otel }}
+{{ if (hasID) }}func (t *{{ storageName | lowerCamelCase }}) Create(ctx context.Context, model *{{structureName}}, opts ...Option) (*{{IDType}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Create")
+	res, err := t.doCreate(ctx, model, opts...)
+	endSpan(span, err)
+	return res, err
+}{{ else }}func (t *{{ storageName | lowerCamelCase }}) Create(ctx context.Context, model *{{structureName}}, opts ...Option) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Create")
+	err := t.doCreate(ctx, model, opts...)
+	endSpan(span, err)
+	return err
+}{{ end }}
+
+// doCreate is Create without its span.
+{{- end }}
+{{ if (hasID) }} func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doCreate{{ else }}Create{{ end }}(ctx context.Context, model *{{structureName}}, opts ...Option) (*{{IDType}}, error) { {{ else }} func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doCreate{{ else }}Create{{ end }}(ctx context.Context, model *{{structureName}}, opts ...Option) error { {{ end }}
+	if model == nil {
+		{{ if (hasID) }}return nil, fmt.Errorf("model is nil") {{ else }}return fmt.Errorf("model is nil") {{ end }}
+	}
+
+	{{- range $index, $field := fields }}
+	{{- if and ($field | isUUID) ($field | isPrimaryKey) (not ($field | isAutoIncrement)) }}
+	if model.{{ $field | fieldName }} == "" {
+		uuidStr, err := uuid.NewUUID()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate uuid for {{ structureName }}: %w", err)
+		}
+
+		model.{{ $field | fieldName }} = uuidStr.String()
+	}
+	{{- end}}
+	{{- end}}
+
+	// set default options
+	options := &Options{}
+	for _, o := range opts {
+		o(options)
+	}
+
+	{{- if and (hasID) (hasChildRelations) }}
+
+	// create the model and its relations in one transaction
+	if options.relations {
+		if _, ok := TxFromContext(ctx); !ok {
+			var id *{{IDType}}
+			err := t.config.txManager().ExecFuncWithTx(ctx, func(ctx context.Context) error {
+				var err error
+				id, err = t.Create(ctx, model, opts...)
+				return err
+			})
+			return id, err
+		}
+	}
+	{{- end }}
+
+	{{- if (hasAutoTime) }}
+
+	// fill automatic time fields
+	t.fillAutoTime(model)
+	{{- end }}
+
+	query, err := t.insertQuery(model)
+	if err != nil {
+		{{ if (hasID) }} return nil, err {{ else }} return err {{ end }}
+	}
 	{{ if (hasID) }}
 		// add RETURNING "id" to query
 		query = query.Suffix("RETURNING \"id\"")
@@ -642,9 +1222,9 @@ const TableCreateMethodTemplate = `
 	}
 
 	{{ if (hasID) }}var id {{IDType}}
-	err = t.DB(ctx).QueryRowContext(ctx,sqlQuery, args...).Scan(&id) {{ else }} _, err = t.DB(ctx).ExecContext(ctx,sqlQuery, args...) {{ end }}
+	err = t.DB(ctx, true).QueryRowContext(ctx,sqlQuery, args...).Scan(&id) {{ else }} _, err = t.DB(ctx, true).ExecContext(ctx,sqlQuery, args...) {{ end }}
 	if err != nil {
-		{{ if (hasID) }} return nil, fmt.Errorf("failed to create {{ structureName }}: %w", err) {{ else }} return fmt.Errorf("failed to create {{ structureName }}: %w", err) {{ end }}
+		{{ if (hasID) }} return nil, fmt.Errorf("failed to create {{ structureName }}: %w", MapError(err)) {{ else }} return fmt.Errorf("failed to create {{ structureName }}: %w", MapError(err)) {{ end }}
 	}
 
 	{{ if (hasID) }}
@@ -652,16 +1232,24 @@ const TableCreateMethodTemplate = `
 	{{- if and ($field | isRelation) ($field | relationAllowSubCreating) }}
 	    if options.relations && model.{{ $field | fieldName }} != nil { {{ if ($field | isRepeated) }}
 			for _, item := range model.{{ $field | fieldName }} {
-				item.{{ $field | getRefID }} = id
-				s := New{{ $field | relationStorageName }}(t.db)
-                {{ if ($field | hasIDFromRelation) }} _, err := s.Create(ctx, item) {{ else }} err := s.Create(ctx, item) {{ end }}
+				item.{{ $field | getRefID }} = {{ if ($field | isRefOptional) }}&{{ end }}id
+				s, err := New{{ $field | relationStorageName }}WithConfig(t.config)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err)
+				}
+
+                {{ if ($field | hasIDFromRelation) }} _, err = s.Create(ctx, item, WithRelations()) {{ else }} err = s.Create(ctx, item, WithRelations()) {{ end }}
 				if err != nil {
 				{{ if (hasID) }} return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ else }} return fmt.Errorf("failed to create {{ structureName }}: %w", err) {{ end }}
 				}
 			} {{ else }}
-			s := New{{ $field | relationStorageName }}(t.db)
-			model.{{ $field | fieldName }}.{{ $field | getRefID }} = id
-			{{ if ($field | hasIDFromRelation) }} _, err := s.Create(ctx, model.{{ $field | fieldName }}) {{ else }} err := s.Create(ctx, model.{{ $field | fieldName }}) {{ end }}
+			s, err := New{{ $field | relationStorageName }}WithConfig(t.config)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err)
+			}
+
+			model.{{ $field | fieldName }}.{{ $field | getRefID }} = {{ if ($field | isRefOptional) }}&{{ end }}id
+			{{ if ($field | hasIDFromRelation) }} _, err = s.Create(ctx, model.{{ $field | fieldName }}, WithRelations()) {{ else }} err = s.Create(ctx, model.{{ $field | fieldName }}, WithRelations()) {{ end }}
 			if err != nil {
 				{{ if (hasID) }} return nil, fmt.Errorf("failed to create {{ $field | fieldName }}: %w", err) {{ else }} return fmt.Errorf("failed to create {{ structureName }}: %w", err) {{ end }}
 			} {{- end}}
@@ -671,48 +1259,345 @@ const TableCreateMethodTemplate = `
 
 	{{ if (hasID) }} return &id, nil {{ else }} return nil {{ end }}
 }
-`
 
-const TableStorageTemplate = `
-// {{ storageName | lowerCamelCase }} is a struct for the "{{ tableName }}" table.
-type {{ storageName | lowerCamelCase }} struct {
-	db *sql.DB // The database connection.
-	queryBuilder sq.StatementBuilderType // queryBuilder is used to build queries.
-}
+// insertQuery builds the INSERT statement for the {{ structureName }}.
+func (t *{{ storageName | lowerCamelCase }}) insertQuery(model *{{structureName}}) (sq.InsertBuilder, error) {
 
-// {{structureName}}TableManager is an interface for managing the {{ tableName }} table.
-type {{structureName}}TableManager interface {
-	CreateTable(ctx context.Context) error
-	DropTable(ctx context.Context) error
-	TruncateTable(ctx context.Context) error
-	UpgradeTable(ctx context.Context) error
-}
+	{{- range $index, $field := fields }}
+	{{- if not ($field | isRelation) }}
+	{{- if ($field | isRepeated) }}
+	// get value of {{ $field | fieldName | lowerCamelCase }}
+	{{ $field | fieldName | lowerCamelCase }}, err := model.{{ $field | fieldName }}.Value()
+	if err != nil {
+		return sq.InsertBuilder{}, fmt.Errorf("failed to get value of {{ $field | fieldName | lowerCamelCase }}: %w", err)
+	}
+	{{- end}}
+	{{- end}}
+	{{- end}}
 
-// {{structureName}}CRUDOperations is an interface for managing the {{ tableName }} table.
-type {{structureName}}CRUDOperations interface {
-	{{- if (hasID) }}
-	Create(ctx context.Context, model *{{structureName}}, opts ...Option) (*{{IDType}}, error)
-	{{- else }} 
-	Create(ctx context.Context, model *{{structureName}}, opts ...Option) error
-	{{- end }}
-	Update(ctx context.Context, id {{IDType}}, updateData *{{structureName}}Update) error
-	{{- if (hasPrimaryKey) }}
-	DeleteBy{{ getPrimaryKey.GetName | camelCase }}(ctx context.Context, {{getPrimaryKey.GetName}} {{IDType}}, opts ...Option) error
-	{{- end }}
-	{{- if (hasPrimaryKey) }}
-	FindBy{{ getPrimaryKey.GetName | camelCase }}(ctx context.Context, id {{IDType}}, opts ...Option) (*{{ structureName }}, error)
-	{{- end }}
-}
+	query := t.queryBuilder.Insert("{{ tableName }}").
+		Columns(
+			{{- range $index, $field := fields }}
+			{{- if not ($field | isRelation) }}
+			{{- if not ($field | isAutoIncrement ) }}
+			{{- if not ($field | isDefaultUUID ) }}
+			"{{ $field | sourceName }}",
+			{{- end}}
+			{{- end}}
+			{{- end}}
+			{{- end}}
+		).
+		Values(
+			{{- range $index, $field := fields }}
+			{{- if not ($field | isRelation) }}
+			{{- if not ($field | isAutoIncrement ) }}
+			{{- if not ($field | isDefaultUUID ) }}
+			{{- if ($field | isRepeated) }}
+			{{ $field | fieldName | lowerCamelCase }},
+			{{- else }}
+			model.{{ $field | fieldName }},
+			{{- end}}
+			{{- end}}
+			{{- end}}
+			{{- end}}
+			{{- end}}
+		)
 
-// {{structureName}}SearchOperations is an interface for searching the {{ tableName }} table.
-type {{structureName}}SearchOperations interface {
-	FindMany(ctx context.Context, builder ...*QueryBuilder) ([]*{{structureName}}, error)
-	FindOne(ctx context.Context, builders ...*QueryBuilder) (*{{structureName}}, error)
-	Count(ctx context.Context, builders ...*QueryBuilder) (int64, error)
-	SelectForUpdate(ctx context.Context, builders ...*QueryBuilder) (*{{structureName}}, error)
+
+	return query, nil
 }
+`
 
-// {{structureName}}PaginationOperations is an interface for pagination operations.
+const TableBatchUpsertMethodTemplate = `
+{{- range $index, $fields := getStructureUniqueIndexes }}
+// {{ structureName }}UniqueIndex{{ $fields | sliceToString | camelCase }} is the conflict target of the unique index on ({{ $fields | conflictColumns }}).
+// Pass it to WithIgnoreConflictField to upsert by this index.
+const {{ structureName }}UniqueIndex{{ $fields | sliceToString | camelCase }} = "{{ $fields | conflictColumns }}"
+{{- end }}
+
+// BatchUpsert creates multiple {{ structureName }} records or updates the existing ones on conflict.
+{{- if defaultConflictTarget }}
+// The conflict target is ({{ defaultConflictTarget }}) unless set with WithIgnoreConflictField.
+{{- else }}
+// The conflict target must be set with WithIgnoreConflictField.
+{{- end }}
+// Large batches are split into chunks below the bind parameter limit and executed in one transaction.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) BatchUpsert(ctx context.Context, models []*{{structureName}}, updateFields []string, opts ...Option) ({{ if (hasID) }}[]string, {{ end }}error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.BatchUpsert")
+	{{ if (hasID) }}ids, {{ end }}err := t.doBatchUpsert(ctx, models, updateFields, opts...)
+	endSpan(span, err)
+	return {{ if (hasID) }}ids, {{ end }}err
+}
+
+// doBatchUpsert is BatchUpsert without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doBatchUpsert{{ else }}BatchUpsert{{ end }}(ctx context.Context, models []*{{structureName}}, updateFields []string, opts ...Option) ({{ if (hasID) }}[]string, {{ end }}error) {
+	if len(models) == 0 {
+		{{ if (hasID) }} return nil, fmt.Errorf("no models to upsert") {{ else }} return fmt.Errorf("no models to upsert") {{ end }}
+	}
+
+	options := &Options{}
+	for _, o := range opts {
+		o(options)
+	}
+
+	if options.relations {
+		{{ if (hasID) }} return nil, fmt.Errorf("relations are not supported in batch upsert") {{ else }} return fmt.Errorf("relations are not supported in batch upsert") {{ end }}
+	}
+
+	conflictTarget := "{{ defaultConflictTarget }}"
+	if options.ignoreConflictField != "" {
+		conflictTarget = options.ignoreConflictField
+	}
+	if conflictTarget == "" {
+		{{ if (hasID) }} return nil, fmt.Errorf("conflict target is required for batch upsert") {{ else }} return fmt.Errorf("conflict target is required for batch upsert") {{ end }}
+	}
+
+	suffix := "ON CONFLICT (" + conflictTarget + ") DO UPDATE SET " + t.upsertSet(updateFields)
+	{{- if (hasID) }}
+	suffix += " RETURNING \"{{ getPrimaryKey.GetName }}\""
+	{{- end }}
+
+	columns := []string{
+		{{- range $index, $field := fields }}
+		{{- if not ($field | isRelation) }}
+		{{- if not ($field | isAutoIncrement ) }}
+		{{- if not ($field | isDefaultUUID ) }}
+		"{{ $field | sourceName }}",
+		{{- end}}
+		{{- end}}
+		{{- end}}
+		{{- end}}
+	}
+
+	// every row binds one parameter per column
+	chunkSize := maxBindParams / len(columns)
+
+	{{ if (hasID) }}var returnIDs []string{{ end }}
+	upsert := func(ctx context.Context) error {
+		for start := 0; start < len(models); start += chunkSize {
+			end := start + chunkSize
+			if end > len(models) {
+				end = len(models)
+			}
+
+			{{ if (hasID) }}ids, err := t.batchUpsertChunk(ctx, models[start:end], columns, suffix){{ else }}err := t.batchUpsertChunk(ctx, models[start:end], columns, suffix){{ end }}
+			if err != nil {
+				return err
+			}
+			{{- if (hasID) }}
+			returnIDs = append(returnIDs, ids...)
+			{{- end }}
+		}
+		return nil
+	}
+
+	var err error
+	if len(models) <= chunkSize {
+		err = upsert(ctx)
+	} else {
+		// keep the chunks atomic
+		err = t.config.txManager().ExecFuncWithTx(ctx, upsert)
+	}
+	if err != nil {
+		{{ if (hasID) }} return nil, err {{ else }} return err {{ end }}
+	}
+
+	return {{ if (hasID) }} returnIDs, nil {{ else }} nil {{ end }}
+}
+
+// batchUpsertChunk upserts a single chunk of {{ structureName }} records.
+func (t *{{ storageName | lowerCamelCase }}) batchUpsertChunk(ctx context.Context, models []*{{structureName}}, columns []string, suffix string) ({{ if (hasID) }}[]string, {{ end }}error) {
+	query := t.queryBuilder.Insert("{{ tableName }}").Columns(columns...)
+
+	for _, model := range models {
+		if model == nil {
+			{{ if (hasID) }} return nil, fmt.Errorf("one of the models is nil") {{ else }} return fmt.Errorf("one of the models is nil") {{ end }}
+		}
+
+		{{- range $index, $field := fields }}
+		{{- if and ($field | isUUID) ($field | isPrimaryKey) (not ($field | isAutoIncrement)) }}
+		if model.{{ $field | fieldName }} == "" {
+			uuidStr, err := uuid.NewUUID()
+			if err != nil {
+				{{ if (hasID) }} return nil, fmt.Errorf("failed to generate uuid for {{ structureName }}: %w", err) {{ else }} return fmt.Errorf("failed to generate uuid for {{ structureName }}: %w", err) {{ end }}
+			}
+
+			model.{{ $field | fieldName }} = uuidStr.String()
+		}
+		{{- end}}
+		{{- end}}
+
+		{{- if (hasAutoTime) }}
+
+		// fill automatic time fields
+		t.fillAutoTime(model)
+		{{- end }}
+		{{- if (hasAutoUpdateTime) }}
+		t.touchAutoUpdateTime(model)
+		{{- end }}
+
+		{{- range $index, $field := fields }}
+		{{- if not ($field | isRelation) }}
+		{{- if ($field | isRepeated) }}
+		// get value of {{ $field | fieldName | lowerCamelCase }}
+		{{ $field | fieldName | lowerCamelCase }}, err := model.{{ $field | fieldName }}.Value()
+		if err != nil {
+			{{ if (hasID) }} return nil, fmt.Errorf("failed to get value of {{ $field | fieldName }}: %w", err) {{ else }} return fmt.Errorf("failed to get value of {{ $field | fieldName }}: %w", err) {{ end }}
+		}
+		{{- end}}
+		{{- end}}
+		{{- end}}
+
+		query = query.Values(
+			{{- range $index, $field := fields }}
+			{{- if not ($field | isRelation) }}
+			{{- if not ($field | isAutoIncrement ) }}
+			{{- if not ($field | isDefaultUUID ) }}
+			{{- if ($field | isRepeated) }}
+			{{ $field | fieldName | lowerCamelCase }},
+			{{- else }}
+			model.{{ $field | fieldName }},
+			{{- end}}
+			{{- end}}
+			{{- end}}
+			{{- end}}
+			{{- end}}
+		)
+	}
+	query = query.Suffix(suffix)
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		{{ if (hasID) }} return nil, fmt.Errorf("failed to build query: %w", err) {{ else }} return fmt.Errorf("failed to build query: %w", err) {{ end }}
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	{{ if (hasID) }}
+	rows, err := t.DB(ctx, true).QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute batch upsert: %w", MapError(err))
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			t.logError(ctx, err, "failed to close rows")
+		}
+	}()
+
+	var returnIDs []string
+	for rows.Next() {
+		var {{ getPrimaryKey.GetName | lowerCamelCase }} string
+		if err := rows.Scan(&{{ getPrimaryKey.GetName | lowerCamelCase }}); err != nil {
+			return nil, fmt.Errorf("failed to scan {{ getPrimaryKey.GetName }}: %w", err)
+		}
+		returnIDs = append(returnIDs, {{ getPrimaryKey.GetName | lowerCamelCase }})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", MapError(err))
+	}
+
+	return returnIDs, nil
+	{{- else }}
+	if _, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...); err != nil {
+		return fmt.Errorf("failed to execute batch upsert: %w", MapError(err))
+	}
+
+	return nil
+	{{- end }}
+}
+
+// upsertSet builds the UPDATE SET clause of an upsert from the given fields.
+func (t *{{ storageName | lowerCamelCase }}) upsertSet(updateFields []string) string {
+	updateSet := make([]string, 0, len(updateFields))
+	for _, field := range updateFields {
+		{{- range $index, $field := fields }}
+		{{- if not ($field | isRelation) }}
+		{{- if not ($field | isAutoIncrement ) }}
+		{{- if not ($field | isPrimaryKey ) }}
+		{{- if not ($field | isAutoUpdateTime ) }}
+		if field == "{{ $field | sourceName }}" {
+			updateSet = append(updateSet, "{{ $field | sourceName }} = excluded.{{ $field | sourceName }}")
+		}
+		{{- end}}
+		{{- end}}
+		{{- end}}
+		{{- end}}
+		{{- end}}
+	}
+
+	{{- range $index, $field := fields }}
+	{{- if ($field | isAutoUpdateTime) }}
+	// {{ $field | sourceName }} is refreshed automatically on conflict
+	updateSet = append(updateSet, "{{ $field | sourceName }} = excluded.{{ $field | sourceName }}")
+	{{- end }}
+	{{- end }}
+
+	if len(updateSet) == 0 {
+		// Default update field to ensure ON CONFLICT is not empty (sqlite requires at least one field)
+		{{- $firstField := false }}
+		{{- range $index, $field := fields }}
+		{{- if and (not ($field | isRelation)) (not ($field | isAutoIncrement)) (not ($field | isPrimaryKey)) (not $firstField) }}
+		{{- $firstField = true }}
+		updateSet = append(updateSet, "{{ $field | sourceName }} = excluded.{{ $field | sourceName }}")
+		{{- end }}
+		{{- end }}
+	}
+
+	return strings.Join(updateSet, ", ")
+}
+`
+
+const TableStorageTemplate = `
+// {{ storageName | lowerCamelCase }} is a struct for the "{{ tableName }}" table.
+type {{ storageName | lowerCamelCase }} struct {
+	config *Config // configuration for the storage.
+	queryBuilder sq.StatementBuilderType // queryBuilder is used to build queries.
+}
+
+// {{structureName}}TableManager is an interface for managing the {{ tableName }} table.
+type {{structureName}}TableManager interface {
+	CreateTable(ctx context.Context) error
+	DropTable(ctx context.Context) error
+	TruncateTable(ctx context.Context) error
+	UpgradeTable(ctx context.Context) error
+}
+
+// {{structureName}}CRUDOperations is an interface for managing the {{ tableName }} table.
+type {{structureName}}CRUDOperations interface {
+	{{- if (hasID) }}
+	Create(ctx context.Context, model *{{structureName}}, opts ...Option) (*{{IDType}}, error)
+	{{- else }} 
+	Create(ctx context.Context, model *{{structureName}}, opts ...Option) error
+	{{- end }}
+	{{- if (hasID) }}
+	BatchUpsert(ctx context.Context, models []*{{structureName}}, updateFields []string, opts ...Option) ([]string, error)
+	{{- else }}
+	BatchUpsert(ctx context.Context, models []*{{structureName}}, updateFields []string, opts ...Option) error
+	{{- end }}
+	Update(ctx context.Context, id {{ keyType }}, updateData *{{structureName}}Update, opts ...Option) (int64, error)
+	CreateReturning(ctx context.Context, model *{{structureName}}, opts ...Option) (*{{structureName}}, error)
+	UpsertReturning(ctx context.Context, model *{{structureName}}, updateFields []string, opts ...Option) (*{{structureName}}, error)
+	UpdateReturning(ctx context.Context, id {{ keyType }}, updateData *{{structureName}}Update) (*{{structureName}}, error)
+	UpdateMany(ctx context.Context, updateData *{{structureName}}Update, builders ...*QueryBuilder) (int64, error)
+	{{- if (hasPrimaryKey) }}
+	DeleteBy{{ primaryKeyName | camelCase }}(ctx context.Context, {{primaryKeyName}} {{ keyType }}, opts ...Option) (int64, error)
+	{{- end }}
+	{{- if (hasPrimaryKey) }}
+	FindBy{{ primaryKeyName | camelCase }}(ctx context.Context, id {{ keyType }}, opts ...Option) (*{{ structureName }}, error)
+	{{- end }}
+}
+
+// {{structureName}}SearchOperations is an interface for searching the {{ tableName }} table.
+type {{structureName}}SearchOperations interface {
+	FindMany(ctx context.Context, builder ...*QueryBuilder) ([]*{{structureName}}, error)
+	FindOne(ctx context.Context, builders ...*QueryBuilder) (*{{structureName}}, error)
+	Count(ctx context.Context, builders ...*QueryBuilder) (int64, error)
+	SelectForUpdate(ctx context.Context, builders ...*QueryBuilder) (*{{structureName}}, error)
+}
+
+// {{structureName}}PaginationOperations is an interface for pagination operations.
 type {{structureName}}PaginationOperations interface {
 	FindManyWithPagination(ctx context.Context, limit int, page int, builders ...*QueryBuilder) ([]*{{structureName}}, *Paginator, error)
 }
@@ -729,242 +1614,1323 @@ type {{structureName}}RelationLoading interface {
 	LoadBatch{{ $field | pluralFieldName }} (ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) error
 	{{- end }}
 	{{- end }}
+	{{- range $index, $field := fields }}
+	{{- if and ($field | isRelation) ($field | isRepeated) (not ($field | isManyToMany)) (not ($field | isCompositeRelation)) }}
+	Load{{ $field | pluralFieldName }}Count (ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) (map[{{ $field | relationKeyType }}]int64, error)
+	{{- end }}
+	{{- end }}
+}
+
+{{- if (hasManyToMany) }}
+
+// {{structureName}}RelationLinking is an interface for linking many-to-many relations.
+type {{structureName}}RelationLinking interface {
+	{{- range $index, $field := fields }}
+	{{- if ($field | isManyToMany) }}
+	Attach{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error
+	Detach{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error
+	Sync{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error
+	{{- end }}
+	{{- end }}
+}
+{{- end }}
+
+{{- if (hasSelfRelation) }}
+
+// {{structureName}}TreeOperations is an interface for recursive queries over the self relation.
+type {{structureName}}TreeOperations interface {
+	Ancestors(ctx context.Context, {{ treeKey.GetName | lowerCamelCase }} {{ treeKey | fieldType }}) ([]*{{structureName}}Node, error)
+	Descendants(ctx context.Context, {{ treeKey.GetName | lowerCamelCase }} {{ treeKey | fieldType }}, maxDepth int) ([]*{{structureName}}Node, error)
+	Tree(ctx context.Context, rootID {{ treeKey | fieldType }}) (*{{structureName}}Node, error)
+}
+{{- end }}
+
+{{- if (hasUniqueKeys) }}
+
+// {{structureName}}UniqueOperations is an interface for finding by the unique fields and indexes.
+type {{structureName}}UniqueOperations interface {
+	{{- range $key := uniqueKeys }}
+	FindBy{{ $key.Name }}(ctx context.Context, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}, {{ end }}opts ...Option) (*{{ structureName }}, error)
+	ExistsBy{{ $key.Name }}(ctx context.Context{{ range $key.Fields }}, {{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}{{ end }}) (bool, error)
+	DeleteBy{{ $key.Name }}(ctx context.Context, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}, {{ end }}opts ...Option) (int64, error)
+	{{- if eq (len $key.Fields) 1 }}
+	{{- $field := index $key.Fields 0 }}
+	FindManyBy{{ $field.GetName | plural | camelCase }}(ctx context.Context, {{ $field.GetName | plural | lowerCamelCase }} []{{ if (findPointer $field) }}{{ $field | fieldTypeWP }}{{ else }}{{ $field | fieldType }}{{ end }}, opts ...Option) (map[{{ if (findPointer $field) }}{{ $field | fieldTypeWP }}{{ else }}{{ $field | fieldType }}{{ end }}]*{{ structureName }}, error)
+	{{- end }}
+	{{- end }}
+}
+{{- end }}
+
+// {{structureName}}AdvancedDeletion is an interface for advanced deletion operations.
+type {{structureName}}AdvancedDeletion interface {
+	DeleteMany(ctx context.Context, builders ...*QueryBuilder) (int64, error)
+}
+
+// {{structureName}}RawQueryOperations is an interface for executing raw queries.
+type {{structureName}}RawQueryOperations interface {
+	Query(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryRows(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// {{ storageName }} is a struct for the "{{ tableName }}" table.
+type {{ storageName }} interface {
+    {{structureName}}TableManager
+	{{structureName}}CRUDOperations
+	{{structureName}}SearchOperations
+	{{structureName}}PaginationOperations
+	{{structureName}}RelationLoading
+	{{- if (hasManyToMany) }}
+	{{structureName}}RelationLinking
+	{{- end }}
+	{{- if (hasSelfRelation) }}
+	{{structureName}}TreeOperations
+	{{- end }}
+	{{- if (hasUniqueKeys) }}
+	{{structureName}}UniqueOperations
+	{{- end }}
+	{{structureName}}AdvancedDeletion
+	{{structureName}}RawQueryOperations
+}
+
+// New{{ storageName }} returns a new {{ storageName | lowerCamelCase }} using db with the default config.
+func New{{ storageName }}(db *sql.DB) {{ storageName }} {
+	return new{{ storageName }}(&Config{DB: db})
+}
+
+// New{{ storageName }}WithConfig returns a new {{ storageName | lowerCamelCase }} using the config.
+func New{{ storageName }}WithConfig(config *Config) ({{ storageName }}, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+	if config.DB == nil {
+		return nil, fmt.Errorf("config.DB is nil")
+	}
+
+	return new{{ storageName }}(config), nil
+}
+
+// new{{ storageName }} returns a new {{ storageName | lowerCamelCase }} using the checked config.
+func new{{ storageName }}(config *Config) *{{ storageName | lowerCamelCase }} {
+	return &{{ storageName | lowerCamelCase }}{
+		config: config,
+		queryBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+// logQuery logs the query if query logging is enabled.
+func (t *{{ storageName | lowerCamelCase }}) logQuery(ctx context.Context, query string, args ...interface{}) {
+	if t.config.QueryLogMethod != nil {
+		t.config.QueryLogMethod(ctx, t.TableName(), query, args...)
+	}
+}
+
+// logError logs the error if error logging is enabled.
+func (t *{{ storageName | lowerCamelCase }}) logError(ctx context.Context, err error, message string) {
+	if t.config.ErrorLogMethod != nil {
+		t.config.ErrorLogMethod(ctx, err, message)
+	}
+}
+
+{{- if (hasAutoTime) }}
+
+// now returns the current time from the configured clock.
+func (t *{{ storageName | lowerCamelCase }}) now() time.Time {
+	if t.config.Clock != nil {
+		return t.config.Clock()
+	}
+	return time.Now()
+}
+
+// fillAutoTime fills the automatic time fields of the {{ structureName }} that are not set yet.
+func (t *{{ storageName | lowerCamelCase }}) fillAutoTime(model *{{ structureName }}) {
+	now := t.now()
+	{{- range $index, $field := fields }}
+	{{- if or ($field | isAutoCreateTime) ($field | isAutoUpdateTime) }}
+	if {{ autoTimeIsZero $field (printf "model.%s" ($field | fieldName)) }} {
+		{{- if ($field | findPointer) }}
+		{{ $field | fieldName | lowerCamelCase }} := {{ $field | autoTimeValue }}
+		model.{{ $field | fieldName }} = &{{ $field | fieldName | lowerCamelCase }}
+		{{- else }}
+		model.{{ $field | fieldName }} = {{ $field | autoTimeValue }}
+		{{- end }}
+	}
+	{{- end }}
+	{{- end }}
+}
+{{- end }}
+{{- if (hasAutoUpdateTime) }}
+
+// touchAutoUpdateTime sets the automatic update time fields of the {{ structureName }} to the current time.
+func (t *{{ storageName | lowerCamelCase }}) touchAutoUpdateTime(model *{{ structureName }}) {
+	now := t.now()
+	{{- range $index, $field := fields }}
+	{{- if ($field | isAutoUpdateTime) }}
+	{{- if ($field | findPointer) }}
+	{{ $field | fieldName | lowerCamelCase }} := {{ $field | autoTimeValue }}
+	model.{{ $field | fieldName }} = &{{ $field | fieldName | lowerCamelCase }}
+	{{- else }}
+	model.{{ $field | fieldName }} = {{ $field | autoTimeValue }}
+	{{- end }}
+	{{- end }}
+	{{- end }}
+}
+{{- end }}
+
+// TableName returns the table name.
+func (t *{{ storageName | lowerCamelCase }}) TableName() string {
+	return "{{ tableName }}"
+}
+
+// Columns returns the columns for the table.
+func (t *{{ storageName | lowerCamelCase }}) Columns() []string {
+	return []string{
+		{{ range $field := fields }}{{if not ($field | isRelation) }}"{{ $field | sourceName }}",{{ end }}{{ end }}
+	}
+}
+
+// DB returns the underlying sql.DB. This is useful for doing transactions.
+// SQLite uses a single connection pool, so isWrite only documents the intent of the call.
+func (t *{{ storageName | lowerCamelCase }}) DB(ctx context.Context, isWrite bool) QueryExecer {
+	var db QueryExecer = t.config.DB
+	if tx, ok := TxFromContext(ctx); ok {
+		db = tx
+	} else if !isWrite && !UsePrimary(ctx) {
+		// Spread the reads over the replicas, falling back to the primary when none is healthy.
+		if replica := selectReplica(t.config.Replicas, t.config.ReplicaSelector); replica != nil {
+			db = &replicaRead{replica: replica, primary: t.config.DB}
+		}
+	}
+
+	// Run the queries through the interceptors.
+	if t.config.intercepted() {
+		return &dbWrapper{db: db, config: t.config, table: t.TableName()}
+	}
+
+	return db
+}
+
+// createTable creates the table in SQLite.
+func (t *{{ storageName | lowerCamelCase }}) CreateTable(ctx context.Context) error {
+    sqlQuery := ` + "`" + `
+        -- Table: {{ tableName }}
+        CREATE TABLE IF NOT EXISTS {{ tableName }} (
+        {{- range $index, $field := fields }}
+        {{- if not ($field | isRelation) }}
+        {{ $field | sourceName }} {{if ($field | isAutoIncrement) }} INTEGER PRIMARY KEY AUTOINCREMENT{{else}}{{ $field | sqliteType }}{{end}}{{if and (isNotNull $field) (not (isAutoIncrement $field)) }} NOT NULL{{ end }}{{if and ($field | isPrimaryKey) (not ($field | isAutoIncrement)) (not hasCompositePrimaryKey) }} PRIMARY KEY{{ end }}{{if ($field | getDefaultValue) }} DEFAULT {{$field | getDefaultValue}}{{end}}{{if not ( $field | isLastField )}},{{end}}
+        {{- end}}
+        {{- end}}
+        {{- if (hasCompositePrimaryKey) }},
+        PRIMARY KEY ({{ range $index, $pk := getPrimaryKeys }}{{ if $index }}, {{ end }}{{ $pk | sourceName }}{{ end }})
+        {{- end }});
+
+        -- Indexes and Unique constraints
+        {{- range $index, $field := fields }}
+        {{- if ($field | hasUnique) }}
+        CREATE UNIQUE INDEX IF NOT EXISTS {{ tableName }}_{{ $field | sourceName }}_unique_idx ON {{ tableName }} ({{ $field | sourceName }});
+        {{- end}}
+        {{- end}}
+
+        {{- range $index, $fields := getStructureUniqueIndexes }}
+        CREATE UNIQUE INDEX IF NOT EXISTS {{ tableName }}_unique_idx_{{ $fields | sliceToString }} ON {{ tableName }} (
+        {{- $length := sub (len $fields) 1 }}
+        {{- range $i, $field := $fields }}
+            {{ $field | sourceName }}{{ if lt $i $length }}, {{ end }}
+        {{- end }}
+        );
+        {{- end}}
+
+        {{- range $index, $field := fields }}
+        {{- if ($field | hasIndex) }}
+        CREATE INDEX IF NOT EXISTS {{ tableName }}_{{ $field | sourceName }}_idx ON {{ tableName }} ({{ $field | sourceName }});
+        {{- end}}
+        {{- end}}
+
+        {{- range $index, $field := fields }}
+        {{- if ($field | isManyToMany) }}
+        {{- $rel := ($field | relation) }}
+
+        -- Join table for {{ $field | relationTableName }}
+        CREATE TABLE IF NOT EXISTS {{ $rel.Through }} (
+        {{ $rel.ThroughField }} {{ $field | relationKey | sqliteType }} NOT NULL,
+        {{ $rel.ThroughReference }} {{ $field | relationRefKey | sqliteType }} NOT NULL,
+        PRIMARY KEY ({{ $rel.ThroughField }}, {{ $rel.ThroughReference }})
+        );
+        CREATE INDEX IF NOT EXISTS {{ $rel.Through }}_{{ $rel.ThroughReference }}_idx ON {{ $rel.Through }} ({{ $rel.ThroughReference }});
+        {{- end}}
+        {{- end}}
+        
+        -- SQLite handles foreign key constraints differently and should be part of table creation
+    ` + "`" + `
+    
+    _, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery)
+    return err
+}
+
+// DropTable drops the table.
+func (t *{{ storageName | lowerCamelCase }}) DropTable(ctx context.Context) error {
+	sqlQuery := ` + "`" + `
+		DROP TABLE IF EXISTS {{ tableName }};
+	` + "`" + `
+
+	_, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery)
+	return err
+}
+
+// TruncateTable truncates the table.
+func (t *{{ storageName | lowerCamelCase }}) TruncateTable(ctx context.Context) error {
+	sqlQuery := ` + "`" + `
+		TRUNCATE TABLE {{ tableName }};
+	` + "`" + `
+
+	_, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery)
+	return err
+}
+
+// UpgradeTable upgrades the table.
+// todo: delete this method 
+func (t *{{ storageName | lowerCamelCase }}) UpgradeTable(ctx context.Context) error {
+	return nil
+}
+
+{{- range $index, $field := fields }}
+{{- if and ($field | isRelation) }}
+// Load{{ $field | pluralFieldName }} loads the {{ $field | pluralFieldName }} relation.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Load{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, builders ...*QueryBuilder) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Load{{ $field | pluralFieldName }}")
+	err := t.doLoad{{ $field | pluralFieldName }}(ctx, model, builders...)
+	endSpan(span, err)
+	return err
+}
+
+// doLoad{{ $field | pluralFieldName }} is Load{{ $field | pluralFieldName }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doLoad{{ $field | pluralFieldName }}{{ else }}Load{{ $field | pluralFieldName }}{{ end }}(ctx context.Context, model *{{structureName}}, builders ...*QueryBuilder) error {
+	if model == nil {
+		return fmt.Errorf("{{structureName}} is nil")
+	}
+
+	{{- if or ($field | isManyToMany) ($field | isCompositeRelation) }}
+
+	return t.LoadBatch{{ $field | pluralFieldName }}(ctx, []*{{structureName}}{model}, builders...)
+	{{- else }}
+
+	// New{{ $field | relationStorageName }}WithConfig creates a new {{ $field | relationStorageName }}.
+	s, err := New{{ $field | relationStorageName }}WithConfig(t.config)
+	if err != nil {
+		return fmt.Errorf("failed to create {{ $field | relationStorageName }}: %w", err)
+	}
+
+	{{- if ($field | isOptional) }}
+		// Check if the optional field is nil
+		if model.{{ $field | getFieldID }} == nil {
+			// If nil, do not attempt to load the relation
+			return nil
+		}
+		// Add the filter for the relation with dereferenced value
+		builders = append(builders, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}Eq(*model.{{ $field | getFieldID }})))
+	{{- else }}
+		// Add the filter for the relation without dereferencing
+		builders = append(builders, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}Eq({{ if ($field | isRefOptional) }}&{{ end }}model.{{ $field | getFieldID }})))
+	{{- end }}
+
+	{{- if ($field | isRepeated) }}
+		relationModels, err := s.FindMany(ctx, builders...)
+		if err != nil {
+			return fmt.Errorf("failed to find many {{ $field | relationStorageName }}: %w", err)
+		}
+
+		model.{{ $field | fieldName }} = relationModels
+	{{- else }}
+		relationModel, err := s.FindOne(ctx, builders...)
+		if err != nil {
+			return fmt.Errorf("failed to find {{ $field | relationStorageName }}: %w", err)
+		}
+
+		model.{{ $field | fieldName }} = relationModel
+	{{- end }}
+	return nil
+	{{- end }}
+}
+{{- end }}
+{{- end }}
+
+{{- range $index, $field := fields }}
+{{- if and ($field | isRelation) }}
+// LoadBatch{{ $field | pluralFieldName }} loads the {{ $field | pluralFieldName }} relation.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) LoadBatch{{ $field | pluralFieldName }}(ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.LoadBatch{{ $field | pluralFieldName }}")
+	err := t.doLoadBatch{{ $field | pluralFieldName }}(ctx, items, builders...)
+	endSpan(span, err)
+	return err
+}
+
+// doLoadBatch{{ $field | pluralFieldName }} is LoadBatch{{ $field | pluralFieldName }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doLoadBatch{{ $field | pluralFieldName }}{{ else }}LoadBatch{{ $field | pluralFieldName }}{{ end }}(ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) error {
+	{{- if ($field | isManyToMany) }}
+	{{- $rel := ($field | relation) }}
+	keys := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, item.{{ $field | getFieldID }})
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// Read the links from the join table
+	sqlQuery, args, err := t.queryBuilder.Select("{{ $rel.ThroughField }}", "{{ $rel.ThroughReference }}").
+		From("{{ $rel.Through }}").
+		Where(sq.Eq{"{{ $rel.ThroughField }}": keys}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	rows, err := t.DB(ctx, false).QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return fmt.Errorf("failed to find {{ $rel.Through }} links: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			t.logError(ctx, err, "failed to close rows")
+		}
+	}()
+
+	parents := make(map[{{ $field | relationRefKey | fieldType }}][]{{ $field | relationKey | fieldType }})
+	refs := make([]interface{}, 0)
+	for rows.Next() {
+		var key {{ $field | relationKey | fieldType }}
+		var ref {{ $field | relationRefKey | fieldType }}
+		if err := rows.Scan(&key, &ref); err != nil {
+			return fmt.Errorf("failed to scan {{ $rel.Through }} link: %w", err)
+		}
+		if _, ok := parents[ref]; !ok {
+			refs = append(refs, ref)
+		}
+		parents[ref] = append(parents[ref], key)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate over rows: %w", err)
+	}
+
+	related := make(map[{{ $field | relationKey | fieldType }}][]*{{ $field | relationStructureName }})
+	if len(refs) > 0 {
+		// New{{ $field | relationStorageName }}WithConfig creates a new {{ $field | relationStorageName }}.
+		s, err := New{{ $field | relationStorageName }}WithConfig(t.config)
+		if err != nil {
+			return fmt.Errorf("failed to create {{ $field | relationStorageName }}: %w", err)
+		}
+
+		builders = append(builders, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}In(refs...)))
+		results, err := s.FindMany(ctx, builders...)
+		if err != nil {
+			return fmt.Errorf("failed to find many {{ $field | relationStorageName }}: %w", err)
+		}
+
+		// Keep the order of the results for every item
+		for _, result := range results {
+			for _, key := range parents[result.{{ $field | getRefID }}] {
+				related[key] = append(related[key], result)
+			}
+		}
+	}
+
+	// Assign {{ $field | relationStructureName }} to items
+	for _, item := range items {
+		item.{{ $field | fieldName }} = related[item.{{ $field | getFieldID }}]
+	}
+
+	return nil
+	{{- else if ($field | isCompositeRelation) }}
+	conditions := make([]FilterApplier, 0, len(items))
+	seen := make(map[[{{ len ($field | relationFields) }}]interface{}]bool, len(items))
+	for _, item := range items {
+		key := [{{ len ($field | relationFields) }}]interface{}{ {{- range $i, $f := ($field | relationFields) }}{{ if $i }}, {{ end }}item.{{ $f | fieldName }}{{ end -}} }
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		conditions = append(conditions, And(
+			{{- range $i, $ref := ($field | relationReferences) }}
+			Eq("{{ $ref.GetName }}", key[{{ $i }}]),
+			{{- end }}
+		))
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	// New{{ $field | relationStorageName }}WithConfig creates a new {{ $field | relationStorageName }}.
+	s, err := New{{ $field | relationStorageName }}WithConfig(t.config)
+	if err != nil {
+		return fmt.Errorf("failed to create {{ $field | relationStorageName }}: %w", err)
+	}
+
+	// Add the filter for the relation, a row matches one of the keys
+	builders = append(builders, FilterBuilder(Or(conditions...)), partitionBuilder("{{ range $i, $ref := ($field | relationReferences) }}{{ if $i }}, {{ end }}{{ $ref.GetName }}{{ end }}"))
+
+	results, err := s.FindMany(ctx, builders...)
+	if err != nil {
+		return fmt.Errorf("failed to find many {{ $field | relationStorageName }}: %w", err)
+	}
+
+	{{- if ($field | isRepeated) }}
+	resultMap := make(map[[{{ len ($field | relationFields) }}]interface{}][]*{{ $field | relationStructureName }})
+	{{- else }}
+	resultMap := make(map[[{{ len ($field | relationFields) }}]interface{}]*{{ $field | relationStructureName }})
+	{{- end }}
+	for _, result := range results {
+		key := [{{ len ($field | relationFields) }}]interface{}{ {{- range $i, $ref := ($field | relationReferences) }}{{ if $i }}, {{ end }}result.{{ $ref | fieldName }}{{ end -}} }
+		{{- if ($field | isRepeated) }}
+		resultMap[key] = append(resultMap[key], result)
+		{{- else }}
+		resultMap[key] = result
+		{{- end }}
+	}
+
+	// Assign {{ $field | relationStructureName }} to items
+	for _, item := range items {
+		key := [{{ len ($field | relationFields) }}]interface{}{ {{- range $i, $f := ($field | relationFields) }}{{ if $i }}, {{ end }}item.{{ $f | fieldName }}{{ end -}} }
+		if v, ok := resultMap[key]; ok {
+			item.{{ $field | fieldName }} = v
+		}
+	}
+
+	return nil
+	{{- else }}
+	requestItems := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		{{- if ($field | isOptional) }}
+			// Check if the field is nil for optional fields
+			if item.{{ $field | getFieldID }} == nil {
+				// Skip nil values for optional fields
+				continue
+			}
+			// Append dereferenced value for optional fields
+			requestItems = append(requestItems, *item.{{ $field | getFieldID }})
+		{{- else }}
+			// Append the value directly for non-optional fields
+			requestItems = append(requestItems, item.{{ $field | getFieldID }})
+		{{- end }}
+	}
+	if len(requestItems) == 0 {
+		return nil
+	}
+
+	// New{{ $field | relationStorageName }}WithConfig creates a new {{ $field | relationStorageName }}.
+	s, err := New{{ $field | relationStorageName }}WithConfig(t.config)
+	if err != nil {
+		return fmt.Errorf("failed to create {{ $field | relationStorageName }}: %w", err)
+	}
+
+	// Add the filter for the relation
+	builders = append(builders, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}In(requestItems...)), partitionBuilder("{{ ($field | relation).Reference }}"))
+
+	results, err := s.FindMany(ctx, builders...)
+	if err != nil {
+		return fmt.Errorf("failed to find many {{ $field | relationStorageName }}: %w", err)
+	}
+
+	{{- if ($field | isRepeated) }}
+	resultMap := make(map[interface{}][]*{{ $field | relationStructureName }})
+	{{- else }}
+	resultMap := make(map[interface{}]*{{ $field | relationStructureName }})
+	{{- end }}
+	for _, result := range results {
+		{{- if ($field | isRefOptional) }}
+		if result.{{ $field | getRefID }} == nil {
+			continue
+		}
+		key := *result.{{ $field | getRefID }}
+		{{- else }}
+		key := result.{{ $field | getRefID }}
+		{{- end }}
+		{{- if ($field | isRepeated) }}
+		resultMap[key] = append(resultMap[key], result)
+		{{- else }}
+		resultMap[key] = result
+		{{- end }}
+	}
+
+	// Assign {{ $field | relationStructureName }} to items
+	for _, item := range items {
+		{{- if ($field | isOptional) }}
+			// Skip assignment if the field is nil
+			if item.{{ $field | getFieldID }} == nil {
+				continue
+			}
+			// Assign the relation if it exists in the resultMap
+			if v, ok := resultMap[*item.{{ $field | getFieldID }}]; ok {
+				item.{{ $field | fieldName }} = v
+			}
+		{{- else }}
+			// Assign the relation directly for non-optional fields
+			if v, ok := resultMap[item.{{ $field | getFieldID }}]; ok {
+				item.{{ $field | fieldName }} = v
+			}
+		{{- end }}
+	}
+
+	return nil
+	{{- end }}
+}
+{{- end }}
+{{- end }}
+
+{{- range $index, $field := fields }}
+{{- if and ($field | isRelation) ($field | isRepeated) (not ($field | isManyToMany)) (not ($field | isCompositeRelation)) }}
+{{- $rel := ($field | relation) }}
+
+// Load{{ $field | pluralFieldName }}Count counts the {{ $field | pluralFieldName }} of every item with one GROUP BY query.
+// The filters of the builders apply to the related rows, items without related rows have no entry.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Load{{ $field | pluralFieldName }}Count(ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) (map[{{ $field | relationKeyType }}]int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Load{{ $field | pluralFieldName }}Count")
+	res, err := t.doLoad{{ $field | pluralFieldName }}Count(ctx, items, builders...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doLoad{{ $field | pluralFieldName }}Count is Load{{ $field | pluralFieldName }}Count without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doLoad{{ $field | pluralFieldName }}Count{{ else }}Load{{ $field | pluralFieldName }}Count{{ end }}(ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) (map[{{ $field | relationKeyType }}]int64, error) {
+	keys := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		{{- if ($field | isOptional) }}
+		if item.{{ $field | getFieldID }} == nil {
+			continue
+		}
+		keys = append(keys, *item.{{ $field | getFieldID }})
+		{{- else }}
+		keys = append(keys, item.{{ $field | getFieldID }})
+		{{- end }}
+	}
+
+	counts := make(map[{{ $field | relationKeyType }}]int64, len(keys))
+	if len(keys) == 0 {
+		return counts, nil
+	}
+
+	query := t.queryBuilder.Select("{{ $rel.Reference }}", "COUNT(*)").
+		From("{{ $field | relationTableName }}").
+		Where(sq.Eq{"{{ $rel.Reference }}": keys}).
+		GroupBy("{{ $rel.Reference }}")
+	for _, builder := range builders {
+		if builder == nil {
+			continue
+		}
+
+		// apply filter options of the related rows
+		for _, option := range builder.filterOptions {
+			query = option.Apply(query)
+		}
+	}
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	rows, err := t.DB(ctx, false).QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count {{ $field | pluralFieldName }}: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			t.logError(ctx, err, "failed to close rows")
+		}
+	}()
+
+	for rows.Next() {
+		var key {{ $field | relationKeyType }}
+		var count int64
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan {{ $field | pluralFieldName }} count: %w", err)
+		}
+		counts[key] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over rows: %w", err)
+	}
+
+	return counts, nil
+}
+{{- end }}
+{{- end }}
+
+{{- range $index, $field := fields }}
+{{- if and ($field | isRelation) }}
+
+// {{ structureName }}Rel{{ $field | fieldName }} identifies the {{ $field | fieldName }} relation of {{ structureName }} for Preload.
+var {{ structureName }}Rel{{ $field | fieldName }} = Relation{name: "{{ structureName }}.{{ $field | fieldName }}"}
+{{- end }}
+{{- end }}
+
+// preload loads the given relations into items with one batched query per relation.
+// Nested relations are preloaded by the related storage.
+func (t *{{ storageName | lowerCamelCase }}) preload(ctx context.Context, items []*{{structureName}}, relations []Relation) error {
+	for _, relation := range mergeRelations(relations) {
+		switch relation.name {
+		{{- range $index, $field := fields }}
+		{{- if and ($field | isRelation) }}
+		case {{ structureName }}Rel{{ $field | fieldName }}.name:
+			if err := t.LoadBatch{{ $field | pluralFieldName }}(ctx, items, relation.builders...); err != nil {
+				return fmt.Errorf("failed to preload %s: %w", relation, err)
+			}
+		{{- end }}
+		{{- end }}
+		default:
+			return fmt.Errorf("unknown relation %s for {{ structureName }}", relation)
+		}
+	}
+
+	return nil
+}
+`
+
+const TableReturningMethodTemplate = `
+// CreateReturning creates a new {{ structureName }} and scans the inserted row back into the model,
+// including values set by the database such as defaults and triggers.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) CreateReturning(ctx context.Context, model *{{structureName}}, opts ...Option) (*{{structureName}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.CreateReturning")
+	res, err := t.doCreateReturning(ctx, model, opts...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doCreateReturning is CreateReturning without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doCreateReturning{{ else }}CreateReturning{{ end }}(ctx context.Context, model *{{structureName}}, opts ...Option) (*{{structureName}}, error) {
+	if model == nil {
+		return nil, fmt.Errorf("model is nil")
+	}
+
+	{{- range $index, $field := fields }}
+	{{- if and ($field | isUUID) ($field | isPrimaryKey) (not ($field | isAutoIncrement)) }}
+	if model.{{ $field | fieldName }} == "" {
+		uuidStr, err := uuid.NewUUID()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate uuid for {{ structureName }}: %w", err)
+		}
+
+		model.{{ $field | fieldName }} = uuidStr.String()
+	}
+	{{- end}}
+	{{- end}}
+
+	// set default options
+	options := &Options{}
+	for _, o := range opts {
+		o(options)
+	}
+
+	if options.relations {
+		return nil, fmt.Errorf("relations are not supported in create returning")
+	}
+
+	{{- if (hasAutoTime) }}
+
+	// fill automatic time fields
+	t.fillAutoTime(model)
+	{{- end }}
+
+	query, err := t.insertQuery(model)
+	if err != nil {
+		return nil, err
+	}
+
+	query = query.Suffix("RETURNING " + strings.Join(t.Columns(), ", "))
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	if err := model.ScanRow(t.DB(ctx, true).QueryRowContext(ctx, sqlQuery, args...)); err != nil {
+		return nil, fmt.Errorf("failed to create {{ structureName }}: %w", MapError(err))
+	}
+
+	return model, nil
+}
+
+// UpsertReturning creates a new {{ structureName }} or updates the existing one on conflict
+// and scans the resulting row back into the model.
+{{- if defaultConflictTarget }}
+// The conflict target is ({{ defaultConflictTarget }}) unless set with WithIgnoreConflictField.
+{{- else }}
+// The conflict target must be set with WithIgnoreConflictField.
+{{- end }}
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) UpsertReturning(ctx context.Context, model *{{structureName}}, updateFields []string, opts ...Option) (*{{structureName}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.UpsertReturning")
+	res, err := t.doUpsertReturning(ctx, model, updateFields, opts...)
+	endSpan(span, err)
+	return res, err
+}
+
+// doUpsertReturning is UpsertReturning without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doUpsertReturning{{ else }}UpsertReturning{{ end }}(ctx context.Context, model *{{structureName}}, updateFields []string, opts ...Option) (*{{structureName}}, error) {
+	if model == nil {
+		return nil, fmt.Errorf("model is nil")
+	}
+
+	{{- range $index, $field := fields }}
+	{{- if and ($field | isUUID) ($field | isPrimaryKey) (not ($field | isAutoIncrement)) }}
+	if model.{{ $field | fieldName }} == "" {
+		uuidStr, err := uuid.NewUUID()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate uuid for {{ structureName }}: %w", err)
+		}
+
+		model.{{ $field | fieldName }} = uuidStr.String()
+	}
+	{{- end}}
+	{{- end}}
+
+	// set default options
+	options := &Options{}
+	for _, o := range opts {
+		o(options)
+	}
+
+	conflictTarget := "{{ defaultConflictTarget }}"
+	if options.ignoreConflictField != "" {
+		conflictTarget = options.ignoreConflictField
+	}
+	if conflictTarget == "" {
+		return nil, fmt.Errorf("conflict target is required for upsert")
+	}
+
+	{{- if (hasAutoTime) }}
+
+	// fill automatic time fields
+	t.fillAutoTime(model)
+	{{- end }}
+	{{- if (hasAutoUpdateTime) }}
+	t.touchAutoUpdateTime(model)
+	{{- end }}
+
+	query, err := t.insertQuery(model)
+	if err != nil {
+		return nil, err
+	}
+
+	query = query.Suffix("ON CONFLICT (" + conflictTarget + ") DO UPDATE SET " + t.upsertSet(updateFields) +
+		" RETURNING " + strings.Join(t.Columns(), ", "))
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	if err := model.ScanRow(t.DB(ctx, true).QueryRowContext(ctx, sqlQuery, args...)); err != nil {
+		return nil, fmt.Errorf("failed to upsert {{ structureName }}: %w", MapError(err))
+	}
+
+	return model, nil
+}
+
+// UpdateReturning updates an existing {{ structureName }} based on non-nil fields and returns the updated row.
+// It returns ErrRowNotFound if no row matches the id.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) UpdateReturning(ctx context.Context, id {{ keyType }}, updateData *{{structureName}}Update) (*{{structureName}}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.UpdateReturning")
+	res, err := t.doUpdateReturning(ctx, id, updateData)
+	endSpan(span, err)
+	return res, err
+}
+
+// doUpdateReturning is UpdateReturning without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doUpdateReturning{{ else }}UpdateReturning{{ end }}(ctx context.Context, id {{ keyType }}, updateData *{{structureName}}Update) (*{{structureName}}, error) {
+	if updateData == nil {
+		return nil, fmt.Errorf("update data is nil")
+	}
+
+	query, err := t.buildUpdateQuery(updateData)
+	if err != nil {
+		return nil, err
+	}
+
+	query = query.Where({{ if (hasCompositePrimaryKey) }}id.eq(){{ else }}"{{ getPrimaryKey.GetName }} = ?", id{{ end }}).
+		Suffix("RETURNING " + strings.Join(t.Columns(), ", "))
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	model := &{{structureName}}{}
+	if err := model.ScanRow(t.DB(ctx, true).QueryRowContext(ctx, sqlQuery, args...)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRowNotFound
+		}
+		return nil, fmt.Errorf("failed to update {{ structureName }}: %w", MapError(err))
+	}
+
+	return model, nil
+}
+`
+
+const TableManyToManyMethodTemplate = `
+{{- range $index, $field := fields }}
+{{- if ($field | isManyToMany) }}
+{{- $rel := ($field | relation) }}
+// Attach{{ $field | pluralFieldName }} links the related {{ $field | relationStructureName }} rows to the model
+// through the "{{ $rel.Through }}" table. Existing links are kept.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Attach{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Attach{{ $field | pluralFieldName }}")
+	err := t.doAttach{{ $field | pluralFieldName }}(ctx, model, related...)
+	endSpan(span, err)
+	return err
+}
+
+// doAttach{{ $field | pluralFieldName }} is Attach{{ $field | pluralFieldName }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doAttach{{ $field | pluralFieldName }}{{ else }}Attach{{ $field | pluralFieldName }}{{ end }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error {
+	if model == nil {
+		return fmt.Errorf("{{structureName}} is nil")
+	}
+	if len(related) == 0 {
+		return nil
+	}
+
+	query := t.queryBuilder.Insert("{{ $rel.Through }}").Columns("{{ $rel.ThroughField }}", "{{ $rel.ThroughReference }}")
+	for _, item := range related {
+		if item == nil {
+			return fmt.Errorf("{{ $field | relationStructureName }} is nil")
+		}
+		query = query.Values(model.{{ $field | getFieldID }}, item.{{ $field | getRefID }})
+	}
+	query = query.Suffix("ON CONFLICT DO NOTHING")
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	if _, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...); err != nil {
+		return fmt.Errorf("failed to attach {{ $field | pluralFieldName }}: %w", MapError(err))
+	}
+
+	return nil
+}
+
+// Detach{{ $field | pluralFieldName }} removes the links between the model and the related {{ $field | relationStructureName }} rows.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Detach{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Detach{{ $field | pluralFieldName }}")
+	err := t.doDetach{{ $field | pluralFieldName }}(ctx, model, related...)
+	endSpan(span, err)
+	return err
+}
+
+// doDetach{{ $field | pluralFieldName }} is Detach{{ $field | pluralFieldName }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doDetach{{ $field | pluralFieldName }}{{ else }}Detach{{ $field | pluralFieldName }}{{ end }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error {
+	if model == nil {
+		return fmt.Errorf("{{structureName}} is nil")
+	}
+	if len(related) == 0 {
+		return nil
+	}
+
+	refs := make([]interface{}, 0, len(related))
+	for _, item := range related {
+		if item == nil {
+			return fmt.Errorf("{{ $field | relationStructureName }} is nil")
+		}
+		refs = append(refs, item.{{ $field | getRefID }})
+	}
+
+	sqlQuery, args, err := t.queryBuilder.Delete("{{ $rel.Through }}").
+		Where(sq.Eq{"{{ $rel.ThroughField }}": model.{{ $field | getFieldID }}, "{{ $rel.ThroughReference }}": refs}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	if _, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...); err != nil {
+		return fmt.Errorf("failed to detach {{ $field | pluralFieldName }}: %w", MapError(err))
+	}
+
+	return nil
+}
+
+// Sync{{ $field | pluralFieldName }} makes the related {{ $field | relationStructureName }} rows the only ones linked to the model.
+// Links to other rows are removed. It runs in the transaction from the context or in a new one.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Sync{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Sync{{ $field | pluralFieldName }}")
+	err := t.doSync{{ $field | pluralFieldName }}(ctx, model, related...)
+	endSpan(span, err)
+	return err
+}
+
+// doSync{{ $field | pluralFieldName }} is Sync{{ $field | pluralFieldName }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doSync{{ $field | pluralFieldName }}{{ else }}Sync{{ $field | pluralFieldName }}{{ end }}(ctx context.Context, model *{{structureName}}, related ...*{{ $field | relationStructureName }}) error {
+	if model == nil {
+		return fmt.Errorf("{{structureName}} is nil")
+	}
+
+	refs := make([]interface{}, 0, len(related))
+	for _, item := range related {
+		if item == nil {
+			return fmt.Errorf("{{ $field | relationStructureName }} is nil")
+		}
+		refs = append(refs, item.{{ $field | getRefID }})
+	}
+
+	query := t.queryBuilder.Delete("{{ $rel.Through }}").Where(sq.Eq{"{{ $rel.ThroughField }}": model.{{ $field | getFieldID }}})
+	if len(refs) > 0 {
+		query = query.Where(sq.NotEq{"{{ $rel.ThroughReference }}": refs})
+	}
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	return t.config.txManager().ExecFuncWithTx(ctx, func(ctx context.Context) error {
+		t.logQuery(ctx, sqlQuery, args...)
+		if _, err := t.DB(ctx, true).ExecContext(ctx, sqlQuery, args...); err != nil {
+			return fmt.Errorf("failed to sync {{ $field | pluralFieldName }}: %w", MapError(err))
+		}
+
+		return t.Attach{{ $field | pluralFieldName }}(ctx, model, related...)
+	})
+}
+{{- end }}
+{{- end }}
+`
+
+const TableTreeMethodTemplate = `
+// {{ structureName }}Node is a {{ structureName }} found by a recursive tree query.
+// Depth is the distance to the row the query started from.
+type {{ structureName }}Node struct {
+	*{{ structureName }}
+	Depth    int
+	Children []*{{ structureName }}Node
 }
 
-// {{structureName}}AdvancedDeletion is an interface for advanced deletion operations.
-type {{structureName}}AdvancedDeletion interface {
-	DeleteMany(ctx context.Context, builders ...*QueryBuilder) error
+// Ancestors returns the parents of the {{ structureName }} up to the root, the nearest one first.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Ancestors(ctx context.Context, {{ treeKey.GetName | lowerCamelCase }} {{ treeKey | fieldType }}) ([]*{{ structureName }}Node, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Ancestors")
+	res, err := t.doAncestors(ctx, {{ treeKey.GetName | lowerCamelCase }})
+	endSpan(span, err)
+	return res, err
 }
 
-// {{structureName}}RawQueryOperations is an interface for executing raw queries.
-type {{structureName}}RawQueryOperations interface {
-	Query(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
-	QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row
-	QueryRows(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+// doAncestors is Ancestors without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doAncestors{{ else }}Ancestors{{ end }}(ctx context.Context, {{ treeKey.GetName | lowerCamelCase }} {{ treeKey | fieldType }}) ([]*{{ structureName }}Node, error) {
+	nodes, err := t.tree(ctx, {{ treeKey.GetName | lowerCamelCase }}, true, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, ErrRowNotFound
+	}
+
+	return nodes[1:], nil
 }
 
-// {{ storageName }} is a struct for the "{{ tableName }}" table.
-type {{ storageName }} interface {
-    {{structureName}}TableManager
-	{{structureName}}CRUDOperations
-	{{structureName}}SearchOperations
-	{{structureName}}PaginationOperations
-	{{structureName}}RelationLoading
-	{{structureName}}AdvancedDeletion
-	{{structureName}}RawQueryOperations
+// Descendants returns the children of the {{ structureName }} ordered by depth.
+// A maxDepth of zero or less returns the whole subtree.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Descendants(ctx context.Context, {{ treeKey.GetName | lowerCamelCase }} {{ treeKey | fieldType }}, maxDepth int) ([]*{{ structureName }}Node, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Descendants")
+	res, err := t.doDescendants(ctx, {{ treeKey.GetName | lowerCamelCase }}, maxDepth)
+	endSpan(span, err)
+	return res, err
 }
 
-// New{{ storageName }} returns a new {{ storageName | lowerCamelCase }}.
-func New{{ storageName }}(db *sql.DB) {{ storageName }} {
-	return &{{ storageName | lowerCamelCase }}{
-		db: db,
-		queryBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+// doDescendants is Descendants without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doDescendants{{ else }}Descendants{{ end }}(ctx context.Context, {{ treeKey.GetName | lowerCamelCase }} {{ treeKey | fieldType }}, maxDepth int) ([]*{{ structureName }}Node, error) {
+	nodes, err := t.tree(ctx, {{ treeKey.GetName | lowerCamelCase }}, false, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, ErrRowNotFound
 	}
+
+	return nodes[1:], nil
 }
 
-// TableName returns the table name.
-func (t *{{ storageName | lowerCamelCase }}) TableName() string {
-	return "{{ tableName }}"
+// Tree returns the {{ structureName }} with all its descendants nested in Children.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) Tree(ctx context.Context, rootID {{ treeKey | fieldType }}) (*{{ structureName }}Node, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.Tree")
+	res, err := t.doTree(ctx, rootID)
+	endSpan(span, err)
+	return res, err
 }
 
-// Columns returns the columns for the table.
-func (t *{{ storageName | lowerCamelCase }}) Columns() []string {
-	return []string{
-		{{ range $field := fields }}{{if not ($field | isRelation) }}"{{ $field | sourceName }}",{{ end }}{{ end }}
+// doTree is Tree without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doTree{{ else }}Tree{{ end }}(ctx context.Context, rootID {{ treeKey | fieldType }}) (*{{ structureName }}Node, error) {
+	nodes, err := t.tree(ctx, rootID, false, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, ErrRowNotFound
 	}
-}
 
-// DB returns the underlying sql.DB. This is useful for doing transactions.
-func (t *{{ storageName | lowerCamelCase }}) DB(ctx context.Context) QueryExecer {
-	var db QueryExecer = t.db
-	if tx, ok := TxFromContext(ctx); ok {
-		db = tx
+	byKey := make(map[{{ treeKey | fieldType }}]*{{ structureName }}Node, len(nodes))
+	for _, node := range nodes {
+		byKey[node.{{ treeKey | fieldName }}] = node
+	}
+	for _, node := range nodes[1:] {
+		{{- if (findPointer treeParentKey) }}
+		if node.{{ treeParentKey | fieldName }} == nil {
+			continue
+		}
+		if parent, ok := byKey[*node.{{ treeParentKey | fieldName }}]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+		{{- else }}
+		if parent, ok := byKey[node.{{ treeParentKey | fieldName }}]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+		{{- end }}
 	}
 
-	return db
+	return nodes[0], nil
 }
 
-// createTable creates the table in SQLite.
-func (t *{{ storageName | lowerCamelCase }}) CreateTable(ctx context.Context) error {
-    sqlQuery := ` + "`" + `
-        -- Table: {{ tableName }}
-        CREATE TABLE IF NOT EXISTS {{ tableName }} (
-        {{- range $index, $field := fields }}
-        {{- if not ($field | isRelation) }}
-        {{ $field | sourceName }} {{if ($field | isAutoIncrement) }} INTEGER PRIMARY KEY AUTOINCREMENT{{else}}{{ $field | sqliteType }}{{end}}{{if and (isNotNull $field) (not (isAutoIncrement $field)) }} NOT NULL{{ end }}{{if ($field | getDefaultValue) }} DEFAULT {{$field | getDefaultValue}}{{end}}{{if not ( $field | isLastField )}},{{end}}
-        {{- end}}
-        {{- end}});
+// tree walks the "{{ tableName }}" table with a recursive query starting from the given row.
+// The starting row is returned first with a depth of zero.
+func (t *{{ storageName | lowerCamelCase }}) tree(ctx context.Context, {{ treeKey.GetName | lowerCamelCase }} {{ treeKey | fieldType }}, ancestors bool, maxDepth int) ([]*{{ structureName }}Node, error) {
+	columns := make([]string, 0, len(t.Columns()))
+	for _, column := range t.Columns() {
+		columns = append(columns, t.TableName()+"."+column)
+	}
 
-        -- Indexes and Unique constraints
-        {{- range $index, $field := fields }}
-        {{- if ($field | hasUnique) }}
-        CREATE UNIQUE INDEX IF NOT EXISTS {{ tableName }}_{{ $field | sourceName }}_unique_idx ON {{ tableName }} ({{ $field | sourceName }});
-        {{- end}}
-        {{- end}}
+	join := "{{ tableName }}.{{ treeParentKey.GetName }} = tree.{{ treeKey.GetName }}"
+	if ancestors {
+		join = "{{ tableName }}.{{ treeKey.GetName }} = tree.{{ treeParentKey.GetName }}"
+	}
 
-        {{- range $index, $fields := getStructureUniqueIndexes }}
-        CREATE UNIQUE INDEX IF NOT EXISTS {{ tableName }}_unique_idx_{{ $fields | sliceToString }} ON {{ tableName }} (
-        {{- $length := sub (len $fields) 1 }}
-        {{- range $i, $field := $fields }}
-            {{ $field | sourceName }}{{ if lt $i $length }}, {{ end }}
-        {{- end }}
-        );
-        {{- end}}
+	args := []interface{}{ {{- treeKey.GetName | lowerCamelCase -}} }
+	recursive := "SELECT " + strings.Join(columns, ", ") + ", tree.depth + 1 FROM {{ tableName }} JOIN tree ON " + join
+	if maxDepth > 0 {
+		recursive += " WHERE tree.depth < ?"
+		args = append(args, maxDepth)
+	}
 
-        {{- range $index, $field := fields }}
-        {{- if ($field | hasIndex) }}
-        CREATE INDEX IF NOT EXISTS {{ tableName }}_{{ $field | sourceName }}_idx ON {{ tableName }} ({{ $field | sourceName }});
-        {{- end}}
-        {{- end}}
-        
-        -- SQLite handles foreign key constraints differently and should be part of table creation
-    ` + "`" + `
-    
-    _, err := t.db.ExecContext(ctx, sqlQuery)
-    return err
+	query := t.queryBuilder.Select(append(t.Columns(), "depth")...).
+		Prefix("WITH RECURSIVE tree AS (SELECT "+strings.Join(columns, ", ")+", 0 AS depth FROM {{ tableName }} WHERE {{ treeKey.GetName }} = ? UNION ALL "+recursive+")", args...).
+		From("tree").
+		OrderBy("depth", "{{ treeKey.GetName }}")
+
+	// execute query
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	t.logQuery(ctx, sqlQuery, args...)
+
+	rows, err := t.DB(ctx, false).QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			t.logError(ctx, err, "failed to close rows")
+		}
+	}()
+
+	var nodes []*{{ structureName }}Node
+	for rows.Next() {
+		node := &{{ structureName }}Node{ {{- structureName }}: &{{ structureName }}{}}
+		if err := rows.Scan(
+			{{- range $field := fields }}
+			{{- if not ($field | isRelation) }}
+			&node.{{ $field | fieldName }},
+			{{- end }}
+			{{- end }}
+			&node.Depth,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan {{ structureName }}: %w", err)
+		}
+		nodes = append(nodes, node)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over rows: %w", err)
+	}
+
+	return nodes, nil
 }
+`
 
-// DropTable drops the table.
-func (t *{{ storageName | lowerCamelCase }}) DropTable(ctx context.Context) error {
-	sqlQuery := ` + "`" + `
-		DROP TABLE IF EXISTS {{ tableName }};
-	` + "`" + `
+const TableUniqueMethodTemplate = `
+{{- range $key := uniqueKeys }}
 
-	_, err := t.db.ExecContext(ctx, sqlQuery)
-	return err
+// FindBy{{ $key.Name }} retrieves a {{ structureName }} by its unique {{ range $i, $f := $key.Fields }}{{ if $i }} and {{ end }}{{ $f.GetName }}{{ end }}.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) FindBy{{ $key.Name }}(ctx context.Context, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}, {{ end }}opts ...Option) (*{{ structureName }}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.FindBy{{ $key.Name }}")
+	res, err := t.doFindBy{{ $key.Name }}(ctx, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }}, {{ end }}opts...)
+	endSpan(span, err)
+	return res, err
 }
 
-// TruncateTable truncates the table.
-func (t *{{ storageName | lowerCamelCase }}) TruncateTable(ctx context.Context) error {
-	sqlQuery := ` + "`" + `
-		TRUNCATE TABLE {{ tableName }};
-	` + "`" + `
+// doFindBy{{ $key.Name }} is FindBy{{ $key.Name }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doFindBy{{ $key.Name }}{{ else }}FindBy{{ $key.Name }}{{ end }}(ctx context.Context, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}, {{ end }}opts ...Option) (*{{ structureName }}, error) {
+	builder := NewQueryBuilder()
+	{
+		builder.WithFilter({{ range $i, $f := $key.Fields }}{{ if $i }}, {{ end }}Eq("{{ $f.GetName }}", {{ $f.GetName | lowerCamelCase }}){{ end }})
+		builder.WithOptions(opts...)
+	}
 
-	_, err := t.db.ExecContext(ctx, sqlQuery)
-	return err
+	// Use FindOne to get a single result
+	model, err := t.FindOne(ctx, builder)
+	if err != nil {
+		return nil, fmt.Errorf("find one {{ structureName }}: %w", err)
+	}
+
+	return model, nil
 }
 
-// UpgradeTable upgrades the table.
-// todo: delete this method 
-func (t *{{ storageName | lowerCamelCase }}) UpgradeTable(ctx context.Context) error {
-	return nil
+// ExistsBy{{ $key.Name }} reports whether a {{ structureName }} with the given {{ range $i, $f := $key.Fields }}{{ if $i }} and {{ end }}{{ $f.GetName }}{{ end }} exists.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) ExistsBy{{ $key.Name }}(ctx context.Context{{ range $key.Fields }}, {{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}{{ end }}) (bool, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.ExistsBy{{ $key.Name }}")
+	res, err := t.doExistsBy{{ $key.Name }}(ctx{{ range $key.Fields }}, {{ .GetName | lowerCamelCase }}{{ end }})
+	endSpan(span, err)
+	return res, err
 }
 
-{{- range $index, $field := fields }}
-{{- if and ($field | isRelation) }}
-// Load{{ $field | pluralFieldName }} loads the {{ $field | pluralFieldName }} relation.
-func (t *{{ storageName | lowerCamelCase }}) Load{{ $field | pluralFieldName }}(ctx context.Context, model *{{structureName}}, builders ...*QueryBuilder) error {
-	if model == nil {
-		return fmt.Errorf("{{structureName}} is nil")
+// doExistsBy{{ $key.Name }} is ExistsBy{{ $key.Name }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doExistsBy{{ $key.Name }}{{ else }}ExistsBy{{ $key.Name }}{{ end }}(ctx context.Context{{ range $key.Fields }}, {{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}{{ end }}) (bool, error) {
+	count, err := t.Count(ctx, FilterBuilder({{ range $i, $f := $key.Fields }}{{ if $i }}, {{ end }}Eq("{{ $f.GetName }}", {{ $f.GetName | lowerCamelCase }}){{ end }}))
+	if err != nil {
+		return false, fmt.Errorf("count {{ structureName }}: %w", err)
 	}
 
-	// New{{ $field | relationStorageName }} creates a new {{ $field | relationStorageName }}.
-	s := New{{ $field | relationStorageName }}(t.db)
+	return count > 0, nil
+}
+
+// DeleteBy{{ $key.Name }} deletes the {{ structureName }} with the given {{ range $i, $f := $key.Fields }}{{ if $i }} and {{ end }}{{ $f.GetName }}{{ end }} and returns the number of deleted rows.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) DeleteBy{{ $key.Name }}(ctx context.Context, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}, {{ end }}opts ...Option) (int64, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.DeleteBy{{ $key.Name }}")
+	res, err := t.doDeleteBy{{ $key.Name }}(ctx, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }}, {{ end }}opts...)
+	endSpan(span, err)
+	return res, err
+}
 
-	{{- if ($field | isOptional) }}
-		// Check if the optional field is nil
-		if model.{{ $field | getFieldID }} == nil {
-			// If nil, do not attempt to load the relation
-			return nil
-		}
-		// Add the filter for the relation with dereferenced value
-		builders = append(builders, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}Eq(*model.{{ $field | getFieldID }})))
-	{{- else }}
-		// Add the filter for the relation without dereferencing
-		builders = append(builders, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}Eq(model.{{ $field | getFieldID }})))
-	{{- end }}
+// doDeleteBy{{ $key.Name }} is DeleteBy{{ $key.Name }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doDeleteBy{{ $key.Name }}{{ else }}DeleteBy{{ $key.Name }}{{ end }}(ctx context.Context, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }} {{ if (findPointer .) }}{{ . | fieldTypeWP }}{{ else }}{{ . | fieldType }}{{ end }}, {{ end }}opts ...Option) (int64, error) {
+	{{- if (hasCascadeDelete) }}
+	// find and delete the row in one transaction, so a concurrent change of the key can't make it delete another row
+	if _, ok := TxFromContext(ctx); !ok {
+		var deleted int64
+		err := t.config.txManager().ExecFuncWithTx(ctx, func(ctx context.Context) error {
+			var err error
+			deleted, err = t.DeleteBy{{ $key.Name }}(ctx, {{ range $key.Fields }}{{ .GetName | lowerCamelCase }}, {{ end }}opts...)
+			return err
+		})
+		return deleted, err
+	}
 
-	{{- if ($field | isRepeated) }}
-		relationModels, err := s.FindMany(ctx, builders...)
-		if err != nil {
-			return fmt.Errorf("failed to find many {{ $field | relationStorageName }}: %w", err)
+	// delete through the primary key, so the cascading relations are deleted as well
+	model, err := t.FindBy{{ $key.Name }}(ctx{{ range $key.Fields }}, {{ .GetName | lowerCamelCase }}{{ end }}, opts...)
+	if err != nil {
+		if errors.Is(err, ErrRowNotFound) {
+			options := &Options{}
+			for _, o := range opts {
+				o(options)
+			}
+			if options.mustAffect {
+				return 0, ErrNotFound
+			}
+			return 0, nil
 		}
+		return 0, err
+	}
 
-		model.{{ $field | fieldName }} = relationModels
+	return t.DeleteBy{{ primaryKeyName | camelCase }}(ctx, model.{{ getPrimaryKey | fieldName }}, opts...)
 	{{- else }}
-		relationModel, err := s.FindOne(ctx, builders...)
-		if err != nil {
-			return fmt.Errorf("failed to find {{ $field | relationStorageName }}: %w", err)
-		}
+	builder := NewQueryBuilder()
+	{
+		builder.WithFilter({{ range $i, $f := $key.Fields }}{{ if $i }}, {{ end }}Eq("{{ $f.GetName }}", {{ $f.GetName | lowerCamelCase }}){{ end }})
+		builder.WithOptions(opts...)
+	}
 
-		model.{{ $field | fieldName }} = relationModel
+	return t.DeleteMany(ctx, builder)
 	{{- end }}
-	return nil
 }
-{{- end }}
-{{- end }}
+{{- if eq (len $key.Fields) 1 }}
+{{- $field := index $key.Fields 0 }}
+
+// FindManyBy{{ $field.GetName | plural | camelCase }} retrieves the {{ structureName }} rows with the given {{ $field.GetName | plural }}, keyed by {{ $field.GetName }}.
+{{- if otel }}
+func (t *{{ storageName | lowerCamelCase }}) FindManyBy{{ $field.GetName | plural | camelCase }}(ctx context.Context, {{ $field.GetName | plural | lowerCamelCase }} []{{ if (findPointer $field) }}{{ $field | fieldTypeWP }}{{ else }}{{ $field | fieldType }}{{ end }}, opts ...Option) (map[{{ if (findPointer $field) }}{{ $field | fieldTypeWP }}{{ else }}{{ $field | fieldType }}{{ end }}]*{{ structureName }}, error) {
+	ctx, span := t.config.startSpan(ctx, t.TableName(), "{{ storageName }}.FindManyBy{{ $field.GetName | plural | camelCase }}")
+	res, err := t.doFindManyBy{{ $field.GetName | plural | camelCase }}(ctx, {{ $field.GetName | plural | lowerCamelCase }}, opts...)
+	endSpan(span, err)
+	return res, err
+}
 
-{{- range $index, $field := fields }}
-{{- if and ($field | isRelation) }}
-// LoadBatch{{ $field | pluralFieldName }} loads the {{ $field | pluralFieldName }} relation.
-func (t *{{ storageName | lowerCamelCase }}) LoadBatch{{ $field | pluralFieldName }}(ctx context.Context, items []*{{structureName}}, builders ...*QueryBuilder) error {
-	requestItems := make([]interface{}, 0, len(items))
-	for _, item := range items {
-		{{- if ($field | isOptional) }}
-			// Check if the field is nil for optional fields
-			if item.{{ $field | getFieldID }} == nil {
-				// Skip nil values for optional fields
-				continue
-			}
-			// Append dereferenced value for optional fields
-			requestItems = append(requestItems, *item.{{ $field | getFieldID }})
-		{{- else }}
-			// Append the value directly for non-optional fields
-			requestItems = append(requestItems, item.{{ $field | getFieldID }})
-		{{- end }}
+// doFindManyBy{{ $field.GetName | plural | camelCase }} is FindManyBy{{ $field.GetName | plural | camelCase }} without its span.
+{{- end }}
+func (t *{{ storageName | lowerCamelCase }}) {{ if otel }}doFindManyBy{{ $field.GetName | plural | camelCase }}{{ else }}FindManyBy{{ $field.GetName | plural | camelCase }}{{ end }}(ctx context.Context, {{ $field.GetName | plural | lowerCamelCase }} []{{ if (findPointer $field) }}{{ $field | fieldTypeWP }}{{ else }}{{ $field | fieldType }}{{ end }}, opts ...Option) (map[{{ if (findPointer $field) }}{{ $field | fieldTypeWP }}{{ else }}{{ $field | fieldType }}{{ end }}]*{{ structureName }}, error) {
+	result := make(map[{{ if (findPointer $field) }}{{ $field | fieldTypeWP }}{{ else }}{{ $field | fieldType }}{{ end }}]*{{ structureName }}, len({{ $field.GetName | plural | lowerCamelCase }}))
+	if len({{ $field.GetName | plural | lowerCamelCase }}) == 0 {
+		return result, nil
 	}
 
-	// New{{ $field | relationStorageName }} creates a new {{ $field | relationStorageName }}.
-	s := New{{ $field | relationStorageName }}(t.db)
-
-	// Add the filter for the relation
-	{{- if ($field | isOptional) }}
-		// Ensure that requestItems are not empty before adding the builder
-		if len(requestItems) > 0 {
-			builders = append(builders, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}In(requestItems...)))
-		}
-	{{- else }}
-		builders = append(builders, FilterBuilder({{ $field | relationStructureName }}{{ $field | getRefID }}In(requestItems...)))
-	{{- end }}
+	values := make([]interface{}, 0, len({{ $field.GetName | plural | lowerCamelCase }}))
+	for _, v := range {{ $field.GetName | plural | lowerCamelCase }} {
+		values = append(values, v)
+	}
 
-	results, err := s.FindMany(ctx, builders...)
-	if err != nil {
-		return fmt.Errorf("failed to find many {{ $field | relationStorageName }}: %w", err)
+	builder := NewQueryBuilder()
+	{
+		builder.WithFilter(In("{{ $field.GetName }}", values...))
+		builder.WithOptions(opts...)
 	}
 
-	{{- if ($field | isRepeated) }}
-	resultMap := make(map[interface{}][]*{{ $field | relationStructureName }})
-	{{- else }}
-	resultMap := make(map[interface{}]*{{ $field | relationStructureName }})
-	{{- end }}
-	for _, result := range results {
-		{{- if ($field | isRepeated) }}
-		resultMap[result.{{ $field | getRefID }}] = append(resultMap[result.{{ $field | getRefID }}], result)
-		{{- else }}
-		resultMap[result.{{ $field | getRefID }}] = result
-		{{- end }}
+	items, err := t.FindMany(ctx, builder)
+	if err != nil {
+		return nil, fmt.Errorf("find many {{ structureName }}: %w", err)
 	}
 
-	// Assign {{ $field | relationStructureName }} to items
 	for _, item := range items {
-		{{- if ($field | isOptional) }}
-			// Skip assignment if the field is nil
-			if item.{{ $field | getFieldID }} == nil {
-				continue
-			}
-			// Assign the relation if it exists in the resultMap
-			if v, ok := resultMap[*item.{{ $field | getFieldID }}]; ok {
-				item.{{ $field | fieldName }} = v
-			}
+		{{- if (findPointer $field) }}
+		if item.{{ $field | fieldName }} == nil {
+			continue
+		}
+		result[*item.{{ $field | fieldName }}] = item
 		{{- else }}
-			// Assign the relation directly for non-optional fields
-			if v, ok := resultMap[item.{{ $field | getFieldID }}]; ok {
-				item.{{ $field | fieldName }} = v
-			}
+		result[item.{{ $field | fieldName }}] = item
 		{{- end }}
 	}
 
-	return nil
+	return result, nil
 }
 {{- end }}
 {{- end }}
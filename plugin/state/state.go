@@ -11,6 +11,7 @@ import (
 	"google.golang.org/protobuf/types/descriptorpb"
 
 	importpkg "github.com/cjp2600/protoc-gen-structify/plugin/import"
+	structify "github.com/cjp2600/protoc-gen-structify/plugin/options"
 	helperpkg "github.com/cjp2600/protoc-gen-structify/plugin/pkg/helper"
 	"github.com/cjp2600/protoc-gen-structify/plugin/pkg/version"
 )
@@ -26,6 +27,9 @@ type State struct {
 	IncludeConnection bool   // IncludeConnection is the flag to include connection in the generated code.
 	CRUDSchemas       bool
 	UseSQLX           bool // UseSQLX enables sqlx-compatible DB interfaces for generated postgres code.
+	Outbox            bool // Outbox enables the transactional outbox.
+	Otel              bool // Otel enables OpenTelemetry tracing in the generated code.
+	Metrics           bool // Metrics enables the Prometheus metrics collector in the generated postgres code.
 
 	Imports        *importpkg.ImportSet // Imports is the set of Imports.
 	Relations      Relations            // Relations is the set of Relations Messages.
@@ -45,6 +49,7 @@ func NewState(
 	nestedMessages := getNestedMessages(request)
 	state := &State{
 		Provider:    getProvider(request),
+		Outbox:      getOutbox(request),
 		PackageName: protoFile.GetPackage(),
 		FileName:    parseFileName(request),
 
@@ -76,6 +81,12 @@ func getProvider(request *plugingo.CodeGeneratorRequest) string {
 	return ""
 }
 
+// getOutbox returns true if the outbox file option is set.
+func getOutbox(request *plugingo.CodeGeneratorRequest) bool {
+	protoFile := helperpkg.GetUserProtoFile(request)
+	return helperpkg.GetDBOptions(protoFile).GetOutbox()
+}
+
 // defaultImports returns the default Imports.
 func defaultImports(request *plugingo.CodeGeneratorRequest) *importpkg.ImportSet {
 	imports := importpkg.NewImportSet()
@@ -133,10 +144,12 @@ func getRelations(request *plugingo.CodeGeneratorRequest, nestSet NestedMessages
 
 	for _, msg := range protoFile.GetMessageType() {
 		var pk *descriptor.FieldDescriptorProto
+		var pks []string
 		for _, f := range msg.GetField() {
 			if opts := helperpkg.GetFieldOptions(f); opts != nil {
 				if opts.GetPrimaryKey() {
 					pk = f
+					pks = append(pks, f.GetName())
 				}
 			}
 		}
@@ -158,8 +171,11 @@ func getRelations(request *plugingo.CodeGeneratorRequest, nestSet NestedMessages
 					AllowSubCreating:   isAllowSubCreating(request, msg, field),   // default allow sub creating
 				}
 
+				// a self reference is described by its own options only
+				relation.SelfReference = relation.RelationDescriptor.GetName() == msg.GetName()
+
 				// find related options
-				if relation.RelationDescriptor != nil {
+				if relation.RelationDescriptor != nil && !relation.SelfReference {
 					for _, f := range relation.RelationDescriptor.GetField() {
 						pc := helperpkg.ConvertType(f)
 						if helperpkg.ClearPointer(pc) == msg.GetName() {
@@ -189,7 +205,9 @@ func getRelations(request *plugingo.CodeGeneratorRequest, nestSet NestedMessages
 						relation.UseTag = true
 						relation.Field = relOptions.GetField()
 						relation.Reference = relOptions.GetReference()
-						if pk != nil {
+						if relOptions.GetThrough() != "" {
+							setThroughOptions(relation, relOptions, pk)
+						} else if pk != nil {
 							if relation.Field != pk.GetName() {
 								relation.Direction = ChildToParent
 							} else {
@@ -201,9 +219,32 @@ func getRelations(request *plugingo.CodeGeneratorRequest, nestSet NestedMessages
 					updateSupOptions(relation)
 				}
 
+				// composite relations point from the parent when they list the whole primary key
+				if relation.IsComposite() && relation.Direction != ManyToMany && len(pks) > 0 {
+					if sameColumns(relation.Fields(), pks) {
+						relation.Direction = ParentToChild
+					} else {
+						relation.Direction = ChildToParent
+					}
+				}
+
+				// tagged parent-to-child relations with foreign.cascade own the related rows
+				// even when the reference is not named after the message
+				if relation.Direction == ParentToChild && hasField(relation.RelationDescriptor, relation.Reference) &&
+					isCascadeDelete(msg, field, relation.RelationDescriptor) {
+					relation.AllowSubCreating = true
+				}
+				// nested writes pass a single key to the related rows
+				if relation.IsComposite() || len(pks) > 1 {
+					relation.AllowSubCreating = false
+				}
+				if relation.AllowSubCreating && relation.Direction != ManyToMany {
+					relation.CascadeDelete = isCascadeDelete(msg, field, relation.RelationDescriptor)
+				}
+
 				if nestSet.CheckIsRelation(field) {
 					// Add the relation to the map of Relations
-					respRelations[NewRelationType(msg.GetName(), relation.StructName)] = relation
+					respRelations[RelationType(RelationName(msg.GetName(), field))] = relation
 				}
 			}
 		}
@@ -212,6 +253,79 @@ func getRelations(request *plugingo.CodeGeneratorRequest, nestSet NestedMessages
 	return respRelations
 }
 
+// sameColumns returns true if both lists hold the same columns in any order.
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, column := range a {
+		found := false
+		for _, other := range b {
+			if column == other {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// hasField returns true if the message has a field with the given name.
+func hasField(msg *descriptor.DescriptorProto, name string) bool {
+	for _, f := range msg.GetField() {
+		if f.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isCascadeDelete returns true if the relation field or the back reference
+// in the related message has the Foreign.cascade flag.
+func isCascadeDelete(msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto, related *descriptor.DescriptorProto) bool {
+	if opts := helperpkg.GetFieldOptions(field); opts.GetRelation().GetForeign().GetCascade() {
+		return true
+	}
+	for _, f := range related.GetField() {
+		if helperpkg.ClearPointer(helperpkg.ConvertType(f)) != msg.GetName() {
+			continue
+		}
+		if opts := helperpkg.GetFieldOptions(f); opts.GetRelation().GetForeign().GetCascade() {
+			return true
+		}
+	}
+	return false
+}
+
+// setThroughOptions fills a many-to-many relation from the through options.
+// Keys default to the primary keys and join columns to "<message>_id".
+func setThroughOptions(relation *Relation, relOptions *structify.Relation, pk *descriptor.FieldDescriptorProto) {
+	relation.Direction = ManyToMany
+	relation.Through = relOptions.GetThrough()
+	relation.ThroughField = relOptions.GetThroughField()
+	relation.ThroughReference = relOptions.GetThroughReference()
+
+	if relation.Field == "" && pk != nil {
+		relation.Field = pk.GetName()
+	}
+	if relation.Reference == "" {
+		for _, f := range relation.RelationDescriptor.GetField() {
+			if opts := helperpkg.GetFieldOptions(f); opts != nil && opts.GetPrimaryKey() {
+				relation.Reference = f.GetName()
+			}
+		}
+	}
+	if relation.ThroughField == "" {
+		relation.ThroughField = helperpkg.SnakeCase(relation.ParentDescriptor.GetName()) + "_id"
+	}
+	if relation.ThroughReference == "" {
+		relation.ThroughReference = helperpkg.SnakeCase(relation.RelationDescriptor.GetName()) + "_id"
+	}
+}
+
 // updateSupOptions updates the relation options.
 func updateSupOptions(relation *Relation) {
 	for _, pDesc := range relation.RelationDescriptor.GetField() {
@@ -737,6 +851,7 @@ const (
 	UnknownDirectionDirection = iota
 	ParentToChild
 	ChildToParent
+	ManyToMany
 )
 
 // Relation is a type for how to generate json statements.
@@ -753,6 +868,41 @@ type Relation struct {
 	Many               bool
 	AllowSubCreating   bool
 	UseTag             bool
+	// CascadeDelete deletes the related rows together with the parent.
+	CascadeDelete bool
+	// SelfReference is set when the message references its own table.
+	SelfReference bool
+	// Through is the join table of a many-to-many relation,
+	// ThroughField and ThroughReference are its columns.
+	Through          string
+	ThroughField     string
+	ThroughReference string
+}
+
+// Fields returns the columns of the relation, a composite relation lists them separated by commas.
+func (r *Relation) Fields() []string {
+	return splitColumns(r.Field)
+}
+
+// References returns the columns of the related message in the order of Fields.
+func (r *Relation) References() []string {
+	return splitColumns(r.Reference)
+}
+
+// IsComposite returns true if the relation spans several columns.
+func (r *Relation) IsComposite() bool {
+	return len(r.Fields()) > 1
+}
+
+// splitColumns splits a comma separated list of columns.
+func splitColumns(columns string) []string {
+	var result []string
+	for _, column := range strings.Split(columns, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			result = append(result, column)
+		}
+	}
+	return result
 }
 
 // RelationType is a type for how to generate json statements.
@@ -763,6 +913,16 @@ func (r RelationType) String() string {
 	return string(r)
 }
 
+// RelationName returns the name of the relation of the message field.
+// A message can reference itself more than once, so self references are named by the field.
+func RelationName(messageName string, field *descriptor.FieldDescriptorProto) string {
+	structName := helperpkg.ClearPointer(helperpkg.ConvertType(field))
+	if structName == messageName {
+		return messageName + "::" + structName + "::" + field.GetName()
+	}
+	return messageName + "::" + structName
+}
+
 // NewRelationType creates a new relation type.
 func NewRelationType(messageName string, structureName string) RelationType {
 	return RelationType(messageName + "::" + structureName)
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	_import "github.com/cjp2600/protoc-gen-structify/plugin/import"
+	structify "github.com/cjp2600/protoc-gen-structify/plugin/options"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	plugingo "github.com/golang/protobuf/protoc-gen-go/plugin"
@@ -435,3 +436,362 @@ func TestNestedMessages_CheckIsRelation_GoogleStruct(t *testing.T) {
 
 	assert.False(t, nested.CheckIsRelation(field))
 }
+
+func TestGetRelations_ManyToMany(t *testing.T) {
+	through := func(rel *structify.Relation) *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, structify.E_Field, &structify.StructifyFieldOptions{Relation: rel})
+		return opts
+	}
+	pk := func() *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, structify.E_Field, &structify.StructifyFieldOptions{PrimaryKey: true})
+		return opts
+	}
+
+	req := &plugingo.CodeGeneratorRequest{
+		FileToGenerate: []string{"test.proto"},
+		ProtoFile: []*descriptor.FileDescriptorProto{
+			{
+				Name:    proto.String("test.proto"),
+				Package: proto.String("test"),
+				MessageType: []*descriptor.DescriptorProto{
+					{
+						Name: proto.String("User"),
+						Field: []*descriptor.FieldDescriptorProto{
+							{Name: proto.String("id"), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Number: proto.Int32(1), Options: pk()},
+							{
+								Name:     proto.String("groups"),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+								TypeName: proto.String(".test.Group"),
+								Number:   proto.Int32(2),
+								Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+								Options:  through(&structify.Relation{Through: "user_groups"}),
+							},
+						},
+					},
+					{
+						Name: proto.String("Group"),
+						Field: []*descriptor.FieldDescriptorProto{
+							{Name: proto.String("uid"), Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Number: proto.Int32(1), Options: pk()},
+							{
+								Name:     proto.String("members"),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+								TypeName: proto.String(".test.User"),
+								Number:   proto.Int32(2),
+								Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+								Options:  through(&structify.Relation{Through: "user_groups", ThroughField: "gid", ThroughReference: "uid"}),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	state := NewState(req)
+
+	groups, ok := state.Relations.Get("User::Group")
+	require.True(t, ok)
+	assert.Equal(t, ManyToMany, int(groups.Direction))
+	assert.Equal(t, "user_groups", groups.Through)
+	assert.Equal(t, "user_id", groups.ThroughField)
+	assert.Equal(t, "group_id", groups.ThroughReference)
+	assert.Equal(t, "id", groups.Field)
+	assert.Equal(t, "uid", groups.Reference)
+
+	members, ok := state.Relations.Get("Group::User")
+	require.True(t, ok)
+	assert.Equal(t, ManyToMany, int(members.Direction))
+	assert.Equal(t, "gid", members.ThroughField)
+	assert.Equal(t, "uid", members.ThroughReference)
+	assert.Equal(t, "uid", members.Field)
+	assert.Equal(t, "id", members.Reference)
+}
+
+func TestGetRelations_CascadeDelete(t *testing.T) {
+	relation := func(rel *structify.Relation) *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, structify.E_Field, &structify.StructifyFieldOptions{Relation: rel})
+		return opts
+	}
+	pk := func() *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, structify.E_Field, &structify.StructifyFieldOptions{PrimaryKey: true})
+		return opts
+	}
+
+	req := &plugingo.CodeGeneratorRequest{
+		FileToGenerate: []string{"test.proto"},
+		ProtoFile: []*descriptor.FileDescriptorProto{
+			{
+				Name:    proto.String("test.proto"),
+				Package: proto.String("test"),
+				MessageType: []*descriptor.DescriptorProto{
+					{
+						Name: proto.String("User"),
+						Field: []*descriptor.FieldDescriptorProto{
+							{Name: proto.String("id"), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Number: proto.Int32(1), Options: pk()},
+							{
+								Name:     proto.String("posts"),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+								TypeName: proto.String(".test.Post"),
+								Number:   proto.Int32(2),
+								Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+								Options:  relation(&structify.Relation{Field: "id", Reference: "author_id"}),
+							},
+						},
+					},
+					{
+						Name: proto.String("Post"),
+						Field: []*descriptor.FieldDescriptorProto{
+							{Name: proto.String("id"), Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Number: proto.Int32(1), Options: pk()},
+							{Name: proto.String("author_id"), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Number: proto.Int32(2)},
+							{
+								Name:     proto.String("author"),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+								TypeName: proto.String(".test.User"),
+								Number:   proto.Int32(3),
+								Options: relation(&structify.Relation{
+									Field:     "author_id",
+									Reference: "id",
+									Foreign:   &structify.Foreign{Cascade: true},
+								}),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	state := NewState(req)
+
+	posts, ok := state.Relations.Get("User::Post")
+	require.True(t, ok)
+	assert.Equal(t, ParentToChild, int(posts.Direction))
+	assert.True(t, posts.AllowSubCreating)
+	assert.True(t, posts.CascadeDelete)
+
+	author, ok := state.Relations.Get("Post::User")
+	require.True(t, ok)
+	assert.Equal(t, ChildToParent, int(author.Direction))
+	assert.False(t, author.AllowSubCreating)
+	assert.False(t, author.CascadeDelete)
+}
+
+func TestGetRelations_SubCreatingDefault(t *testing.T) {
+	relation := func(rel *structify.Relation) *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, structify.E_Field, &structify.StructifyFieldOptions{Relation: rel})
+		return opts
+	}
+	pk := func() *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, structify.E_Field, &structify.StructifyFieldOptions{PrimaryKey: true})
+		return opts
+	}
+
+	req := &plugingo.CodeGeneratorRequest{
+		FileToGenerate: []string{"test.proto"},
+		ProtoFile: []*descriptor.FileDescriptorProto{
+			{
+				Name:    proto.String("test.proto"),
+				Package: proto.String("test"),
+				MessageType: []*descriptor.DescriptorProto{
+					{
+						Name: proto.String("User"),
+						Field: []*descriptor.FieldDescriptorProto{
+							{Name: proto.String("id"), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Number: proto.Int32(1), Options: pk()},
+							{
+								Name:     proto.String("posts"),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+								TypeName: proto.String(".test.Post"),
+								Number:   proto.Int32(2),
+								Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+								Options:  relation(&structify.Relation{Field: "id", Reference: "author_id"}),
+							},
+							{
+								Name:     proto.String("comments"),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+								TypeName: proto.String(".test.Comment"),
+								Number:   proto.Int32(3),
+								Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+								Options:  relation(&structify.Relation{Field: "id", Reference: "user_id"}),
+							},
+						},
+					},
+					{
+						Name: proto.String("Post"),
+						Field: []*descriptor.FieldDescriptorProto{
+							{Name: proto.String("id"), Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Number: proto.Int32(1), Options: pk()},
+							{Name: proto.String("author_id"), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Number: proto.Int32(2)},
+						},
+					},
+					{
+						Name: proto.String("Comment"),
+						Field: []*descriptor.FieldDescriptorProto{
+							{Name: proto.String("id"), Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Number: proto.Int32(1), Options: pk()},
+							{Name: proto.String("user_id"), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Number: proto.Int32(2)},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	state := NewState(req)
+
+	// without foreign.cascade only the relations named after the message allow nested writes
+	posts, ok := state.Relations.Get("User::Post")
+	require.True(t, ok)
+	assert.Equal(t, ParentToChild, int(posts.Direction))
+	assert.False(t, posts.AllowSubCreating)
+	assert.False(t, posts.CascadeDelete)
+
+	comments, ok := state.Relations.Get("User::Comment")
+	require.True(t, ok)
+	assert.True(t, comments.AllowSubCreating)
+	assert.False(t, comments.CascadeDelete)
+}
+
+func TestGetRelations_SelfReference(t *testing.T) {
+	relation := func(rel *structify.Relation) *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, structify.E_Field, &structify.StructifyFieldOptions{Relation: rel})
+		return opts
+	}
+	pk := func() *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, structify.E_Field, &structify.StructifyFieldOptions{PrimaryKey: true})
+		return opts
+	}
+
+	req := &plugingo.CodeGeneratorRequest{
+		FileToGenerate: []string{"test.proto"},
+		ProtoFile: []*descriptor.FileDescriptorProto{
+			{
+				Name:    proto.String("test.proto"),
+				Package: proto.String("test"),
+				MessageType: []*descriptor.DescriptorProto{
+					{
+						Name: proto.String("Category"),
+						Field: []*descriptor.FieldDescriptorProto{
+							{Name: proto.String("id"), Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Number: proto.Int32(1), Options: pk()},
+							{Name: proto.String("parent_id"), Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Number: proto.Int32(2), Proto3Optional: proto.Bool(true)},
+							{
+								Name:     proto.String("parent"),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+								TypeName: proto.String(".test.Category"),
+								Number:   proto.Int32(3),
+								Options: relation(&structify.Relation{
+									Field:     "parent_id",
+									Reference: "id",
+									Foreign:   &structify.Foreign{Cascade: true},
+								}),
+							},
+							{
+								Name:     proto.String("children"),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+								TypeName: proto.String(".test.Category"),
+								Number:   proto.Int32(4),
+								Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+								Options:  relation(&structify.Relation{Field: "id", Reference: "parent_id"}),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	state := NewState(req)
+
+	parent, ok := state.Relations.Get("Category::Category::parent")
+	require.True(t, ok)
+	assert.True(t, parent.SelfReference)
+	assert.Equal(t, ChildToParent, int(parent.Direction))
+	assert.Equal(t, "parent_id", parent.Field)
+	assert.Equal(t, "id", parent.Reference)
+	assert.False(t, parent.CascadeDelete)
+
+	children, ok := state.Relations.Get("Category::Category::children")
+	require.True(t, ok)
+	assert.True(t, children.SelfReference)
+	assert.Equal(t, ParentToChild, int(children.Direction))
+	assert.Equal(t, "id", children.Field)
+	assert.Equal(t, "parent_id", children.Reference)
+	assert.True(t, children.AllowSubCreating)
+	assert.True(t, children.CascadeDelete)
+}
+
+func TestGetRelations_Composite(t *testing.T) {
+	relation := func(rel *structify.Relation) *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, structify.E_Field, &structify.StructifyFieldOptions{Relation: rel})
+		return opts
+	}
+	pk := func() *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, structify.E_Field, &structify.StructifyFieldOptions{PrimaryKey: true})
+		return opts
+	}
+
+	req := &plugingo.CodeGeneratorRequest{
+		FileToGenerate: []string{"test.proto"},
+		ProtoFile: []*descriptor.FileDescriptorProto{
+			{
+				Name:    proto.String("test.proto"),
+				Package: proto.String("test"),
+				MessageType: []*descriptor.DescriptorProto{
+					{
+						Name: proto.String("ActiveTag"),
+						Field: []*descriptor.FieldDescriptorProto{
+							{Name: proto.String("customer_id"), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Number: proto.Int32(1), Options: pk()},
+							{Name: proto.String("tag_name"), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Number: proto.Int32(2), Options: pk()},
+							{
+								Name:     proto.String("events"),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+								TypeName: proto.String(".test.TagEvent"),
+								Number:   proto.Int32(3),
+								Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+								Options:  relation(&structify.Relation{Field: "tag_name,customer_id", Reference: "tag_name,customer_id"}),
+							},
+						},
+					},
+					{
+						Name: proto.String("TagEvent"),
+						Field: []*descriptor.FieldDescriptorProto{
+							{Name: proto.String("id"), Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Number: proto.Int32(1), Options: pk()},
+							{Name: proto.String("customer_id"), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Number: proto.Int32(2)},
+							{Name: proto.String("tag_name"), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Number: proto.Int32(3)},
+							{
+								Name:     proto.String("tag"),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+								TypeName: proto.String(".test.ActiveTag"),
+								Number:   proto.Int32(4),
+								Options:  relation(&structify.Relation{Field: "customer_id, tag_name", Reference: "customer_id, tag_name"}),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	state := NewState(req)
+
+	events, ok := state.Relations.Get("ActiveTag::TagEvent")
+	require.True(t, ok)
+	assert.True(t, events.IsComposite())
+	assert.Equal(t, []string{"tag_name", "customer_id"}, events.Fields())
+	assert.Equal(t, ParentToChild, int(events.Direction))
+	assert.False(t, events.AllowSubCreating)
+	assert.False(t, events.CascadeDelete)
+
+	tag, ok := state.Relations.Get("TagEvent::ActiveTag")
+	require.True(t, ok)
+	assert.True(t, tag.IsComposite())
+	assert.Equal(t, []string{"customer_id", "tag_name"}, tag.References())
+	assert.Equal(t, ChildToParent, int(tag.Direction))
+}